@@ -0,0 +1,113 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/olzzhas/qrent/internal/data"
+	"github.com/olzzhas/qrent/pkg/validator"
+	"github.com/tomasen/realip"
+)
+
+// auditFor возвращает автора изменения для журнала аудита. Модель пишет
+// запись в транзакции самого изменения: если запись не удалась, изменение
+// откатывается и запрос завершается ошибкой. before — ресурс до правки или
+// удаления, nil для создания.
+func (app *application) auditFor(r *http.Request, before any) *data.Audit {
+	audit := &data.Audit{
+		IP:        realip.FromRequest(r),
+		RequestID: app.contextGetRequestID(r),
+		Before:    before,
+	}
+
+	if user := app.contextGetUser(r); !user.IsAnonymous() {
+		audit.ActorID = &user.ID
+	}
+
+	return audit
+}
+
+// readAuditResource разбирает параметр resource: тип ресурса или тип и
+// идентификатор через двоеточие, например station:12.
+func (app *application) readAuditResource(s string, v *validator.Validator) (string, int) {
+	if s == "" {
+		return "", 0
+	}
+
+	resourceType, rawID, hasID := strings.Cut(s, ":")
+	v.Check(slices.Contains(data.AuditResources, resourceType), "resource", "must be one of: "+strings.Join(data.AuditResources, ", "))
+	if !hasID {
+		return resourceType, 0
+	}
+
+	id, err := strconv.Atoi(rawID)
+	if err != nil || id < 1 {
+		v.AddError("resource", "resource id must be a positive integer")
+		return resourceType, 0
+	}
+
+	return resourceType, id
+}
+
+// ListAuditHandler godoc
+// @Summary Возвращает журнал аудита
+// @Description Возвращает страницу записей аудита изменяющих запросов. Доступно только администраторам
+// @Tags audit
+// @Accept json
+// @Produce json
+// @Param resource query string false "Тип ресурса (organization, station, powerbank, user, tariff, payment) или тип и ID через двоеточие: station:12"
+// @Param actor query int false "ID пользователя, выполнившего изменение"
+// @Param from query string false "Начало периода, RFC 3339 (включительно)"
+// @Param to query string false "Конец периода, RFC 3339 (не включительно)"
+// @Param page query int false "Номер страницы" default(1)
+// @Param page_size query int false "Размер страницы (до 100)" default(20)
+// @Param sort query string false "Сортировка: id, created_at; префикс - для убывания" default(-id)
+// @Success 200 {object} AuditListResponse
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Security BearerAuth
+// @Router /audit [get]
+func (app *application) ListAuditHandler(w http.ResponseWriter, r *http.Request) {
+	if app.contextGetUser(r).Role != data.RoleAdmin {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	var filter data.AuditFilter
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	filter.ResourceType, filter.ResourceID = app.readAuditResource(qs.Get("resource"), v)
+	filter.ActorID = app.readInt(qs, "actor", 0, v)
+	filter.From = app.readTime(qs, "from", v)
+	filter.To = app.readTime(qs, "to", v)
+	if filter.From != nil && filter.To != nil {
+		v.Check(filter.From.Before(*filter.To), "to", "must be after from")
+	}
+
+	filters := data.Filters{
+		Page:         app.readInt(qs, "page", 1, v),
+		PageSize:     app.readInt(qs, "page_size", 20, v),
+		Sort:         app.readString(qs, "sort", "-id"),
+		SortSafelist: []string{"id", "created_at", "-id", "-created_at"},
+	}
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	entries, metadata, err := app.models.Audit.List(filter, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{"audit": entries, "metadata": metadata}
+	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
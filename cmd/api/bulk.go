@@ -0,0 +1,381 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/olzzhas/qrent/internal/data"
+	"github.com/olzzhas/qrent/pkg/validator"
+)
+
+const (
+	csvMediaType    = "text/csv"
+	ndjsonMediaType = "application/x-ndjson"
+
+	importMaxBytes = 10_485_760
+	importMaxRows  = 10_000
+
+	// exportStatusTrailer — трейлер ответа выгрузки: complete, если файл
+	// выгружен целиком, error — если выгрузка оборвалась.
+	exportStatusTrailer = "X-Export-Status"
+	// exportAbortedMarker — последняя строка оборванной выгрузки. В ней одно
+	// поле, поэтому файл с ней не пройдёт и проверку числа столбцов.
+	exportAbortedMarker = "#export-aborted"
+)
+
+var errUnsupportedImportFormat = errors.New("unsupported import format")
+
+// importRow — строка файла импорта. У строки CSV заполнен cells, у строки
+// NDJSON — object. line — номер строки в файле, с которым она попадёт в отчёт
+// об ошибках.
+type importRow struct {
+	line   int
+	cells  map[string]string
+	object mergePatch
+}
+
+// importRowError — ошибки одной строки файла импорта.
+type importRowError struct {
+	Line   int               `json:"line"`
+	Errors map[string]string `json:"errors"`
+}
+
+// importReport — итог импорта. При DryRun строки только проверены.
+type importReport struct {
+	Rows   int  `json:"rows"`
+	DryRun bool `json:"dry_run"`
+}
+
+// readImport читает тело импорта в формате CSV с заголовком или NDJSON.
+// Колонки CSV и ключи NDJSON должны быть из columns: неизвестная колонка
+// ломает весь файл, неизвестный ключ — только свою строку. Формат берётся из
+// Content-Type; для прочих типов возвращается errUnsupportedImportFormat.
+func (app *application) readImport(w http.ResponseWriter, r *http.Request, columns ...string) ([]importRow, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	body := http.MaxBytesReader(w, r.Body, importMaxBytes)
+
+	var (
+		rows []importRow
+		err  error
+	)
+	switch mediaType {
+	case csvMediaType:
+		rows, err = readCSVImport(body, columns)
+	case ndjsonMediaType:
+		rows, err = readNDJSONImport(body)
+	default:
+		return nil, errUnsupportedImportFormat
+	}
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			return nil, fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+		}
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("body must contain at least one row")
+	}
+
+	return rows, nil
+}
+
+func readCSVImport(body io.Reader, columns []string) ([]importRow, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("body must not be empty")
+		}
+		return nil, err
+	}
+
+	for i, name := range header {
+		// Excel сохраняет CSV в UTF-8 с BOM перед первой колонкой.
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		switch {
+		case !slices.Contains(columns, name):
+			return nil, fmt.Errorf("unknown column %q, expected columns: %s", name, strings.Join(columns, ", "))
+		case slices.Contains(header[:i], name):
+			return nil, fmt.Errorf("duplicate column %q", name)
+		}
+		header[i] = name
+	}
+
+	var rows []importRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if len(rows) == importMaxRows {
+			return nil, fmt.Errorf("body must not contain more than %d rows", importMaxRows)
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := importRow{line: line, cells: make(map[string]string, len(header))}
+		for i, name := range header {
+			row.cells[name] = strings.TrimSpace(record[i])
+		}
+		rows = append(rows, row)
+	}
+}
+
+func readNDJSONImport(body io.Reader) ([]importRow, error) {
+	scanner := bufio.NewScanner(body)
+
+	var rows []importRow
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		if len(rows) == importMaxRows {
+			return nil, fmt.Errorf("body must not contain more than %d rows", importMaxRows)
+		}
+
+		row := importRow{line: line}
+		if err := json.Unmarshal([]byte(text), &row.object); err != nil || row.object == nil {
+			return nil, fmt.Errorf("line %d must be a JSON object", line)
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+// check начинает проверку строки: неизвестные ключи NDJSON сразу становятся
+// ошибками своих полей.
+func (row importRow) check(columns ...string) *validator.Validator {
+	v := validator.New()
+	for key := range row.object {
+		if !slices.Contains(columns, key) {
+			v.AddError(key, "is not a known field")
+		}
+	}
+	return v
+}
+
+// importField переносит значение колонки key в dst. Пустая ячейка CSV, как и
+// null в NDJSON, записывает нулевое значение. Значение неверного типа
+// становится ошибкой поля key.
+func importField[T any](row importRow, key string, dst *T, v *validator.Validator) {
+	if row.cells == nil {
+		patchField(row.object, key, dst, v)
+		return
+	}
+
+	cell, ok := row.cells[key]
+	if !ok {
+		return
+	}
+
+	var value T
+	if err := parseCell(cell, &value); err != nil {
+		v.AddError(key, "must be "+jsonTypeName(reflect.TypeOf(value)))
+		return
+	}
+
+	*dst = value
+}
+
+func parseCell(cell string, dst any) error {
+	switch dst := dst.(type) {
+	case *string:
+		*dst = cell
+	case *int:
+		if cell == "" {
+			return nil
+		}
+		n, err := strconv.Atoi(cell)
+		if err != nil {
+			return err
+		}
+		*dst = n
+	case **int:
+		if cell == "" {
+			return nil
+		}
+		n, err := strconv.Atoi(cell)
+		if err != nil {
+			return err
+		}
+		*dst = &n
+	case **float64:
+		if cell == "" {
+			return nil
+		}
+		f, err := strconv.ParseFloat(cell, 64)
+		if err != nil {
+			return err
+		}
+		*dst = &f
+	default:
+		panic(fmt.Sprintf("unsupported import field type %T", dst))
+	}
+
+	return nil
+}
+
+// importRowErrors превращает ошибку пакетной вставки в ошибку строки файла.
+// describe возвращает поле и сообщение для ошибок, которые клиент может
+// исправить в файле; остальные ошибки остаются ошибками сервера.
+func importRowErrors(rows []importRow, err error, describe func(error) (string, string, bool)) ([]importRowError, bool) {
+	var bulkErr *data.BulkError
+	if !errors.As(err, &bulkErr) {
+		return nil, false
+	}
+
+	field, message, ok := describe(bulkErr.Err)
+	if !ok {
+		return nil, false
+	}
+
+	return []importRowError{{
+		Line:   rows[bulkErr.Index].line,
+		Errors: map[string]string{field: message},
+	}}, true
+}
+
+// csvExport пишет выгрузку CSV по мере чтения строк из базы. Заголовки
+// ответа отправляются с первой строкой, чтобы ошибка запроса до неё ещё
+// могла стать ответом 500.
+type csvExport struct {
+	w        http.ResponseWriter
+	cw       *csv.Writer
+	filename string
+	columns  []string
+	started  bool
+}
+
+func newCSVExport(w http.ResponseWriter, filename string, columns ...string) *csvExport {
+	return &csvExport{w: w, cw: csv.NewWriter(w), filename: filename, columns: columns}
+}
+
+func (e *csvExport) start() error {
+	e.w.Header().Set("Content-Type", csvMediaType+"; charset=utf-8")
+	e.w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, e.filename))
+	e.w.Header().Set("Trailer", exportStatusTrailer)
+	e.w.WriteHeader(http.StatusOK)
+	e.started = true
+
+	return e.cw.Write(e.columns)
+}
+
+func (e *csvExport) write(record []string) error {
+	if !e.started {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+
+	return e.cw.Write(record)
+}
+
+// finish дописывает буфер и помечает выгрузку полной.
+func (e *csvExport) finish() error {
+	e.cw.Flush()
+	if err := e.cw.Error(); err != nil {
+		return err
+	}
+
+	e.w.Header().Set(exportStatusTrailer, "complete")
+	return nil
+}
+
+// abort дописывает отправленное и обрывает выгрузку так, чтобы клиент это
+// заметил: строкой exportAbortedMarker в конце файла и трейлером error.
+func (e *csvExport) abort() {
+	e.cw.Write([]string{exportAbortedMarker})
+	e.cw.Flush()
+	e.w.Header().Set(exportStatusTrailer, "error")
+}
+
+// finishCSVExport завершает выгрузку после чтения из базы с ошибкой err.
+// Если строки уже отправлены, статус ответа изменить нельзя, поэтому
+// выгрузка обрывается через abort, чтобы клиент не принял файл за полный.
+func (app *application) finishCSVExport(w http.ResponseWriter, r *http.Request, e *csvExport, err error) {
+	switch {
+	case err != nil && !e.started:
+		app.serverErrorResponse(w, r, err)
+		return
+	case err != nil:
+		app.logError(r, err)
+		e.abort()
+		return
+	case !e.started:
+		err = e.start()
+	}
+
+	if err == nil {
+		err = e.finish()
+	}
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+// readExportOrgID читает параметр org_id выгрузки. Оператор выгружает только
+// свою организацию, и без параметра выгружается она; администратор без
+// параметра выгружает всё.
+func (app *application) readExportOrgID(r *http.Request, v *validator.Validator) (int, bool) {
+	user := app.contextGetUser(r)
+
+	orgID := app.readInt(r.URL.Query(), "org_id", 0, v)
+	v.Check(orgID >= 0, "org_id", "must be a positive integer")
+
+	if orgID == 0 && user.Role != data.RoleAdmin {
+		if user.OrgID == nil {
+			return 0, false
+		}
+		orgID = *user.OrgID
+	}
+
+	return orgID, orgID == 0 || user.CanManageOrganization(orgID)
+}
+
+func csvOptionalInt(n *int) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.Itoa(*n)
+}
+
+func csvOptionalFloat(f *float64) string {
+	if f == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*f, 'f', -1, 64)
+}
+
+// csvText экранирует текстовую ячейку выгрузки: значение, начинающееся с
+// = + - @ или табуляции и перевода строки, табличный редактор выполнит как
+// формулу, поэтому перед ним ставится апостроф.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/csv"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCSVText(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"ул. Абая, 10", "ул. Абая, 10"},
+		{"", ""},
+		{"=HYPERLINK(\"http://evil\")", "'=HYPERLINK(\"http://evil\")"},
+		{"+7 701 000 00 00", "'+7 701 000 00 00"},
+		{"-1+1", "'-1+1"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1", "'\t=1"},
+		{"a=1", "a=1"},
+	}
+
+	for _, tt := range tests {
+		if got := csvText(tt.value); got != tt.expected {
+			t.Errorf("csvText(%q): expected %q, got %q", tt.value, tt.expected, got)
+		}
+	}
+}
+
+// Оборванная выгрузка заканчивается маркером, который ломает разбор CSV, и
+// трейлером error; полная — трейлером complete.
+func TestCSVExport_Trailer(t *testing.T) {
+	tests := []struct {
+		name   string
+		abort  bool
+		status string
+	}{
+		{"complete", false, "complete"},
+		{"aborted", true, "error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			e := newCSVExport(w, "stations.csv", "id", "address")
+			if err := e.write([]string{"1", "ул. Абая, 10"}); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if tt.abort {
+				e.abort()
+			} else if err := e.finish(); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			res := w.Result()
+			if got := res.Trailer.Get(exportStatusTrailer); got != tt.status {
+				t.Errorf("expected trailer %q, got %q", tt.status, got)
+			}
+
+			body := w.Body.String()
+			if !strings.HasPrefix(body, "id,address\n1,\"ул. Абая, 10\"\n") {
+				t.Errorf("rows written before the end are missing: %q", body)
+			}
+			_, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+			if (err != nil) != tt.abort {
+				t.Errorf("expected parse error=%t, got %v", tt.abort, err)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"os"
 	"strconv"
+	"time"
 )
 
 func loadConfig() config {
@@ -19,6 +20,23 @@ func loadConfig() config {
 	cfg.limiter.rps = getEnvAsFloat("LIMITER_RPS", 10)
 	cfg.limiter.burst = getEnvAsInt("LIMITER_BURST", 50)
 	cfg.limiter.enabled = getEnvAsBool("LIMITER_ENABLED", true)
+	cfg.limiter.backend = getEnv("LIMITER_BACKEND", "memory")
+	cfg.limiter.write.rps = getEnvAsFloat("LIMITER_WRITE_RPS", 2)
+	cfg.limiter.write.burst = getEnvAsInt("LIMITER_WRITE_BURST", 10)
+	cfg.limiter.rental.rps = getEnvAsFloat("LIMITER_RENTAL_RPS", 0.2)
+	cfg.limiter.rental.burst = getEnvAsInt("LIMITER_RENTAL_BURST", 3)
+	cfg.limiter.ip.rps = getEnvAsFloat("LIMITER_IP_RPS", 50)
+	cfg.limiter.ip.burst = getEnvAsInt("LIMITER_IP_BURST", 200)
+
+	cfg.auth.exposeActivationToken = getEnvAsBool("EXPOSE_ACTIVATION_TOKEN", false)
+
+	cfg.qr.secret = getEnv("QR_SECRET", "")
+
+	cfg.payments.provider = getEnv("PAYMENT_PROVIDER", "fake")
+
+	cfg.rentals.overdueAfter = getEnvAsDuration("RENTAL_OVERDUE_AFTER", 24*time.Hour)
+	cfg.rentals.lostAfter = getEnvAsDuration("RENTAL_LOST_AFTER", 72*time.Hour)
+	cfg.rentals.checkInterval = getEnvAsDuration("RENTAL_CHECK_INTERVAL", 5*time.Minute)
 
 	return cfg
 }
@@ -53,3 +71,11 @@ func getEnvAsBool(name string, defaultVal bool) bool {
 	}
 	return defaultVal
 }
+
+func getEnvAsDuration(name string, defaultVal time.Duration) time.Duration {
+	valStr := getEnv(name, "")
+	if val, err := time.ParseDuration(valStr); err == nil {
+		return val
+	}
+	return defaultVal
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"github.com/olzzhas/qrent/internal/data"
+	"net/http"
+)
+
+type contextKey string
+
+const (
+	userContextKey      = contextKey("user")
+	requestIDContextKey = contextKey("request_id")
+)
+
+func (app *application) contextSetUser(r *http.Request, user *data.User) *http.Request {
+	ctx := context.WithValue(r.Context(), userContextKey, user)
+	return r.WithContext(ctx)
+}
+
+func (app *application) contextGetUser(r *http.Request) *data.User {
+	user, ok := r.Context().Value(userContextKey).(*data.User)
+	if !ok {
+		panic("missing user value in request context")
+	}
+
+	return user
+}
+
+func (app *application) contextSetRequestID(r *http.Request, id string) *http.Request {
+	ctx := context.WithValue(r.Context(), requestIDContextKey, id)
+	return r.WithContext(ctx)
+}
+
+// contextGetRequestID возвращает идентификатор запроса или пустую строку,
+// если запрос не прошёл через middleware requestID.
+func (app *application) contextGetRequestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDContextKey).(string)
+	return id
+}
@@ -17,10 +17,14 @@ func CorsSettings() *cors.Cors {
 		AllowedHeaders: []string{
 			"Content-Type",
 			"Authorization",
+			"If-Match",
+			"Idempotency-Key",
 		},
 		OptionsPassthrough: true,
 		ExposedHeaders: []string{
 			"Content-Type",
+			"ETag",
+			"Idempotent-Replayed",
 		},
 		Debug: false,
 	})
@@ -1,14 +1,18 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+
+	"github.com/olzzhas/qrent/internal/data"
 )
 
 func (app *application) logError(r *http.Request, err error) {
 	app.logger.PrintError(err, map[string]any{
 		"request_method": r.Method,
 		"request_url":    r.URL.String(),
+		"request_id":     app.contextGetRequestID(r),
 	}, "errors")
 }
 
@@ -52,6 +56,38 @@ func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Requ
 	app.errorResponse(w, r, http.StatusConflict, message)
 }
 
+func (app *application) preconditionFailedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the record has been modified since it was fetched, fetch it again and retry"
+	app.errorResponse(w, r, http.StatusPreconditionFailed, message)
+}
+
+func (app *application) unsupportedMediaTypeResponse(w http.ResponseWriter, r *http.Request, mediaType string) {
+	w.Header().Set("Accept-Patch", mediaType)
+	message := fmt.Sprintf("the request body must be sent as %s", mediaType)
+	app.errorResponse(w, r, http.StatusUnsupportedMediaType, message)
+}
+
+func (app *application) unsupportedImportFormatResponse(w http.ResponseWriter, r *http.Request) {
+	message := fmt.Sprintf("the request body must be sent as %s or %s", csvMediaType, ndjsonMediaType)
+	app.errorResponse(w, r, http.StatusUnsupportedMediaType, message)
+}
+
+// importFailedResponse отвечает на файл импорта с ошибками списком строк
+// файла и их ошибок.
+func (app *application) importFailedResponse(w http.ResponseWriter, r *http.Request, rows []importRowError) {
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, envelope{"rows": rows})
+}
+
+func (app *application) idempotencyKeyReusedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the Idempotency-Key has already been used for a different request"
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, message)
+}
+
+func (app *application) idempotencyInProgressResponse(w http.ResponseWriter, r *http.Request) {
+	message := "a request with this Idempotency-Key is still being processed, please retry later"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
 func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
 	message := "rate limit exceeded"
 	app.errorResponse(w, r, http.StatusTooManyRequests, message)
@@ -94,3 +130,20 @@ func (app *application) invalidTimeResponse(w http.ResponseWriter, r *http.Reque
 	message := "given time is not valid"
 	app.errorResponse(w, r, http.StatusBadRequest, message)
 }
+
+// restoreErrorResponse отвечает на ошибки восстановления мягко удалённой записи.
+func (app *application) restoreErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.notFoundResponse(w, r)
+	case errors.Is(err, data.ErrNotDeleted), errors.Is(err, data.ErrParentDeleted):
+		app.errorResponse(w, r, http.StatusConflict, err.Error())
+	default:
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) paymentDeclinedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the payment provider declined the deposit authorization"
+	app.errorResponse(w, r, http.StatusPaymentRequired, message)
+}
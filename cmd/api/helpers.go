@@ -5,12 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"github.com/julienschmidt/httprouter"
+	"github.com/olzzhas/qrent/internal/data"
 	"github.com/olzzhas/qrent/pkg/validator"
 	"io"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type envelope map[string]any
@@ -45,17 +47,6 @@ func (app *application) writeJSON(w http.ResponseWriter, status int, data envelo
 	return nil
 }
 
-func (app *application) writeJSONRedis(data any) (response any, err error) {
-	js, err := json.Marshal(data)
-	if err != nil {
-		return nil, err
-	}
-
-	js = append(js, '\n')
-
-	return js, nil
-}
-
 func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
 	maxBytes := 1_048_576
 	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
@@ -139,6 +130,95 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 	return i
 }
 
+func (app *application) readFloat(qs url.Values, key string, defaultValue float64, v *validator.Validator) float64 {
+	s := qs.Get(key)
+
+	if s == "" {
+		return defaultValue
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		v.AddError(key, "must be a number")
+		return defaultValue
+	}
+
+	return f
+}
+
+func (app *application) readBool(qs url.Values, key string, defaultValue bool, v *validator.Validator) bool {
+	s := qs.Get(key)
+
+	if s == "" {
+		return defaultValue
+	}
+
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		v.AddError(key, "must be a boolean value")
+		return defaultValue
+	}
+
+	return b
+}
+
+// readIncludeDeleted читает параметр include_deleted. Мягко удалённые записи
+// видны только администраторам, остальным параметр возвращает ошибку
+// валидации.
+func (app *application) readIncludeDeleted(r *http.Request, v *validator.Validator) bool {
+	include := app.readBool(r.URL.Query(), "include_deleted", false, v)
+	if include && app.contextGetUser(r).Role != data.RoleAdmin {
+		v.AddError("include_deleted", "is available to administrators only")
+		return false
+	}
+
+	return include
+}
+
+// etagHeader возвращает заголовки ответа с ETag версии записи.
+func (app *application) etagHeader(version int) http.Header {
+	headers := make(http.Header)
+	headers.Set("ETag", strconv.Quote(strconv.Itoa(version)))
+	return headers
+}
+
+// checkIfMatch проверяет заголовок If-Match по версии записи. Запрос без
+// заголовка или с "*" проходит; иначе один из перечисленных ETag должен
+// совпасть с текущим. Слабые ETag (W/"...") для If-Match не совпадают никогда.
+func (app *application) checkIfMatch(r *http.Request, version int) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return true
+	}
+
+	current := strconv.Quote(strconv.Itoa(version))
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == current {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readTime разбирает параметр key в формате RFC 3339. Пустой параметр даёт nil.
+func (app *application) readTime(qs url.Values, key string, v *validator.Validator) *time.Time {
+	s := qs.Get(key)
+
+	if s == "" {
+		return nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		v.AddError(key, "must be an RFC 3339 timestamp")
+		return nil
+	}
+
+	return &t
+}
+
 func (app *application) background(fn func()) {
 	app.wg.Add(1)
 
@@ -154,3 +234,14 @@ func (app *application) background(fn func()) {
 		fn()
 	}()
 }
+
+// canManageStation сообщает, может ли текущий пользователь управлять станцией
+// stationID и её повербанками.
+func (app *application) canManageStation(r *http.Request, stationID int) (bool, error) {
+	station, err := app.models.Station.Get(stationID)
+	if err != nil {
+		return false, err
+	}
+
+	return app.contextGetUser(r).CanManageOrganization(station.OrgID), nil
+}
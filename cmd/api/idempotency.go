@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// idempotencyTTL — сколько хранится ответ на запрос с Idempotency-Key.
+	idempotencyTTL = 24 * time.Hour
+	// idempotencyLockTTL ограничивает время, на которое ключ занимается
+	// выполняющимся запросом, если процесс упадёт, не дописав ответ. Он должен
+	// быть дольше самого медленного обработчика (импорт и экспорт — до минуты),
+	// иначе повтор займёт освободившийся ключ и выполнится второй раз.
+	idempotencyLockTTL = 5 * time.Minute
+)
+
+// idempotencyKeyRX — допустимый Idempotency-Key: обычно это UUID клиента.
+var idempotencyKeyRX = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,255}$`)
+
+// idempotencyRecord — то, что хранится в Redis по ключу идемпотентности.
+// Пока исходный запрос выполняется, Status равен нулю.
+type idempotencyRecord struct {
+	Fingerprint string      `json:"fingerprint"`
+	Status      int         `json:"status,omitempty"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+}
+
+// idempotent выполняет запрос с заголовком Idempotency-Key не больше одного
+// раза: ответ сохраняется в Redis, и повтор с тем же ключом и телом получает
+// его копию. Тот же ключ с другим телом или путём отклоняется с 422, а повтор,
+// пришедший до завершения исходного запроса, — с 409. Ответы 5xx не
+// сохраняются, чтобы клиент мог повторить запрос. Без заголовка запрос
+// обрабатывается как обычно. Должен вызываться после authenticate.
+func (app *application) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !idempotencyKeyRX.MatchString(key) {
+			app.badRequestResponse(w, r, errors.New("Idempotency-Key must be 1-255 letters, digits or ._:- characters"))
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1_048_576))
+		if err != nil {
+			app.badRequestResponse(w, r, fmt.Errorf("unable to read request body: %w", err))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.New()
+		fmt.Fprintf(hash, "%s %s\n", r.Method, r.URL.Path)
+		hash.Write(body)
+		fingerprint := hex.EncodeToString(hash.Sum(nil))
+
+		redisKey := fmt.Sprintf("idempotency:%d:%s", app.contextGetUser(r).ID, key)
+
+		acquired, err := app.acquireIdempotencyKey(redisKey, fingerprint)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if !acquired {
+			app.replayIdempotentResponse(w, r, redisKey, fingerprint)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w}
+		stored := false
+		defer func() {
+			if !stored {
+				app.releaseIdempotencyKey(r, redisKey)
+			}
+		}()
+
+		next.ServeHTTP(rec, r)
+
+		if rec.status == 0 || rec.status >= http.StatusInternalServerError {
+			return
+		}
+
+		record := idempotencyRecord{
+			Fingerprint: fingerprint,
+			Status:      rec.status,
+			Header:      rec.Header().Clone(),
+			Body:        rec.body.Bytes(),
+		}
+		if err := app.storeIdempotencyRecord(redisKey, record, idempotencyTTL); err != nil {
+			app.logError(r, err)
+			return
+		}
+		stored = true
+	}
+}
+
+// acquireIdempotencyKey занимает ключ за текущим запросом. false означает,
+// что ключ уже использован другим запросом.
+func (app *application) acquireIdempotencyKey(redisKey, fingerprint string) (bool, error) {
+	js, err := json.Marshal(idempotencyRecord{Fingerprint: fingerprint})
+	if err != nil {
+		return false, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return app.redis.SetNX(ctx, redisKey, js, idempotencyLockTTL).Result()
+}
+
+func (app *application) storeIdempotencyRecord(redisKey string, record idempotencyRecord, ttl time.Duration) error {
+	js, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return app.redis.Set(ctx, redisKey, js, ttl).Err()
+}
+
+// releaseIdempotencyKey освобождает ключ запроса, ответ на который не был
+// сохранён, чтобы клиент мог повторить его с тем же ключом.
+func (app *application) releaseIdempotencyKey(r *http.Request, redisKey string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	if err := app.redis.Del(ctx, redisKey).Err(); err != nil {
+		app.logError(r, err)
+	}
+}
+
+// replayIdempotentResponse отвечает на повтор запроса с уже использованным
+// ключом.
+func (app *application) replayIdempotentResponse(w http.ResponseWriter, r *http.Request, redisKey, fingerprint string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	js, err := app.redis.Get(ctx, redisKey).Bytes()
+	if err != nil {
+		switch {
+		case errors.Is(err, redis.Nil):
+			// Исходный запрос завершился ошибкой и освободил ключ между
+			// SETNX и GET: повтор с тем же ключом будет выполнен заново.
+			app.idempotencyInProgressResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var record idempotencyRecord
+	if err := json.Unmarshal(js, &record); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	switch {
+	case record.Fingerprint != fingerprint:
+		app.idempotencyKeyReusedResponse(w, r)
+		return
+	case record.Status == 0:
+		app.idempotencyInProgressResponse(w, r)
+		return
+	}
+
+	for name, values := range record.Header {
+		// X-Request-ID относится к текущему запросу, а не к исходному.
+		if name == "X-Request-Id" {
+			continue
+		}
+		w.Header()[name] = values
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(record.Status)
+	w.Write(record.Body)
+}
+
+// responseRecorder пропускает ответ клиенту, попутно запоминая статус и тело.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	if rr.status == 0 {
+		rr.status = status
+	}
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	if rr.status == 0 {
+		rr.status = http.StatusOK
+	}
+	rr.body.Write(b)
+	return rr.ResponseWriter.Write(b)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/olzzhas/qrent/internal/data"
+	"github.com/olzzhas/qrent/internal/redistest"
+)
+
+// newIdempotentRequest строит POST от пользователя 1 с ключом key.
+func newIdempotentRequest(app *application, key, body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/v1/stations", strings.NewReader(body))
+	r.Header.Set("Idempotency-Key", key)
+	return app.contextSetUser(r, &data.User{ID: 1})
+}
+
+func serveIdempotent(app *application, handler http.HandlerFunc, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	app.idempotent(handler)(w, r)
+	return w
+}
+
+func TestIdempotent_ReplaysStoredResponse(t *testing.T) {
+	app := &application{redis: redistest.NewClient(t)}
+
+	calls := 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Location", "/v1/stations/7")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"station":{"id":7}}`))
+	}
+
+	first := serveIdempotent(app, handler, newIdempotentRequest(app, "key-1", `{"name":"A"}`))
+	second := serveIdempotent(app, handler, newIdempotentRequest(app, "key-1", `{"name":"A"}`))
+
+	if calls != 1 {
+		t.Errorf("expected the handler to run once, ran %d times", calls)
+	}
+	if second.Code != http.StatusCreated || second.Body.String() != first.Body.String() {
+		t.Errorf("expected the stored response, got %d %q", second.Code, second.Body.String())
+	}
+	if second.Header().Get("Location") != "/v1/stations/7" || second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("unexpected replay headers: %v", second.Header())
+	}
+	if first.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("original response must not be marked as replayed")
+	}
+}
+
+func TestIdempotent_DifferentBodyRejected(t *testing.T) {
+	app := &application{redis: redistest.NewClient(t)}
+
+	calls := 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusCreated)
+	}
+
+	serveIdempotent(app, handler, newIdempotentRequest(app, "key-1", `{"name":"A"}`))
+	w := serveIdempotent(app, handler, newIdempotentRequest(app, "key-1", `{"name":"B"}`))
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, w.Code)
+	}
+	if calls != 1 {
+		t.Errorf("expected the handler to run once, ran %d times", calls)
+	}
+}
+
+func TestIdempotent_InFlightRejected(t *testing.T) {
+	app := &application{redis: redistest.NewClient(t)}
+
+	var retry *httptest.ResponseRecorder
+	var handler http.HandlerFunc
+	handler = func(w http.ResponseWriter, r *http.Request) {
+		// Повтор приходит, пока исходный запрос ещё выполняется.
+		if retry == nil {
+			retry = serveIdempotent(app, handler, newIdempotentRequest(app, "key-1", `{"name":"A"}`))
+		}
+		w.WriteHeader(http.StatusCreated)
+	}
+
+	w := serveIdempotent(app, handler, newIdempotentRequest(app, "key-1", `{"name":"A"}`))
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("expected the original request to succeed, got %d", w.Code)
+	}
+	if retry.Code != http.StatusConflict {
+		t.Errorf("expected status %d for the concurrent retry, got %d", http.StatusConflict, retry.Code)
+	}
+}
+
+func TestIdempotent_ServerErrorReleasesKey(t *testing.T) {
+	app := &application{redis: redistest.NewClient(t)}
+
+	calls := 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}
+
+	serveIdempotent(app, handler, newIdempotentRequest(app, "key-1", `{"name":"A"}`))
+	w := serveIdempotent(app, handler, newIdempotentRequest(app, "key-1", `{"name":"A"}`))
+
+	if w.Code != http.StatusCreated || calls != 2 {
+		t.Errorf("expected the retry to run after a 5xx, got %d after %d calls", w.Code, calls)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"golang.org/x/time/rate"
+	"sync"
+	"time"
+)
+
+const (
+	limiterBackendMemory = "memory"
+	limiterBackendRedis  = "redis"
+)
+
+// rateLimiter решает, можно ли пропустить очередной запрос из корзины key
+// при заданных rps и burst.
+type rateLimiter interface {
+	Allow(ctx context.Context, key string, rps float64, burst int) (bool, error)
+}
+
+func newRateLimiter(backend string, redisClient *redis.Client) (rateLimiter, error) {
+	switch backend {
+	case limiterBackendMemory:
+		return newMemoryLimiter(), nil
+	case limiterBackendRedis:
+		return &redisLimiter{client: redisClient}, nil
+	default:
+		return nil, fmt.Errorf("unknown limiter backend %q", backend)
+	}
+}
+
+// validateLimiterConfig проверяет квоты всех классов включённого лимитера.
+// Нулевой rps или burst не отключает лимит: корзина в памяти никогда не
+// пропустит запрос, а скрипт Redis не сможет выставить срок жизни ключа.
+func validateLimiterConfig(cfg config) error {
+	if !cfg.limiter.enabled {
+		return nil
+	}
+
+	classes := []struct {
+		name  string
+		rps   float64
+		burst int
+	}{
+		{"LIMITER", cfg.limiter.rps, cfg.limiter.burst},
+		{"LIMITER_WRITE", cfg.limiter.write.rps, cfg.limiter.write.burst},
+		{"LIMITER_RENTAL", cfg.limiter.rental.rps, cfg.limiter.rental.burst},
+		{"LIMITER_IP", cfg.limiter.ip.rps, cfg.limiter.ip.burst},
+	}
+	for _, class := range classes {
+		if class.rps <= 0 || class.burst <= 0 {
+			return fmt.Errorf("%s_RPS и %s_BURST должны быть больше нуля", class.name, class.name)
+		}
+	}
+
+	return nil
+}
+
+// memoryLimiter хранит корзины в памяти процесса: лимиты не делятся между
+// репликами и сбрасываются при перезапуске.
+type memoryLimiter struct {
+	mu      sync.Mutex
+	clients map[string]*memoryClient
+}
+
+type memoryClient struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newMemoryLimiter() *memoryLimiter {
+	l := &memoryLimiter{clients: make(map[string]*memoryClient)}
+
+	go func() {
+		for {
+			time.Sleep(time.Minute)
+
+			l.mu.Lock()
+			for key, client := range l.clients {
+				if time.Since(client.lastSeen) > 3*time.Minute {
+					delete(l.clients, key)
+				}
+			}
+			l.mu.Unlock()
+		}
+	}()
+
+	return l
+}
+
+func (l *memoryLimiter) Allow(_ context.Context, key string, rps float64, burst int) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, found := l.clients[key]; !found {
+		l.clients[key] = &memoryClient{
+			limiter: rate.NewLimiter(rate.Limit(rps), burst),
+		}
+	}
+	l.clients[key].lastSeen = time.Now()
+
+	return l.clients[key].limiter.Allow(), nil
+}
+
+// tokenBucketScript атомарно пополняет и списывает корзину. Время берётся
+// из самого Redis, чтобы расхождение часов между репликами не влияло на лимит.
+var tokenBucketScript = redis.NewScript(`
+redis.replicate_commands()
+
+local key = KEYS[1]
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local bucket = redis.call('HMGET', key, 'tokens', 'ts')
+local tokens = tonumber(bucket[1])
+local ts = tonumber(bucket[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HSET', key, 'tokens', tokens, 'ts', now)
+redis.call('PEXPIRE', key, math.ceil(burst / rate * 1000) + 1000)
+
+return allowed
+`)
+
+// redisLimiter реализует token bucket в Redis, поэтому лимиты согласованы
+// между всеми репликами qrent.
+type redisLimiter struct {
+	client *redis.Client
+}
+
+func (l *redisLimiter) Allow(ctx context.Context, key string, rps float64, burst int) (bool, error) {
+	allowed, err := tokenBucketScript.Run(ctx, l.client, []string{"ratelimit:" + key}, rps, burst).Int()
+	if err != nil {
+		return false, err
+	}
+
+	return allowed == 1, nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func validLimiterConfig() config {
+	var cfg config
+	cfg.limiter.enabled = true
+	cfg.limiter.rps, cfg.limiter.burst = 10, 50
+	cfg.limiter.write.rps, cfg.limiter.write.burst = 2, 10
+	cfg.limiter.rental.rps, cfg.limiter.rental.burst = 0.2, 3
+	cfg.limiter.ip.rps, cfg.limiter.ip.burst = 50, 200
+	return cfg
+}
+
+func TestValidateLimiterConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *config)
+		valid  bool
+	}{
+		{"valid", func(cfg *config) {}, true},
+		{"zero default rps", func(cfg *config) { cfg.limiter.rps = 0 }, false},
+		{"zero write burst", func(cfg *config) { cfg.limiter.write.burst = 0 }, false},
+		{"negative rental rps", func(cfg *config) { cfg.limiter.rental.rps = -1 }, false},
+		{"zero ip rps", func(cfg *config) { cfg.limiter.ip.rps = 0 }, false},
+		{"zero ip burst", func(cfg *config) { cfg.limiter.ip.burst = 0 }, false},
+		{"disabled", func(cfg *config) { cfg.limiter.enabled, cfg.limiter.ip.rps = false, 0 }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validLimiterConfig()
+			tt.modify(&cfg)
+
+			err := validateLimiterConfig(cfg)
+			if (err == nil) != tt.valid {
+				t.Errorf("expected valid=%t, got error %v", tt.valid, err)
+			}
+		})
+	}
+}
+
+// testLimiterBurst проверяет, что корзина пропускает burst запросов, затем
+// отказывает, и что корзины разных ключей независимы.
+func testLimiterBurst(t *testing.T, limiter rateLimiter, key string) {
+	t.Helper()
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		allowed, err := limiter.Allow(ctx, key, 0.01, 3)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !allowed {
+			t.Fatalf("request %d within the burst was rejected", i+1)
+		}
+	}
+
+	allowed, err := limiter.Allow(ctx, key, 0.01, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if allowed {
+		t.Errorf("request over the burst was allowed")
+	}
+
+	allowed, err = limiter.Allow(ctx, key+":other", 0.01, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !allowed {
+		t.Errorf("another key must have its own bucket")
+	}
+}
+
+func TestMemoryLimiter_Allow(t *testing.T) {
+	testLimiterBurst(t, newMemoryLimiter(), "test")
+}
+
+// Скрипт корзины выполняется только настоящим Redis, поэтому тест
+// запускается, если задан REDIS_TEST_ADDR.
+func TestRedisLimiter_Allow(t *testing.T) {
+	addr := os.Getenv("REDIS_TEST_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_TEST_ADDR is not set")
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	defer client.Close()
+
+	key := fmt.Sprintf("test:%d", time.Now().UnixNano())
+	defer client.Del(context.Background(), "ratelimit:"+key, "ratelimit:"+key+":other")
+
+	limiter := &redisLimiter{client: client}
+	testLimiterBurst(t, limiter, key)
+
+	ttl, err := client.PTTL(context.Background(), "ratelimit:"+key).Result()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ttl <= 0 {
+		t.Errorf("expected the bucket to expire, got TTL %s", ttl)
+	}
+}
+
+// Класс квоты задаётся при регистрации маршрута: начало аренды расходует
+// свою корзину, а остальные изменяющие запросы того же клиента — свою.
+func TestRoutes_RateLimitClass(t *testing.T) {
+	app := &application{config: validLimiterConfig(), limiter: newMemoryLimiter()}
+	handler := app.routes()
+
+	serve := func(method, path string) int {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w.Code
+	}
+
+	for i := 0; i < app.config.limiter.rental.burst; i++ {
+		if code := serve(http.MethodPost, "/v1/rentals"); code != http.StatusUnauthorized {
+			t.Fatalf("request %d: expected 401 from the handler, got %d", i+1, code)
+		}
+	}
+	if code := serve(http.MethodPost, "/v1/rentals"); code != http.StatusTooManyRequests {
+		t.Errorf("expected the rental quota to be exhausted, got %d", code)
+	}
+	if code := serve(http.MethodPost, "/v1/rentals/1/refund"); code != http.StatusUnauthorized {
+		t.Errorf("expected other writes to use the write quota, got %d", code)
+	}
+}
@@ -1,17 +1,40 @@
 package main
 
 import (
+	"crypto/rand"
+	"errors"
 	"expvar"
 	"fmt"
 	"github.com/felixge/httpsnoop"
+	"github.com/olzzhas/qrent/internal/data"
+	"github.com/olzzhas/qrent/pkg/validator"
 	"github.com/tomasen/realip"
-	"golang.org/x/time/rate"
+	"mime"
 	"net/http"
+	"regexp"
 	"strconv"
-	"sync"
-	"time"
+	"strings"
 )
 
+// requestIDRX — допустимый клиентский X-Request-ID: без пробелов и управляющих
+// символов, чтобы его можно было безопасно писать в логи и заголовки.
+var requestIDRX = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// requestID присваивает запросу идентификатор: берёт X-Request-ID клиента,
+// если он корректен, иначе генерирует новый. Идентификатор возвращается в
+// заголовке ответа и попадает в лог ошибок и журнал аудита.
+func (app *application) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !requestIDRX.MatchString(id) {
+			id = rand.Text()
+		}
+
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, app.contextSetRequestID(r, id))
+	})
+}
+
 func (app *application) recoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
@@ -24,55 +47,193 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 	})
 }
 
-// TODO сейчас пока нет юзеров, в дальнейшем можно ставить лимиты не только по ip, а по токену юзера
-func (app *application) rateLimit(next http.Handler) http.Handler {
-	type client struct {
-		limiter  *rate.Limiter
-		lastSeen time.Time
+// limiterClass — класс маршрутов со своей квотой запросов. Класс задаётся
+// при регистрации маршрута в routes.go.
+type limiterClass string
+
+const (
+	limiterClassRead   limiterClass = "read"
+	limiterClassWrite  limiterClass = "write"
+	limiterClassRental limiterClass = "rental"
+)
+
+func (app *application) limitFor(class limiterClass) (float64, int) {
+	switch class {
+	case limiterClassRental:
+		return app.config.limiter.rental.rps, app.config.limiter.rental.burst
+	case limiterClassWrite:
+		return app.config.limiter.write.rps, app.config.limiter.write.burst
+	default:
+		return app.config.limiter.rps, app.config.limiter.burst
+	}
+}
+
+// rateLimitKey возвращает ключ корзины: аутентифицированные пользователи
+// ограничиваются по id, анонимные — по IP. Должен вызываться после authenticate.
+func (app *application) rateLimitKey(r *http.Request, class limiterClass) string {
+	user := app.contextGetUser(r)
+	if !user.IsAnonymous() {
+		return fmt.Sprintf("%s:user:%d", class, user.ID)
 	}
-	var (
-		mu      sync.Mutex
-		clients = make(map[string]*client)
-	)
 
-	go func() {
-		for {
-			time.Sleep(time.Minute)
+	return fmt.Sprintf("%s:ip:%s", class, realip.FromRequest(r))
+}
+
+// rateLimitIP ограничивает все запросы с одного IP общей квотой ещё до
+// authenticate: иначе перебор токенов и поток запросов с поддельным токеном
+// доходили бы до базы без всякого лимита. Квота рассчитана на несколько
+// пользователей за одним NAT.
+func (app *application) rateLimitIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.config.limiter.enabled {
+			key := "ip:" + realip.FromRequest(r)
+			if !app.allowRequest(w, r, key, app.config.limiter.ip.rps, app.config.limiter.ip.burst) {
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
 
-			mu.Lock()
+// rateLimit применяет квоту класса class к пользователю или, для анонимных
+// запросов, к IP. Оборачивает обработчик маршрута, поэтому выполняется после
+// authenticate.
+func (app *application) rateLimit(class limiterClass, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if app.config.limiter.enabled {
+			rps, burst := app.limitFor(class)
 
-			for ip, client := range clients {
-				if time.Since(client.lastSeen) > 3*time.Minute {
-					delete(clients, ip)
-				}
+			if !app.allowRequest(w, r, app.rateLimitKey(r, class), rps, burst) {
+				return
 			}
-			mu.Unlock()
 		}
-	}()
+		next(w, r)
+	}
+}
 
+// allowRequest списывает запрос из корзины key и отвечает 429, если она
+// пуста. При недоступности хранилища лимитов запрос намеренно пропускается,
+// а ошибка пишется в лог: сбой Redis не должен останавливать весь API.
+// Ошибки конфигурации сюда не доходят — квоты проверяются при запуске.
+func (app *application) allowRequest(w http.ResponseWriter, r *http.Request, key string, rps float64, burst int) bool {
+	allowed, err := app.limiter.Allow(r.Context(), key, rps, burst)
+	if err != nil {
+		app.logError(r, err)
+		return true
+	}
+	if !allowed {
+		app.rateLimitExceededResponse(w, r)
+		return false
+	}
+	return true
+}
+
+// authenticate кладёт в контекст запроса пользователя по bearer-токену
+// из заголовка Authorization либо AnonymousUser, если заголовка нет.
+func (app *application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if app.config.limiter.enabled {
-			ip := realip.FromRequest(r)
+		w.Header().Add("Vary", "Authorization")
 
-			mu.Lock()
-			if _, found := clients[ip]; !found {
-				clients[ip] = &client{
+		authorizationHeader := r.Header.Get("Authorization")
+		if authorizationHeader == "" {
+			r = app.contextSetUser(r, data.AnonymousUser)
+			next.ServeHTTP(w, r)
+			return
+		}
 
-					limiter: rate.NewLimiter(rate.Limit(app.config.limiter.rps), app.config.limiter.burst),
-				}
-			}
-			clients[ip].lastSeen = time.Now()
-			if !clients[ip].limiter.Allow() {
-				mu.Unlock()
-				app.rateLimitExceededResponse(w, r)
-				return
+		headerParts := strings.Split(authorizationHeader, " ")
+		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
+		token := headerParts[1]
+
+		v := validator.New()
+		if data.ValidateTokenPlaintext(v, token); !v.Valid() {
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
+		user, err := app.models.User.GetForToken(data.ScopeAuthentication, token)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.invalidAuthenticationTokenResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
 			}
-			mu.Unlock()
+			return
 		}
+
+		r = app.contextSetUser(r, user)
 		next.ServeHTTP(w, r)
 	})
 }
 
+func (app *application) requireAuthenticatedUser(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+
+		if user.IsAnonymous() {
+			app.authenticationRequiredResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+func (app *application) requireActivatedUser(next http.HandlerFunc) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+
+		if !user.Activated {
+			app.inactiveAccountResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+
+	return app.requireAuthenticatedUser(fn)
+}
+
+func (app *application) requirePermission(code string, next http.HandlerFunc) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+
+		permissions, err := app.models.Permission.GetAllForUser(user.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !permissions.Include(code) {
+			app.notPermittedResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+
+	return app.requireActivatedUser(fn)
+}
+
+// requireMergePatch пропускает к обработчику PATCH только тела в формате
+// JSON Merge Patch.
+func (app *application) requireMergePatch(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType != mergePatchMediaType {
+			app.unsupportedMediaTypeResponse(w, r, mergePatchMediaType)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
 func (app *application) metrics(next http.Handler) http.Handler {
 	totalRequestsReceived := expvar.NewInt("total_requests_received")
 	totalResponsesSent := expvar.NewInt("total_responses_sent")
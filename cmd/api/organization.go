@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"github.com/olzzhas/qrent/internal/data"
 	"github.com/olzzhas/qrent/pkg/validator"
 	"net/http"
@@ -8,14 +9,17 @@ import (
 
 // GetOrganizationHandler godoc
 // @Summary Получает организацию по ID
-// @Description Возвращает организацию по переданному идентификатору
+// @Description Возвращает организацию по переданному идентификатору. ETag ответа — версия организации для If-Match в PUT
 // @Tags organizations
 // @Accept  json
 // @Produce  json
 // @Param id path int true "Organization ID"
+// @Param include_deleted query bool false "Вернуть и мягко удалённую организацию (только для администраторов)"
 // @Success 200 {object} OrganizationResponse
+// @Header 200 {string} ETag "Версия организации"
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 422 {object} map[string]string
 // @Router /organizations/{id} [get]
 func (app *application) GetOrganizationHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
@@ -24,14 +28,26 @@ func (app *application) GetOrganizationHandler(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	org, err := app.models.Organization.Get(int(id))
+	v := validator.New()
+	includeDeleted := app.readIncludeDeleted(r, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	get := app.models.Organization.Get
+	if includeDeleted {
+		get = app.models.Organization.GetWithDeleted
+	}
+
+	org, err := get(int(id))
 	if err != nil {
 		app.notFoundResponse(w, r)
 		return
 	}
 
 	env := envelope{"organization": org}
-	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+	if err := app.writeJSON(w, http.StatusOK, env, app.etagHeader(org.Version)); err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
@@ -43,9 +59,13 @@ func (app *application) GetOrganizationHandler(w http.ResponseWriter, r *http.Re
 // @Accept json
 // @Produce json
 // @Param organization body CreateOrganizationRequest true "Organization Data"
+// @Param Idempotency-Key header string false "Ключ идемпотентности: повтор с тем же ключом вернёт исходный ответ"
 // @Success 201 {object} OrganizationResponse
 // @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 422 {object} map[string]string
+// @Security BearerAuth
 // @Router /organizations [post]
 func (app *application) CreateOrganizationHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
@@ -70,7 +90,7 @@ func (app *application) CreateOrganizationHandler(w http.ResponseWriter, r *http
 		return
 	}
 
-	if err := app.models.Organization.Insert(org); err != nil {
+	if err := app.models.Organization.Insert(org, app.auditFor(r, nil)); err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
@@ -82,19 +102,80 @@ func (app *application) CreateOrganizationHandler(w http.ResponseWriter, r *http
 }
 
 // UpdateOrganizationHandler godoc
-// @Summary Обновляет организацию по ID
-// @Description Обновляет данные организации. Обновляются только переданные поля.
+// @Summary Заменяет организацию по ID
+// @Description Заменяет данные организации целиком: тело должно содержать все поля. Для частичного обновления используйте PATCH. С If-Match обновление пройдёт, только если версия не изменилась
 // @Tags organizations
 // @Accept json
 // @Produce json
 // @Param id path int true "Organization ID"
+// @Param If-Match header string false "ETag из GET /organizations/{id}"
 // @Param organization body UpdateOrganizationRequest true "Organization Data"
 // @Success 200 {object} OrganizationResponse
+// @Header 200 {string} ETag "Новая версия организации"
 // @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 412 {object} map[string]string
 // @Failure 422 {object} map[string]string
+// @Security BearerAuth
 // @Router /organizations/{id} [put]
 func (app *application) UpdateOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	app.updateOrganization(w, r, func(org *data.Organization, v *validator.Validator) error {
+		var input struct {
+			Name     string `json:"name"`
+			Location string `json:"location"`
+		}
+
+		if err := app.readJSON(w, r, &input); err != nil {
+			return err
+		}
+
+		org.Name = input.Name
+		org.Location = input.Location
+		return nil
+	})
+}
+
+// PatchOrganizationHandler godoc
+// @Summary Частично обновляет организацию по ID
+// @Description Применяет JSON Merge Patch (RFC 7396): меняются только переданные поля, null сбрасывает поле. Ошибки возвращаются по полям. С If-Match обновление пройдёт, только если версия не изменилась
+// @Tags organizations
+// @Accept application/merge-patch+json
+// @Produce json
+// @Param id path int true "Organization ID"
+// @Param If-Match header string false "ETag из GET /organizations/{id}"
+// @Param organization body PatchOrganizationRequest true "Changed fields"
+// @Success 200 {object} OrganizationResponse
+// @Header 200 {string} ETag "Новая версия организации"
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Security BearerAuth
+// @Router /organizations/{id} [patch]
+func (app *application) PatchOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	app.updateOrganization(w, r, func(org *data.Organization, v *validator.Validator) error {
+		patch, err := app.readMergePatch(w, r, v, "name", "location")
+		if err != nil {
+			return err
+		}
+
+		patchField(patch, "name", &org.Name, v)
+		patchField(patch, "location", &org.Location, v)
+		return nil
+	})
+}
+
+// updateOrganization — общая часть PUT и PATCH: apply переносит тело запроса
+// в организацию, ошибка apply означает неразборчивое тело, а ошибки полей
+// apply добавляет в v.
+func (app *application) updateOrganization(w http.ResponseWriter, r *http.Request, apply func(*data.Organization, *validator.Validator) error) {
 	id, err := app.readIDParam(r)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
@@ -107,51 +188,54 @@ func (app *application) UpdateOrganizationHandler(w http.ResponseWriter, r *http
 		return
 	}
 
-	var input struct {
-		Name     *string `json:"name"`
-		Location *string `json:"location"`
+	if !app.checkIfMatch(r, org.Version) {
+		app.preconditionFailedResponse(w, r)
+		return
 	}
 
-	if err := app.readJSON(w, r, &input); err != nil {
+	before := *org
+
+	v := validator.New()
+	if err := apply(org, v); err != nil {
 		app.badRequestResponse(w, r, err)
 		return
 	}
 
-	if input.Name != nil {
-		org.Name = *input.Name
-	}
-	if input.Location != nil {
-		org.Location = *input.Location
-	}
-
-	v := validator.New()
 	data.ValidateOrganization(v, org)
 	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	if err := app.models.Organization.Update(org); err != nil {
-		app.serverErrorResponse(w, r, err)
+	if err := app.models.Organization.Update(org, app.auditFor(r, &before)); err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
 	env := envelope{"organization": org}
-	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+	if err := app.writeJSON(w, http.StatusOK, env, app.etagHeader(org.Version)); err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
 // DeleteOrganizationHandler godoc
 // @Summary Удаляет организацию по ID
-// @Description Удаляет организацию с заданным идентификатором
+// @Description Мягко удаляет организацию вместе с её станциями и повербанками на них, кроме выданных в аренду. Восстанавливается через POST /organizations/{id}/restore
 // @Tags organizations
 // @Accept json
 // @Produce json
 // @Param id path int true "Organization ID"
 // @Success 200 {object} MessageResponse
 // @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Security BearerAuth
 // @Router /organizations/{id} [delete]
 func (app *application) DeleteOrganizationHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
@@ -160,35 +244,281 @@ func (app *application) DeleteOrganizationHandler(w http.ResponseWriter, r *http
 		return
 	}
 
-	if err := app.models.Organization.Delete(int(id)); err != nil {
+	org, err := app.models.Organization.Get(int(id))
+	if err != nil {
 		app.notFoundResponse(w, r)
 		return
 	}
 
+	if err := app.models.Organization.Delete(org.ID, app.auditFor(r, org)); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
 	env := envelope{"message": "organization successfully deleted"}
 	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
+// RestoreOrganizationHandler godoc
+// @Summary Восстанавливает удалённую организацию
+// @Description Восстанавливает мягко удалённую организацию вместе со станциями и повербанками, удалёнными вместе с ней
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param id path int true "Organization ID"
+// @Success 200 {object} OrganizationResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /organizations/{id}/restore [post]
+func (app *application) RestoreOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if !app.contextGetUser(r).CanManageOrganization(int(id)) {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	org, err := app.models.Organization.Restore(int(id), app.auditFor(r, nil))
+	if err != nil {
+		app.restoreErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{"organization": org}
+	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
 // ListOrganizationHandler godoc
 // @Summary Возвращает список организаций
-// @Description Возвращает все организации
+// @Description Возвращает страницу организаций с полнотекстовым поиском по названию
 // @Tags organizations
 // @Accept json
 // @Produce json
+// @Param name query string false "Полнотекстовый поиск по названию"
+// @Param include_deleted query bool false "Включить мягко удалённые организации (только для администраторов)"
+// @Param page query int false "Номер страницы" default(1)
+// @Param page_size query int false "Размер страницы (до 100)" default(20)
+// @Param sort query string false "Сортировка: id, name, created_at; префикс - для убывания" default(name)
 // @Success 200 {object} OrganizationListResponse
+// @Failure 422 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /organizations [get]
 func (app *application) ListOrganizationHandler(w http.ResponseWriter, r *http.Request) {
-	orgs, err := app.models.Organization.List()
+	var input struct {
+		Name           string
+		IncludeDeleted bool
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Name = app.readString(qs, "name", "")
+	input.IncludeDeleted = app.readIncludeDeleted(r, v)
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "name")
+	input.Filters.SortSafelist = []string{"id", "name", "created_at", "-id", "-name", "-created_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	orgs, metadata, err := app.models.Organization.List(input.Name, input.IncludeDeleted, input.Filters)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	env := envelope{"organizations": orgs}
+	env := envelope{"organizations": orgs, "metadata": metadata}
 	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+// GetOrganizationRebalancePlanHandler godoc
+// @Summary Предлагает перебалансировку станций организации
+// @Description Жадно подбирает переносы повербанков между станциями организации, чтобы каждая станция приблизилась к целевой заполненности target (доля ёмкости). Переносятся только повербанки available и charging; пары станций перебираются от ближайших к дальним. План можно выполнить через POST /stations/{id}/transfer
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param id path int true "Organization ID"
+// @Param target query number false "Целевая заполненность станций от 0 до 1" default(0.5)
+// @Success 200 {object} RebalancePlanResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Security BearerAuth
+// @Router /organizations/{id}/rebalance-plan [get]
+func (app *application) GetOrganizationRebalancePlanHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	org, err := app.models.Organization.Get(int(id))
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	if !app.contextGetUser(r).CanManageOrganization(org.ID) {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	target := app.readFloat(r.URL.Query(), "target", 0.5, v)
+	v.Check(target >= 0 && target <= 1, "target", "must be between 0 and 1")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	stocks, err := app.models.Station.Stock(org.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{"rebalance_plan": data.PlanRebalance(stocks, target)}
+	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// ListOrganizationStationsHandler godoc
+// @Summary Возвращает станции организации
+// @Description Возвращает страницу станций организации вместе с их доступностью. Для несуществующей организации возвращает 404
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param id path int true "Organization ID"
+// @Param include_deleted query bool false "Включить мягко удалённые станции и организацию (только для администраторов)"
+// @Param page query int false "Номер страницы" default(1)
+// @Param page_size query int false "Размер страницы (до 100)" default(20)
+// @Param sort query string false "Сортировка: id, org_id, created_at; префикс - для убывания" default(id)
+// @Success 200 {object} StationListResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /organizations/{id}/stations [get]
+func (app *application) ListOrganizationStationsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	includeDeleted := app.readIncludeDeleted(r, v)
+	filters := app.readStationFilters(qs, v)
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	org, err := app.getOrganization(int(id), includeDeleted)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	stations, metadata, err := app.models.Station.List(org.ID, includeDeleted, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{"stations": stations, "metadata": metadata}
+	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// ListOrganizationPowerbanksHandler godoc
+// @Summary Возвращает повербанки организации
+// @Description Возвращает страницу повербанков, числящихся за станциями организации, с фильтром по статусу. Для несуществующей организации возвращает 404
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param id path int true "Organization ID"
+// @Param status query string false "Статусы через запятую: rented, available, charging, lost, maintenance"
+// @Param include_deleted query bool false "Включить мягко удалённые повербанки и организацию (только для администраторов)"
+// @Param page query int false "Номер страницы" default(1)
+// @Param page_size query int false "Размер страницы (до 100)" default(20)
+// @Param sort query string false "Сортировка: id, status, current_station_id, created_at; префикс - для убывания" default(id)
+// @Success 200 {object} PowerbankListResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /organizations/{id}/powerbanks [get]
+func (app *application) ListOrganizationPowerbanksHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	statuses := app.readPowerbankStatuses(qs, v)
+	includeDeleted := app.readIncludeDeleted(r, v)
+	filters := app.readPowerbankFilters(qs, v)
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	org, err := app.getOrganization(int(id), includeDeleted)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	powerbanks, metadata, err := app.models.Powerbank.ListForOrganization(org.ID, statuses, includeDeleted, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{"powerbanks": powerbanks, "metadata": metadata}
+	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// getOrganization возвращает организацию-родителя вложенного списка. Мягко
+// удалённая организация находится, только если запрошены удалённые записи.
+func (app *application) getOrganization(id int, includeDeleted bool) (*data.Organization, error) {
+	if includeDeleted {
+		return app.models.Organization.GetWithDeleted(id)
+	}
+	return app.models.Organization.Get(id)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/olzzhas/qrent/internal/data"
+	"github.com/olzzhas/qrent/pkg/validator"
+)
+
+// monitorOverdueRentals раз в rentals.checkInterval помечает просроченные
+// аренды, закрывает как потерю те, что не вернули после rentals.lostAfter, и
+// повторяет расчёт по закрытым арендам, списание по которым не прошло.
+// Работает до остановки сервера.
+func (app *application) monitorOverdueRentals() {
+	ticker := time.NewTicker(app.config.rentals.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-app.shutdown:
+			return
+		case <-ticker.C:
+			app.processOverdueRentals()
+		}
+	}
+}
+
+func (app *application) processOverdueRentals() {
+	overdue, err := app.models.Rental.MarkOverdue(app.config.rentals.overdueAfter)
+	if err != nil {
+		app.logger.PrintError(err, nil, "general")
+		return
+	}
+	if overdue > 0 {
+		app.logger.PrintInfo("rentals marked overdue", map[string]any{"count": overdue}, "general")
+	}
+
+	ids, err := app.models.Rental.OverdueSince(app.config.rentals.lostAfter)
+	if err != nil {
+		app.logger.PrintError(err, nil, "general")
+		return
+	}
+
+	for _, id := range ids {
+		if err := app.markRentalLost(id); err != nil {
+			app.logger.PrintError(err, map[string]any{"rental_id": id}, "general")
+		}
+	}
+
+	app.retrySettlements()
+}
+
+// retrySettlements повторяет расчёт по закрытым арендам без отметки о нём.
+// Аренды, закрытые меньше интервала проверки назад, ещё может рассчитывать
+// обработчик возврата, поэтому они ждут следующего прохода.
+func (app *application) retrySettlements() {
+	ids, err := app.models.Rental.Unsettled(app.config.rentals.checkInterval)
+	if err != nil {
+		app.logger.PrintError(err, nil, "general")
+		return
+	}
+
+	for _, id := range ids {
+		rental, err := app.models.Rental.Get(id)
+		if err == nil {
+			err = app.settleRental(rental)
+		}
+		if err != nil {
+			app.logger.PrintError(err, map[string]any{"rental_id": id}, "general")
+		}
+	}
+}
+
+// markRentalLost закрывает аренду как потерю и списывает штраф из депозита.
+// Аренду, которую успели вернуть между выборкой и обработкой, пропускает. Если
+// списание не прошло, аренда остаётся нерассчитанной и его повторит
+// retrySettlements.
+func (app *application) markRentalLost(id int) error {
+	rental, err := app.models.Rental.Get(id)
+	if err != nil {
+		return err
+	}
+
+	if err := app.models.Rental.MarkLost(rental); err != nil {
+		if errors.Is(err, data.ErrRentalNotOverdue) {
+			return nil
+		}
+		return err
+	}
+
+	app.logger.PrintInfo("rental marked lost", map[string]any{
+		"rental_id":    rental.ID,
+		"powerbank_id": rental.PowerbankID,
+	}, "general")
+
+	return app.settleRental(rental)
+}
+
+// ListOverdueRentalsHandler godoc
+// @Summary Возвращает просроченные аренды организации
+// @Description Возвращает страницу аренд в статусе overdue, начатых на станциях организации
+// @Tags rentals
+// @Accept json
+// @Produce json
+// @Param id path int true "Organization ID"
+// @Param page query int false "Номер страницы" default(1)
+// @Param page_size query int false "Размер страницы (до 100)" default(20)
+// @Param sort query string false "Сортировка: id, started_at; префикс - для убывания" default(started_at)
+// @Success 200 {object} RentalListResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Security BearerAuth
+// @Router /organizations/{id}/overdue-rentals [get]
+func (app *application) ListOverdueRentalsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	org, err := app.models.Organization.Get(int(id))
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	if !app.contextGetUser(r).CanManageOrganization(org.ID) {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	filters := data.Filters{
+		Page:         app.readInt(qs, "page", 1, v),
+		PageSize:     app.readInt(qs, "page_size", 20, v),
+		Sort:         app.readString(qs, "sort", "started_at"),
+		SortSafelist: []string{"id", "started_at", "-id", "-started_at"},
+	}
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	rentals, metadata, err := app.models.Rental.ListOverdue(org.ID, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{"rentals": rentals, "metadata": metadata}
+	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
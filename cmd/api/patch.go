@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"slices"
+
+	"github.com/olzzhas/qrent/pkg/validator"
+)
+
+const mergePatchMediaType = "application/merge-patch+json"
+
+// mergePatch — тело запроса PATCH в формате JSON Merge Patch (RFC 7396):
+// переданные ключи заменяют поля ресурса, null сбрасывает поле, отсутствующие
+// ключи ничего не меняют.
+type mergePatch map[string]json.RawMessage
+
+// readMergePatch читает тело PATCH. Ключи не из allowed не ломают разбор
+// остальных: каждый из них становится ошибкой своего поля в v.
+func (app *application) readMergePatch(w http.ResponseWriter, r *http.Request, v *validator.Validator, allowed ...string) (mergePatch, error) {
+	var patch mergePatch
+	if err := app.readJSON(w, r, &patch); err != nil {
+		return nil, err
+	}
+
+	for key := range patch {
+		if !slices.Contains(allowed, key) {
+			v.AddError(key, "is not a known field")
+		}
+	}
+
+	return patch, nil
+}
+
+// patchField переносит значение ключа key в dst. null записывает нулевое
+// значение: у указателей это nil, у обязательных полей — значение, которое
+// затем не пройдёт валидацию ресурса. Значение неверного типа становится
+// ошибкой поля key, а dst остаётся прежним.
+func patchField[T any](patch mergePatch, key string, dst *T, v *validator.Validator) {
+	raw, ok := patch[key]
+	if !ok {
+		return
+	}
+
+	var value T
+	if err := json.Unmarshal(raw, &value); err != nil {
+		v.AddError(key, "must be "+jsonTypeName(reflect.TypeOf(value)))
+		return
+	}
+
+	*dst = value
+}
+
+// jsonTypeName описывает ожидаемый JSON-тип поля для сообщений об ошибках.
+func jsonTypeName(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Bool:
+		return "a boolean"
+	default:
+		return "a valid JSON value"
+	}
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/olzzhas/qrent/internal/data"
+	"github.com/olzzhas/qrent/internal/payment"
+	"github.com/olzzhas/qrent/pkg/validator"
+)
+
+const paymentProviderFake = "fake"
+
+func newPaymentProvider(name string) (payment.Provider, error) {
+	switch name {
+	case paymentProviderFake:
+		return payment.NewFake(), nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q", name)
+	}
+}
+
+// runPayment записывает попытку платежа p и выполняет call у провайдера.
+// Если попытка с тем же ключом идемпотентности уже прошла успешно, провайдер
+// не вызывается повторно и p заполняется сохранённым результатом. С непустым
+// audit результат платежа пишется в журнал аудита.
+func (app *application) runPayment(p *data.Payment, audit *data.Audit, call func(ctx context.Context) (string, error)) error {
+	p.Provider = app.payments.Name()
+
+	created, err := app.models.Payment.Begin(p)
+	if err != nil {
+		return err
+	}
+	if !created && p.Status == data.PaymentStatusSucceeded {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ref, callErr := call(ctx)
+	if callErr != nil {
+		message := callErr.Error()
+		p.Status, p.ProviderRef, p.Error = data.PaymentStatusFailed, nil, &message
+	} else {
+		p.Status, p.ProviderRef, p.Error = data.PaymentStatusSucceeded, &ref, nil
+	}
+
+	if err := app.models.Payment.Complete(p, audit); err != nil {
+		return err
+	}
+
+	return callErr
+}
+
+// authorizeDeposit замораживает депозит в размере штрафа за потерю по тарифу
+// станции выдачи. Аренды ещё нет, поэтому ключ идемпотентности случайный, а
+// связь с арендой пишется в транзакции её создания.
+func (app *application) authorizeDeposit(tariff *data.Tariff) (*data.Payment, error) {
+	p := &data.Payment{
+		Kind:           data.PaymentKindAuthorize,
+		Amount:         tariff.LostPenalty,
+		Currency:       tariff.Currency,
+		IdempotencyKey: "deposit:" + rand.Text(),
+	}
+
+	err := app.runPayment(p, nil, func(ctx context.Context) (string, error) {
+		return app.payments.Authorize(ctx, p.IdempotencyKey, p.Amount, p.Currency)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// voidPayment снимает заморозку auth.
+func (app *application) voidPayment(auth *data.Payment, key string) error {
+	p := &data.Payment{
+		RentalID:        auth.RentalID,
+		AuthorizationID: &auth.ID,
+		Kind:            data.PaymentKindVoid,
+		Amount:          auth.Amount,
+		Currency:        auth.Currency,
+		IdempotencyKey:  key,
+	}
+
+	return app.runPayment(p, nil, func(ctx context.Context) (string, error) {
+		return app.payments.Void(ctx, p.IdempotencyKey, *auth.ProviderRef)
+	})
+}
+
+// settleRental рассчитывается по закрытой аренде и отмечает её
+// рассчитанной. Ключи идемпотентности строятся по аренде, поэтому повторный
+// вызов для той же аренды не спишет деньги второй раз.
+func (app *application) settleRental(rental *data.Rental) error {
+	if err := app.chargeRental(rental); err != nil {
+		return err
+	}
+
+	return app.models.Rental.MarkSettled(rental.ID)
+}
+
+// chargeRental списывает стоимость аренды. Списание идёт из депозита; если
+// депозита не было, сумма сначала замораживается. Стоимость сверх депозита
+// замораживается и списывается отдельно. Нулевая стоимость снимает заморозку.
+func (app *application) chargeRental(rental *data.Rental) error {
+	var price int64
+	if rental.Price != nil {
+		price = *rental.Price
+	}
+
+	auth, err := app.models.Payment.FirstSucceeded(rental.ID, data.PaymentKindAuthorize)
+	switch {
+	case err == nil:
+	case errors.Is(err, data.ErrRecordNotFound):
+		if price == 0 {
+			return nil
+		}
+
+		auth, err = app.authorizeRental(rental, "authorize", price)
+		if err != nil {
+			return err
+		}
+	default:
+		return err
+	}
+
+	if price == 0 {
+		key, err := app.rentalPaymentKey(rental.ID, "void")
+		if err != nil {
+			return err
+		}
+		return app.voidPayment(auth, key)
+	}
+
+	amount := min(price, auth.Amount)
+	if err := app.captureRental(rental, "capture", auth, amount); err != nil {
+		return err
+	}
+
+	shortfall := price - amount
+	if shortfall == 0 {
+		return nil
+	}
+
+	extra, err := app.authorizeRental(rental, "shortfall-authorize", shortfall)
+	if err != nil {
+		return err
+	}
+
+	return app.captureRental(rental, "shortfall-capture", extra, shortfall)
+}
+
+// rentalPaymentKey возвращает ключ идемпотентности шага step расчёта по
+// аренде. Провайдер на повтор с тем же ключом возвращает прежний результат,
+// в том числе отказ, поэтому после каждой неудачной попытки шаг получает
+// новый номер, а успешная попытка сохраняет свой ключ и не повторяется.
+func (app *application) rentalPaymentKey(rentalID int, step string) (string, error) {
+	prefix := fmt.Sprintf("rental:%d:%s:", rentalID, step)
+
+	failed, err := app.models.Payment.CountFailed(prefix)
+	if err != nil {
+		return "", err
+	}
+
+	return prefix + strconv.Itoa(failed), nil
+}
+
+// authorizeRental замораживает amount по аренде на шаге step расчёта.
+func (app *application) authorizeRental(rental *data.Rental, step string, amount int64) (*data.Payment, error) {
+	key, err := app.rentalPaymentKey(rental.ID, step)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &data.Payment{
+		RentalID:       &rental.ID,
+		Kind:           data.PaymentKindAuthorize,
+		Amount:         amount,
+		Currency:       *rental.Currency,
+		IdempotencyKey: key,
+	}
+
+	err = app.runPayment(p, nil, func(ctx context.Context) (string, error) {
+		return app.payments.Authorize(ctx, p.IdempotencyKey, p.Amount, p.Currency)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// captureRental списывает amount из заморозки auth на шаге step расчёта.
+func (app *application) captureRental(rental *data.Rental, step string, auth *data.Payment, amount int64) error {
+	key, err := app.rentalPaymentKey(rental.ID, step)
+	if err != nil {
+		return err
+	}
+
+	p := &data.Payment{
+		RentalID:        &rental.ID,
+		AuthorizationID: &auth.ID,
+		Kind:            data.PaymentKindCapture,
+		Amount:          amount,
+		Currency:        auth.Currency,
+		IdempotencyKey:  key,
+	}
+
+	return app.runPayment(p, nil, func(ctx context.Context) (string, error) {
+		return app.payments.Capture(ctx, p.IdempotencyKey, *auth.ProviderRef, p.Amount)
+	})
+}
+
+// refundPart — часть возврата, которая возвращается из списания по одной
+// заморозке.
+type refundPart struct {
+	capture *data.RefundableCapture
+	amount  int64
+}
+
+// planRefund делит amount между списаниями captures по порядку: сначала
+// возвращается остаток первого списания, затем следующего. Если amount
+// больше всего, что можно вернуть, возвращается payment.ErrAmountExceeded.
+func planRefund(captures []*data.RefundableCapture, amount int64) ([]refundPart, error) {
+	var parts []refundPart
+	for _, c := range captures {
+		if amount == 0 {
+			break
+		}
+		if c.Amount <= 0 {
+			continue
+		}
+
+		part := min(amount, c.Amount)
+		parts = append(parts, refundPart{capture: c, amount: part})
+		amount -= part
+	}
+
+	if amount > 0 {
+		return nil, payment.ErrAmountExceeded
+	}
+
+	return parts, nil
+}
+
+// ListRentalPaymentsHandler godoc
+// @Summary Возвращает платежи по аренде
+// @Description Возвращает все попытки операций у платёжного провайдера по аренде: заморозку депозита, списание, возвраты
+// @Tags rentals
+// @Accept json
+// @Produce json
+// @Param id path int true "Rental ID"
+// @Success 200 {object} PaymentListResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /rentals/{id}/payments [get]
+func (app *application) ListRentalPaymentsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	rental, err := app.models.Rental.Get(int(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if user := app.contextGetUser(r); !rental.IsOwnedBy(user) && user.Role != data.RoleAdmin {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	payments, err := app.models.Payment.ListForRental(rental.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{"payments": payments}
+	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// RefundRentalHandler godoc
+// @Summary Возвращает деньги за аренду
+// @Description Возвращает часть или всю списанную за аренду сумму, не больше списанного за вычетом прошлых возвратов. Если стоимость превысила депозит, деньги списаны из двух заморозок, и возврат делится между ними: сначала депозит, затем доплата; в ответе — по операции возврата на каждую. Требует права payments:refund. Повтор с тем же Idempotency-Key не возвращает деньги второй раз, а каждый новый ключ — отдельный возврат
+// @Tags rentals
+// @Accept json
+// @Produce json
+// @Param id path int true "Rental ID"
+// @Param refund body RefundRequest true "Refund Data"
+// @Param Idempotency-Key header string true "Ключ идемпотентности: повтор с тем же ключом вернёт исходный ответ"
+// @Success 200 {object} PaymentListResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 422 {object} map[string]string
+// @Security BearerAuth
+// @Router /rentals/{id}/refund [post]
+func (app *application) RefundRentalHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Ключ запроса отличает второй возврат той же суммы от повтора первого.
+	key := r.Header.Get("Idempotency-Key")
+	if key == "" {
+		app.badRequestResponse(w, r, errors.New("Idempotency-Key header is required"))
+		return
+	}
+
+	var input struct {
+		Amount int64 `json:"amount"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.Amount > 0, "amount", "must be greater than zero")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	prefix := fmt.Sprintf("rental:%d:refund:%d:%s:", id, app.contextGetUser(r).ID, key)
+
+	captures, err := app.models.Payment.Refundable(int(id), prefix)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if len(captures) == 0 {
+		app.errorResponse(w, r, http.StatusConflict, "rental has no captured payment to refund")
+		return
+	}
+
+	// Депозит и доплата сверх него списаны из разных заморозок, поэтому
+	// возврат может пройти по нескольким из них.
+	parts, err := planRefund(captures, input.Amount)
+	if err != nil {
+		v.AddError("amount", err.Error())
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	rentalID := int(id)
+	refunds := make([]*data.Payment, 0, len(parts))
+	for _, part := range parts {
+		p := &data.Payment{
+			RentalID:        &rentalID,
+			AuthorizationID: &part.capture.AuthorizationID,
+			Kind:            data.PaymentKindRefund,
+			Amount:          part.amount,
+			Currency:        part.capture.Currency,
+			IdempotencyKey:  prefix + strconv.FormatInt(part.capture.AuthorizationID, 10),
+		}
+
+		err = app.runPayment(p, app.auditFor(r, nil), func(ctx context.Context) (string, error) {
+			return app.payments.Refund(ctx, p.IdempotencyKey, part.capture.ProviderRef, p.Amount)
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, payment.ErrAmountExceeded):
+				v.AddError("amount", err.Error())
+				app.failedValidationResponse(w, r, v.Errors)
+			case errors.Is(err, payment.ErrInvalidState):
+				app.errorResponse(w, r, http.StatusConflict, err.Error())
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		refunds = append(refunds, p)
+	}
+
+	env := envelope{"payments": refunds}
+	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
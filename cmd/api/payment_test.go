@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/olzzhas/qrent/internal/data"
+	"github.com/olzzhas/qrent/internal/payment"
+)
+
+func TestPlanRefund(t *testing.T) {
+	captures := []*data.RefundableCapture{
+		{AuthorizationID: 10, Amount: 5000},
+		{AuthorizationID: 12, Amount: 0},
+		{AuthorizationID: 14, Amount: 2000},
+	}
+
+	tests := []struct {
+		name    string
+		amount  int64
+		want    []int64
+		wantErr error
+	}{
+		{"within deposit", 3000, []int64{3000}, nil},
+		{"deposit and shortfall", 6000, []int64{5000, 1000}, nil},
+		{"everything captured", 7000, []int64{5000, 2000}, nil},
+		{"more than captured", 7001, nil, payment.ErrAmountExceeded},
+	}
+
+	for _, tt := range tests {
+		parts, err := planRefund(captures, tt.amount)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+			continue
+		}
+		if len(parts) != len(tt.want) {
+			t.Errorf("%s: expected %d parts, got %d", tt.name, len(tt.want), len(parts))
+			continue
+		}
+		for i, part := range parts {
+			if part.amount != tt.want[i] {
+				t.Errorf("%s: part %d: expected %d, got %d", tt.name, i, tt.want[i], part.amount)
+			}
+		}
+	}
+}
+
+// Аренда дороже депозита списана из двух заморозок: возврат всей стоимости
+// проходит по обеим, а из одной заморозки больше её списания не вернуть.
+func TestPlanRefund_RentalPricedAboveDeposit(t *testing.T) {
+	ctx := context.Background()
+	provider := payment.NewFake()
+
+	deposit, err := provider.Authorize(ctx, "deposit", 5000, "KZT")
+	if err != nil {
+		t.Fatalf("unexpected error in Authorize: %s", err)
+	}
+	if _, err := provider.Capture(ctx, "capture", deposit, 5000); err != nil {
+		t.Fatalf("unexpected error in Capture: %s", err)
+	}
+	shortfall, err := provider.Authorize(ctx, "shortfall-authorize", 2500, "KZT")
+	if err != nil {
+		t.Fatalf("unexpected error in Authorize: %s", err)
+	}
+	if _, err := provider.Capture(ctx, "shortfall-capture", shortfall, 2500); err != nil {
+		t.Fatalf("unexpected error in Capture: %s", err)
+	}
+
+	if _, err := provider.Refund(ctx, "single", shortfall, 7500); !errors.Is(err, payment.ErrAmountExceeded) {
+		t.Fatalf("expected a single authorization to refuse the full price, got %v", err)
+	}
+
+	captures := []*data.RefundableCapture{
+		{AuthorizationID: 1, ProviderRef: deposit, Amount: 5000},
+		{AuthorizationID: 2, ProviderRef: shortfall, Amount: 2500},
+	}
+	parts, err := planRefund(captures, 7500)
+	if err != nil {
+		t.Fatalf("unexpected error in planRefund: %s", err)
+	}
+
+	var refunded int64
+	for i, part := range parts {
+		if _, err := provider.Refund(ctx, "refund-"+part.capture.ProviderRef, part.capture.ProviderRef, part.amount); err != nil {
+			t.Fatalf("part %d: unexpected error in Refund: %s", i, err)
+		}
+		refunded += part.amount
+	}
+	if refunded != 7500 {
+		t.Errorf("expected the whole price 7500 refunded, got %d", refunded)
+	}
+}
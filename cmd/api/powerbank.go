@@ -5,18 +5,24 @@ import (
 	"github.com/olzzhas/qrent/internal/data"
 	"github.com/olzzhas/qrent/pkg/validator"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 )
 
 // GetPowerbankHandler godoc
 // @Summary Получает повербанк по ID
-// @Description Возвращает повербанк по переданному идентификатору
+// @Description Возвращает повербанк по переданному идентификатору. ETag ответа — версия повербанка для If-Match в PUT
 // @Tags powerbanks
 // @Accept json
 // @Produce json
 // @Param id path int true "Powerbank ID"
+// @Param include_deleted query bool false "Вернуть и мягко удалённый повербанк (только для администраторов)"
 // @Success 200 {object} PowerbankResponse
+// @Header 200 {string} ETag "Версия повербанка"
 // @Failure 400 {object} ErrorResponse "invalid id parameter"
 // @Failure 404 {object} ErrorResponse "the requested resource could not be found"
+// @Failure 422 {object} ErrorResponse "include_deleted is available to administrators only"
 // @Failure 500 {object} ErrorResponse "the server encountered a problem and could not process your request"
 // @Router /powerbanks/{id} [get]
 func (app *application) GetPowerbankHandler(w http.ResponseWriter, r *http.Request) {
@@ -26,13 +32,97 @@ func (app *application) GetPowerbankHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	p, err := app.models.Powerbank.Get(int(id))
+	v := validator.New()
+	includeDeleted := app.readIncludeDeleted(r, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	get := app.models.Powerbank.Get
+	if includeDeleted {
+		get = app.models.Powerbank.GetWithDeleted
+	}
+
+	p, err := get(int(id))
 	if err != nil {
 		app.notFoundResponse(w, r)
 		return
 	}
 
 	env := envelope{"powerbank": p}
+	if err := app.writeJSON(w, http.StatusOK, env, app.etagHeader(p.Version)); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// GetPowerbankHistoryHandler godoc
+// @Summary Возвращает историю повербанка
+// @Description Возвращает страницу событий повербанка: смены статуса и станции с автором и арендой
+// @Tags powerbanks
+// @Accept json
+// @Produce json
+// @Param id path int true "Powerbank ID"
+// @Param page query int false "Номер страницы" default(1)
+// @Param page_size query int false "Размер страницы (до 100)" default(20)
+// @Param sort query string false "Сортировка: id; префикс - для убывания" default(-id)
+// @Success 200 {object} PowerbankEventListResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Security BearerAuth
+// @Router /powerbanks/{id}/history [get]
+func (app *application) GetPowerbankHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	p, err := app.models.Powerbank.Get(int(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	allowed, err := app.canManageStation(r, p.CurrentStationID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !allowed {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	filters := data.Filters{
+		Page:         app.readInt(qs, "page", 1, v),
+		PageSize:     app.readInt(qs, "page_size", 20, v),
+		Sort:         app.readString(qs, "sort", "-id"),
+		SortSafelist: []string{"id", "-id"},
+	}
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	events, metadata, err := app.models.Powerbank.History(p.ID, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{"events": events, "metadata": metadata}
 	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -40,18 +130,24 @@ func (app *application) GetPowerbankHandler(w http.ResponseWriter, r *http.Reque
 
 // CreatePowerbankHandler godoc
 // @Summary Создаёт новый повербанк
-// @Description Создаёт повербанк с заданными current_station_id и status
+// @Description Создаёт повербанк с заданными current_station_id и status (available, charging или maintenance: rented и lost выставляют только аренды). Повербанк занимает slot_number или первый свободный слот станции
 // @Tags powerbanks
 // @Accept json
 // @Produce json
 // @Param powerbank body CreatePowerbankRequest true "Powerbank Data"
+// @Param Idempotency-Key header string false "Ключ идемпотентности: повтор с тем же ключом вернёт исходный ответ"
 // @Success 201 {object} PowerbankResponse
 // @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 422 {object} map[string]string
+// @Security BearerAuth
 // @Router /powerbanks [post]
 func (app *application) CreatePowerbankHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
 		CurrentStationID int    `json:"current_station_id"`
+		SlotNumber       *int   `json:"slot_number"`
 		Status           string `json:"status"`
 	}
 
@@ -62,23 +158,35 @@ func (app *application) CreatePowerbankHandler(w http.ResponseWriter, r *http.Re
 
 	p := &data.Powerbank{
 		CurrentStationID: input.CurrentStationID,
+		SlotNumber:       input.SlotNumber,
 		Status:           data.PowerbankStatus(input.Status),
 	}
 
 	v := validator.New()
-	data.ValidatePowerbank(v, p)
+	data.ValidateNewPowerbank(v, p)
 	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	// Вставка нового повербанка в базу.
-	if err := app.models.Powerbank.Insert(p); err != nil {
-		if errors.As(data.ErrInvalidForeignKey, &err) {
-			app.badRequestResponse(w, r, err)
-			return
+	allowed, err := app.canManageStation(r, p.CurrentStationID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.badRequestResponse(w, r, data.ErrInvalidForeignKey)
+		default:
+			app.serverErrorResponse(w, r, err)
 		}
-		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !allowed {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	// Вставка нового повербанка в базу.
+	if err := app.models.Powerbank.Insert(p, &app.contextGetUser(r).ID, app.auditFor(r, nil)); err != nil {
+		app.placePowerbankErrorResponse(w, r, err)
 		return
 	}
 
@@ -89,19 +197,90 @@ func (app *application) CreatePowerbankHandler(w http.ResponseWriter, r *http.Re
 }
 
 // UpdatePowerbankHandler godoc
-// @Summary Обновляет повербанк по ID
-// @Description Обновляет данные повербанка. Обновляются только переданные поля.
+// @Summary Заменяет повербанк по ID
+// @Description Заменяет данные повербанка целиком: тело должно содержать current_station_id и status, slot_number null или без значения занимает первый свободный слот. Для частичного обновления используйте PATCH. Статус меняется только по допустимым переходам (available -> charging|maintenance, charging -> available|maintenance, maintenance -> available|charging, lost -> maintenance); статусы rented и lost выставляют только аренды, повербанк в аренде не правится. Недопустимый переход возвращает 409. С If-Match обновление пройдёт, только если версия не изменилась
 // @Tags powerbanks
 // @Accept json
 // @Produce json
 // @Param id path int true "Powerbank ID"
+// @Param If-Match header string false "ETag из GET /powerbanks/{id}"
 // @Param powerbank body UpdatePowerbankRequest true "Powerbank Data"
 // @Success 200 {object} PowerbankResponse
+// @Header 200 {string} ETag "Новая версия повербанка"
 // @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 412 {object} map[string]string
 // @Failure 422 {object} map[string]string
+// @Security BearerAuth
 // @Router /powerbanks/{id} [put]
 func (app *application) UpdatePowerbankHandler(w http.ResponseWriter, r *http.Request) {
+	app.updatePowerbank(w, r, func(p *data.Powerbank, v *validator.Validator) error {
+		var input struct {
+			CurrentStationID int    `json:"current_station_id"`
+			SlotNumber       *int   `json:"slot_number"`
+			Status           string `json:"status"`
+		}
+
+		if err := app.readJSON(w, r, &input); err != nil {
+			return err
+		}
+
+		p.CurrentStationID = input.CurrentStationID
+		p.SlotNumber = input.SlotNumber
+		p.Status = data.PowerbankStatus(input.Status)
+		return nil
+	})
+}
+
+// PatchPowerbankHandler godoc
+// @Summary Частично обновляет повербанк по ID
+// @Description Применяет JSON Merge Patch (RFC 7396): меняются только переданные поля, null сбрасывает поле. slot_number: null занимает первый свободный слот; при смене станции без slot_number слот тоже выбирается заново. Статус меняется только по допустимым переходам, как в PUT, недопустимый переход возвращает 409. Ошибки возвращаются по полям. С If-Match обновление пройдёт, только если версия не изменилась
+// @Tags powerbanks
+// @Accept application/merge-patch+json
+// @Produce json
+// @Param id path int true "Powerbank ID"
+// @Param If-Match header string false "ETag из GET /powerbanks/{id}"
+// @Param powerbank body PatchPowerbankRequest true "Changed fields"
+// @Success 200 {object} PowerbankResponse
+// @Header 200 {string} ETag "Новая версия повербанка"
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Security BearerAuth
+// @Router /powerbanks/{id} [patch]
+func (app *application) PatchPowerbankHandler(w http.ResponseWriter, r *http.Request) {
+	app.updatePowerbank(w, r, func(p *data.Powerbank, v *validator.Validator) error {
+		patch, err := app.readMergePatch(w, r, v, "current_station_id", "slot_number", "status")
+		if err != nil {
+			return err
+		}
+
+		// При переезде на другую станцию старый номер слота теряет смысл:
+		// без явного slot_number займётся первый свободный.
+		stationID := p.CurrentStationID
+		patchField(patch, "current_station_id", &p.CurrentStationID, v)
+		if _, ok := patch["slot_number"]; !ok && p.CurrentStationID != stationID {
+			p.SlotNumber = nil
+		}
+
+		patchField(patch, "slot_number", &p.SlotNumber, v)
+		patchField(patch, "status", &p.Status, v)
+		return nil
+	})
+}
+
+// updatePowerbank — общая часть PUT и PATCH: apply переносит тело запроса в
+// повербанк, ошибка apply означает неразборчивое тело, а ошибки полей apply
+// добавляет в v.
+func (app *application) updatePowerbank(w http.ResponseWriter, r *http.Request, apply func(*data.Powerbank, *validator.Validator) error) {
 	id, err := app.readIDParam(r)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
@@ -110,61 +289,93 @@ func (app *application) UpdatePowerbankHandler(w http.ResponseWriter, r *http.Re
 
 	p, err := app.models.Powerbank.Get(int(id))
 	if err != nil {
-		app.notFoundResponse(w, r)
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
-	var input struct {
-		CurrentStationID *int    `json:"current_station_id"`
-		Status           *string `json:"status"`
+	allowed, err := app.canManageStation(r, p.CurrentStationID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
 	}
-
-	if err := app.readJSON(w, r, &input); err != nil {
-		app.badRequestResponse(w, r, err)
+	if !allowed {
+		app.notPermittedResponse(w, r)
 		return
 	}
 
-	if input.CurrentStationID != nil {
-		p.CurrentStationID = *input.CurrentStationID
+	if !app.checkIfMatch(r, p.Version) {
+		app.preconditionFailedResponse(w, r)
+		return
 	}
 
-	if input.Status != nil {
-		newStatus := data.PowerbankStatus(*input.Status)
-		p.Status = newStatus
-	}
+	before := *p
 
 	v := validator.New()
+	if err := apply(p, v); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
 	data.ValidatePowerbank(v, p)
 	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	if err := app.models.Powerbank.Update(p); err != nil {
-		if errors.As(data.ErrInvalidForeignKey, &err) {
-			app.badRequestResponse(w, r, err)
+	if p.CurrentStationID != before.CurrentStationID {
+		allowed, err := app.canManageStation(r, p.CurrentStationID)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.badRequestResponse(w, r, data.ErrInvalidForeignKey)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
 			return
 		}
-		app.serverErrorResponse(w, r, err)
+		if !allowed {
+			app.notPermittedResponse(w, r)
+			return
+		}
+	}
+
+	if err := app.models.Powerbank.Update(p, before.Status, &app.contextGetUser(r).ID, app.auditFor(r, &before)); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.placePowerbankErrorResponse(w, r, err)
+		}
 		return
 	}
 
 	env := envelope{"powerbank": p}
-	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+	if err := app.writeJSON(w, http.StatusOK, env, app.etagHeader(p.Version)); err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
 // DeletePowerbankHandler godoc
 // @Summary Удаляет повербанк по ID
-// @Description Удаляет повербанк с заданным идентификатором
+// @Description Мягко удаляет повербанк и освобождает его слот. Повербанк в аренде удалить нельзя. Восстанавливается через POST /powerbanks/{id}/restore
 // @Tags powerbanks
 // @Accept json
 // @Produce json
 // @Param id path int true "Powerbank ID"
 // @Success 200 {object} MessageResponse
 // @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
 // @Router /powerbanks/{id} [delete]
 func (app *application) DeletePowerbankHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
@@ -173,8 +384,36 @@ func (app *application) DeletePowerbankHandler(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	if err := app.models.Powerbank.Delete(int(id)); err != nil {
-		app.notFoundResponse(w, r)
+	p, err := app.models.Powerbank.Get(int(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	allowed, err := app.canManageStation(r, p.CurrentStationID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !allowed {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	if err := app.models.Powerbank.Delete(int(id), app.auditFor(r, p)); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrPowerbankRented):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
@@ -184,24 +423,320 @@ func (app *application) DeletePowerbankHandler(w http.ResponseWriter, r *http.Re
 	}
 }
 
+// RestorePowerbankHandler godoc
+// @Summary Восстанавливает удалённый повербанк
+// @Description Восстанавливает мягко удалённый повербанк. Повербанк без слота занимает первый свободный слот станции. Повербанк удалённой станции восстановить нельзя
+// @Tags powerbanks
+// @Accept json
+// @Produce json
+// @Param id path int true "Powerbank ID"
+// @Success 200 {object} PowerbankResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /powerbanks/{id}/restore [post]
+func (app *application) RestorePowerbankHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	p, err := app.models.Powerbank.GetWithDeleted(int(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	station, err := app.models.Station.GetWithDeleted(p.CurrentStationID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !app.contextGetUser(r).CanManageOrganization(station.OrgID) {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	p, err = app.models.Powerbank.Restore(p.ID, app.auditFor(r, nil))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrStationFull):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		default:
+			app.restoreErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env := envelope{"powerbank": p}
+	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
 // ListPowerbankHandler godoc
 // @Summary Возвращает список повербанков
-// @Description Возвращает все повербанки
+// @Description Возвращает страницу повербанков с фильтрами по статусу и станции
 // @Tags powerbanks
 // @Accept json
 // @Produce json
+// @Param status query string false "Статусы через запятую: rented, available, charging, lost, maintenance"
+// @Param current_station_id query int false "Фильтр по текущей станции"
+// @Param include_deleted query bool false "Включить мягко удалённые повербанки (только для администраторов)"
+// @Param page query int false "Номер страницы" default(1)
+// @Param page_size query int false "Размер страницы (до 100)" default(20)
+// @Param sort query string false "Сортировка: id, status, current_station_id, created_at; префикс - для убывания" default(id)
 // @Success 200 {object} PowerbankListResponse
+// @Failure 422 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /powerbanks [get]
 func (app *application) ListPowerbankHandler(w http.ResponseWriter, r *http.Request) {
-	powerbanks, err := app.models.Powerbank.List()
+	var input struct {
+		Statuses         []string
+		CurrentStationID int
+		IncludeDeleted   bool
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Statuses = app.readPowerbankStatuses(qs, v)
+	input.CurrentStationID = app.readInt(qs, "current_station_id", 0, v)
+	input.IncludeDeleted = app.readIncludeDeleted(r, v)
+	input.Filters = app.readPowerbankFilters(qs, v)
+
+	v.Check(input.CurrentStationID >= 0, "current_station_id", "must be a positive integer")
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	powerbanks, metadata, err := app.models.Powerbank.List(input.Statuses, input.CurrentStationID, input.IncludeDeleted, input.Filters)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	env := envelope{"powerbanks": powerbanks}
+	env := envelope{"powerbanks": powerbanks, "metadata": metadata}
 	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+// readPowerbankStatuses читает фильтр списка повербанков по статусам.
+func (app *application) readPowerbankStatuses(qs url.Values, v *validator.Validator) []string {
+	statuses := app.readCSV(qs, "status", []string{})
+	for _, status := range statuses {
+		if !data.PowerbankStatus(status).IsValid() {
+			v.AddError("status", "must be one of: rented, available, charging, lost, maintenance")
+		}
+	}
+	return statuses
+}
+
+// readPowerbankFilters читает страницу и сортировку списка повербанков.
+func (app *application) readPowerbankFilters(qs url.Values, v *validator.Validator) data.Filters {
+	return data.Filters{
+		Page:     app.readInt(qs, "page", 1, v),
+		PageSize: app.readInt(qs, "page_size", 20, v),
+		Sort:     app.readString(qs, "sort", "id"),
+		SortSafelist: []string{
+			"id", "status", "current_station_id", "created_at",
+			"-id", "-status", "-current_station_id", "-created_at",
+		},
+	}
+}
+
+// placePowerbankErrorResponse отвечает на ошибки Insert и Update повербанка,
+// включая ошибки размещения в слоте.
+func (app *application) placePowerbankErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, data.ErrInvalidForeignKey):
+		app.badRequestResponse(w, r, err)
+	case errors.Is(err, data.ErrStationFull), errors.Is(err, data.ErrSlotOccupied),
+		errors.Is(err, data.ErrInvalidTransition):
+		app.errorResponse(w, r, http.StatusConflict, err.Error())
+	case errors.Is(err, data.ErrInvalidSlot):
+		app.failedValidationResponse(w, r, map[string]string{"slot_number": err.Error()})
+	default:
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// ImportPowerbanksHandler godoc
+// @Summary Импортирует повербанки из CSV или NDJSON
+// @Description Создаёт повербанки одной транзакцией: либо все, либо ни одного. Тело — CSV с заголовком (колонки current_station_id, slot_number, status в любом порядке) или NDJSON с теми же ключами, до 10000 строк. Каждая строка проверяется как при создании повербанка (status — available, charging или maintenance), пустой slot_number занимает первый свободный слот; при ошибках возвращается 422 со списком строк файла и их ошибок. С dry_run=true строки только проверяются, повербанки не создаются
+// @Tags powerbanks
+// @Accept text/csv
+// @Accept application/x-ndjson
+// @Produce json
+// @Param dry_run query bool false "Только проверить файл" default(false)
+// @Success 200 {object} ImportResponse "dry_run: файл прошёл проверку"
+// @Success 201 {object} PowerbankImportResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Failure 422 {object} ImportErrorResponse
+// @Security BearerAuth
+// @Router /powerbanks/import [post]
+func (app *application) ImportPowerbanksHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	dryRun := app.readBool(r.URL.Query(), "dry_run", false, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	columns := []string{"current_station_id", "slot_number", "status"}
+
+	rows, err := app.readImport(w, r, columns...)
+	if err != nil {
+		if errors.Is(err, errUnsupportedImportFormat) {
+			app.unsupportedImportFormatResponse(w, r)
+			return
+		}
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	stationErrors := make(map[int]string)
+
+	powerbanks := make([]*data.Powerbank, len(rows))
+	var rowErrors []importRowError
+	for i, row := range rows {
+		var status string
+		p := &data.Powerbank{}
+
+		v := row.check(columns...)
+		importField(row, "current_station_id", &p.CurrentStationID, v)
+		importField(row, "slot_number", &p.SlotNumber, v)
+		importField(row, "status", &status, v)
+		p.Status = data.PowerbankStatus(status)
+		data.ValidateNewPowerbank(v, p)
+
+		if _, invalid := v.Errors["current_station_id"]; !invalid {
+			message, checked := stationErrors[p.CurrentStationID]
+			if !checked {
+				allowed, err := app.canManageStation(r, p.CurrentStationID)
+				switch {
+				case errors.Is(err, data.ErrRecordNotFound):
+					message = "station does not exist"
+				case err != nil:
+					app.serverErrorResponse(w, r, err)
+					return
+				case !allowed:
+					message = "must be a station of an organization you manage"
+				}
+				stationErrors[p.CurrentStationID] = message
+			}
+			if message != "" {
+				v.AddError("current_station_id", message)
+			}
+		}
+
+		if !v.Valid() {
+			rowErrors = append(rowErrors, importRowError{Line: row.line, Errors: v.Errors})
+		}
+		powerbanks[i] = p
+	}
+
+	if len(rowErrors) > 0 {
+		app.importFailedResponse(w, r, rowErrors)
+		return
+	}
+
+	if dryRun {
+		err = app.models.Powerbank.CheckImport(powerbanks)
+	} else {
+		err = app.models.Powerbank.Import(powerbanks, &app.contextGetUser(r).ID, app.auditFor(r, nil))
+	}
+	if err != nil {
+		rowErrors, ok := importRowErrors(rows, err, func(err error) (string, string, bool) {
+			switch {
+			case errors.Is(err, data.ErrInvalidForeignKey):
+				return "current_station_id", "station does not exist", true
+			case errors.Is(err, data.ErrStationFull), errors.Is(err, data.ErrSlotOccupied),
+				errors.Is(err, data.ErrInvalidSlot):
+				return "slot_number", err.Error(), true
+			default:
+				return "", "", false
+			}
+		})
+		if !ok {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		app.importFailedResponse(w, r, rowErrors)
+		return
+	}
+
+	report := importReport{Rows: len(powerbanks), DryRun: dryRun}
+	if dryRun {
+		if err := app.writeJSON(w, http.StatusOK, envelope{"import": report}, nil); err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env := envelope{"import": report, "powerbanks": powerbanks}
+	if err := app.writeJSON(w, http.StatusCreated, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// ExportPowerbanksHandler godoc
+// @Summary Выгружает повербанки в CSV
+// @Description Возвращает все неудалённые повербанки по возрастанию id одним CSV-файлом, который отдаётся по мере чтения из базы. Оператор выгружает только повербанки станций своей организации, администратор без org_id — все повербанки Если база отказала посреди выгрузки, файл заканчивается строкой #export-aborted, а трейлер X-Export-Status равен error; у полной выгрузки он равен complete
+// @Tags powerbanks
+// @Produce text/csv
+// @Param org_id query int false "ID организации"
+// @Param station_id query int false "ID станции"
+// @Success 200 {string} string "CSV: id, current_station_id, slot_number, status, created_at, updated_at, version"
+// @Header 200 {string} X-Export-Status "Трейлер: complete или error"
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /powerbanks/export [get]
+func (app *application) ExportPowerbanksHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	orgID, allowed := app.readExportOrgID(r, v)
+	stationID := app.readInt(r.URL.Query(), "station_id", 0, v)
+	v.Check(stationID >= 0, "station_id", "must be a positive integer")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	if !allowed {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	export := newCSVExport(w, "powerbanks.csv",
+		"id", "current_station_id", "slot_number", "status", "created_at", "updated_at", "version")
+
+	err := app.models.Powerbank.Export(orgID, stationID, func(p *data.Powerbank) error {
+		return export.write([]string{
+			strconv.Itoa(p.ID),
+			strconv.Itoa(p.CurrentStationID),
+			csvOptionalInt(p.SlotNumber),
+			string(p.Status),
+			p.CreatedAt.Format(time.RFC3339),
+			p.UpdatedAt.Format(time.RFC3339),
+			strconv.Itoa(p.Version),
+		})
+	})
+	app.finishCSVExport(w, r, export, err)
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/olzzhas/qrent/internal/data"
+	"github.com/olzzhas/qrent/pkg/qrcode"
+	"github.com/olzzhas/qrent/pkg/validator"
+)
+
+// GetStationQRHandler godoc
+// @Summary Возвращает QR-код станции
+// @Description Отрисовывает подписанный QR-код станции в PNG или SVG для печати наклейки
+// @Tags qr
+// @Produce png
+// @Produce image/svg+xml
+// @Param id path int true "Station ID"
+// @Param format query string false "Формат: png или svg" default(png)
+// @Param scale query int false "Размер модуля в пикселях (1-40)" default(8)
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Security BearerAuth
+// @Router /stations/{id}/qr [get]
+func (app *application) GetStationQRHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	station, err := app.models.Station.Get(int(id))
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	if !app.contextGetUser(r).CanManageOrganization(station.OrgID) {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	app.writeQRCode(w, r, data.QRKindStation, station.ID)
+}
+
+// GetPowerbankQRHandler godoc
+// @Summary Возвращает QR-код повербанка
+// @Description Отрисовывает подписанный QR-код повербанка в PNG или SVG для печати наклейки
+// @Tags qr
+// @Produce png
+// @Produce image/svg+xml
+// @Param id path int true "Powerbank ID"
+// @Param format query string false "Формат: png или svg" default(png)
+// @Param scale query int false "Размер модуля в пикселях (1-40)" default(8)
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Security BearerAuth
+// @Router /powerbanks/{id}/qr [get]
+func (app *application) GetPowerbankQRHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	p, err := app.models.Powerbank.Get(int(id))
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	allowed, err := app.canManageStation(r, p.CurrentStationID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !allowed {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	app.writeQRCode(w, r, data.QRKindPowerbank, p.ID)
+}
+
+// writeQRCode подписывает QR-код объекта текущей ревизии и отдаёт его
+// изображением в формате из параметра format.
+func (app *application) writeQRCode(w http.ResponseWriter, r *http.Request, kind data.QRKind, id int) {
+	v := validator.New()
+	qs := r.URL.Query()
+
+	format := app.readString(qs, "format", "png")
+	scale := app.readInt(qs, "scale", 8, v)
+
+	v.Check(validator.PermittedValue(format, "png", "svg"), "format", "must be png or svg")
+	v.Check(scale >= 1 && scale <= 40, "scale", "must be between 1 and 40")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	revision, err := app.models.QRCode.Revision(kind, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	payload := data.SignQRCode([]byte(app.config.qr.secret), data.QRCode{Kind: kind, ID: id, Revision: revision})
+
+	code, err := qrcode.Encode([]byte(payload))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	var (
+		body        []byte
+		contentType string
+	)
+	switch format {
+	case "svg":
+		body, contentType = code.SVG(scale), "image/svg+xml"
+	default:
+		body, err = code.PNG(scale)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		contentType = "image/png"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="qrent-%s-%d.%s"`, kind, id, format))
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// RevokeStationQRHandler godoc
+// @Summary Отзывает QR-коды станции
+// @Description Делает недействительными все ранее напечатанные QR-коды станции; новый код нужно перепечатать
+// @Tags qr
+// @Produce json
+// @Param id path int true "Station ID"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /stations/{id}/qr/revoke [post]
+func (app *application) RevokeStationQRHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	station, err := app.models.Station.Get(int(id))
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	if !app.contextGetUser(r).CanManageOrganization(station.OrgID) {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	app.revokeQRCode(w, r, data.QRKindStation, station.ID)
+}
+
+// RevokePowerbankQRHandler godoc
+// @Summary Отзывает QR-коды повербанка
+// @Description Делает недействительными все ранее напечатанные QR-коды повербанка; новый код нужно перепечатать
+// @Tags qr
+// @Produce json
+// @Param id path int true "Powerbank ID"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /powerbanks/{id}/qr/revoke [post]
+func (app *application) RevokePowerbankQRHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	p, err := app.models.Powerbank.Get(int(id))
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	allowed, err := app.canManageStation(r, p.CurrentStationID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !allowed {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	app.revokeQRCode(w, r, data.QRKindPowerbank, p.ID)
+}
+
+func (app *application) revokeQRCode(w http.ResponseWriter, r *http.Request, kind data.QRKind, id int) {
+	if _, err := app.models.QRCode.Revoke(kind, id, app.auditFor(r, nil)); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env := envelope{"message": "QR codes successfully revoked"}
+	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// ScanHandler godoc
+// @Summary Разбирает отсканированный QR-код
+// @Description Проверяет подпись и ревизию QR-кода и возвращает станцию с повербанками, доступными для аренды. Для кода повербанка в списке только он сам, если он доступен
+// @Tags qr
+// @Accept json
+// @Produce json
+// @Param scan body ScanRequest true "Scanned Payload"
+// @Success 200 {object} ScanResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Security BearerAuth
+// @Router /scan [post]
+func (app *application) ScanHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Payload string `json:"payload"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.Payload != "", "payload", "must be provided")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	code, err := data.ParseQRCode([]byte(app.config.qr.secret), input.Payload)
+	if err == nil {
+		var revision int
+		revision, err = app.models.QRCode.Revision(code.Kind, code.ID)
+		if err == nil && revision != code.Revision {
+			err = data.ErrInvalidQRCode
+		}
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidQRCode), errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("payload", data.ErrInvalidQRCode.Error())
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env := envelope{}
+
+	stationID := code.ID
+	if code.Kind == data.QRKindPowerbank {
+		p, err := app.models.Powerbank.Get(code.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+		stationID = p.CurrentStationID
+		env["powerbank"] = p
+	}
+
+	// Объект могли удалить между проверкой ревизии и чтением.
+	station, err := app.models.Station.Get(stationID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	env["station"] = station
+
+	filters := data.Filters{Page: 1, PageSize: 100, Sort: "id", SortSafelist: []string{"id"}}
+	available, _, err := app.models.Powerbank.List([]string{string(data.PowerbankStatusAvailable)}, stationID, false, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if code.Kind == data.QRKindPowerbank {
+		scanned := available[:0]
+		for _, p := range available {
+			if p.ID == code.ID {
+				scanned = append(scanned, p)
+			}
+		}
+		available = scanned
+	}
+	env["powerbanks"] = available
+
+	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
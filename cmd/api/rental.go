@@ -0,0 +1,230 @@
+package main
+
+import (
+	"errors"
+	"github.com/olzzhas/qrent/internal/data"
+	"github.com/olzzhas/qrent/internal/payment"
+	"github.com/olzzhas/qrent/pkg/validator"
+	"net/http"
+)
+
+// GetRentalHandler godoc
+// @Summary Получает аренду по ID
+// @Description Возвращает аренду по переданному идентификатору
+// @Tags rentals
+// @Accept json
+// @Produce json
+// @Param id path int true "Rental ID"
+// @Success 200 {object} RentalResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /rentals/{id} [get]
+func (app *application) GetRentalHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	rental, err := app.models.Rental.Get(int(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if user := app.contextGetUser(r); !rental.IsOwnedBy(user) && user.Role != data.RoleAdmin {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	env := envelope{"rental": rental}
+	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// StartRentalHandler godoc
+// @Summary Начинает аренду повербанка
+// @Description Выдаёт доступный повербанк со станции и переводит его в статус rented. Если в тарифе станции задан штраф за потерю, на эту сумму замораживается депозит; отказ провайдера возвращает 402. Действующий тариф станции копируется в аренду и определяет её цену до возврата
+// @Tags rentals
+// @Accept json
+// @Produce json
+// @Param rental body StartRentalRequest true "Rental Data"
+// @Param Idempotency-Key header string false "Ключ идемпотентности: повтор с тем же ключом вернёт исходный ответ"
+// @Success 201 {object} RentalResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 402 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 422 {object} map[string]string
+// @Security BearerAuth
+// @Router /rentals [post]
+func (app *application) StartRentalHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		PowerbankID int `json:"powerbank_id"`
+		StationID   int `json:"station_id"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	rental := &data.Rental{
+		UserID:         &user.ID,
+		PowerbankID:    input.PowerbankID,
+		StartStationID: input.StationID,
+	}
+
+	v := validator.New()
+	data.ValidateRentalStart(v, rental)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	tariff, err := app.models.Tariff.GetForStation(rental.StartStationID)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	var deposit *data.Payment
+	if tariff != nil && tariff.LostPenalty > 0 {
+		deposit, err = app.authorizeDeposit(tariff)
+		if err != nil {
+			switch {
+			case errors.Is(err, payment.ErrDeclined):
+				app.paymentDeclinedResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+	}
+
+	if err := app.models.Rental.Start(rental, deposit); err != nil {
+		if deposit != nil {
+			if err := app.voidPayment(deposit, deposit.IdempotencyKey+":void"); err != nil {
+				app.logError(r, err)
+			}
+		}
+
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrPowerbankNotAvailable):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env := envelope{"rental": rental}
+	if err := app.writeJSON(w, http.StatusCreated, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// ReturnRentalHandler godoc
+// @Summary Завершает аренду
+// @Description Возвращает повербанк в слот любой станции и закрывает аренду. Без slot_number занимается первый свободный слот; возврат на заполненную станцию отклоняется с 409. Стоимость считается по тарифу на момент выдачи и списывается в фоне; результат виден в /rentals/{id}/payments
+// @Tags rentals
+// @Accept json
+// @Produce json
+// @Param id path int true "Rental ID"
+// @Param rental body ReturnRentalRequest true "Return Data"
+// @Param Idempotency-Key header string false "Ключ идемпотентности: повтор с тем же ключом вернёт исходный ответ"
+// @Success 200 {object} RentalResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 422 {object} map[string]string
+// @Security BearerAuth
+// @Router /rentals/{id}/return [post]
+func (app *application) ReturnRentalHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var input struct {
+		StationID  int  `json:"station_id"`
+		SlotNumber *int `json:"slot_number"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	data.ValidateRentalReturn(v, input.StationID, input.SlotNumber)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	rental, err := app.models.Rental.Get(int(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if user := app.contextGetUser(r); !rental.IsOwnedBy(user) && user.Role != data.RoleAdmin {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	if err := app.models.Rental.Finish(rental, input.StationID, input.SlotNumber); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrRentalNotActive),
+			errors.Is(err, data.ErrStationFull),
+			errors.Is(err, data.ErrSlotOccupied):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		case errors.Is(err, data.ErrInvalidSlot):
+			v.AddError("slot_number", err.Error())
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrInvalidForeignKey):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Не прошедший расчёт повторит фоновая проверка аренд.
+	app.background(func() {
+		if err := app.settleRental(rental); err != nil {
+			app.logger.PrintError(err, map[string]any{"rental_id": rental.ID}, "general")
+		}
+	})
+
+	env := envelope{"rental": rental}
+	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
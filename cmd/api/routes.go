@@ -3,6 +3,7 @@ package main
 import (
 	"expvar"
 	_ "github.com/olzzhas/qrent/docs"
+	"github.com/olzzhas/qrent/internal/data"
 	httpSwagger "github.com/swaggo/http-swagger"
 	"net/http"
 
@@ -12,35 +13,112 @@ import (
 func (app *application) routes() http.Handler {
 	router := httprouter.New()
 
-	router.NotFound = http.HandlerFunc(app.notFoundResponse)
-	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
+	router.NotFound = app.rateLimit(limiterClassRead, app.notFoundResponse)
+	router.MethodNotAllowed = app.rateLimit(limiterClassRead, app.methodNotAllowedResponse)
 
-	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
-	router.Handler(http.MethodGet, "/debug", expvar.Handler())
+	// route регистрирует маршрут вместе с классом его квоты запросов.
+	route := func(method, path string, class limiterClass, handler http.HandlerFunc) {
+		router.HandlerFunc(method, path, app.rateLimit(class, handler))
+	}
+
+	route(http.MethodGet, "/v1/healthcheck", limiterClassRead, app.healthcheckHandler)
+	route(http.MethodGet, "/debug", limiterClassRead, expvar.Handler().ServeHTTP)
 
 	// Swagger документация: маршрут /swagger/*any
-	router.HandlerFunc(http.MethodGet, "/swagger/*any", httpSwagger.WrapHandler)
+	route(http.MethodGet, "/swagger/*any", limiterClassRead, httpSwagger.WrapHandler)
 
 	// Organization routes.
-	router.HandlerFunc(http.MethodGet, "/v1/organizations", app.ListOrganizationHandler)
-	router.HandlerFunc(http.MethodPost, "/v1/organizations", app.CreateOrganizationHandler)
-	router.HandlerFunc(http.MethodGet, "/v1/organizations/:id", app.GetOrganizationHandler)
-	router.HandlerFunc(http.MethodPut, "/v1/organizations/:id", app.UpdateOrganizationHandler)
-	router.HandlerFunc(http.MethodDelete, "/v1/organizations/:id", app.DeleteOrganizationHandler)
+	route(http.MethodGet, "/v1/organizations", limiterClassRead, app.ListOrganizationHandler)
+	route(http.MethodPost, "/v1/organizations", limiterClassWrite, app.requirePermission(data.PermissionOrganizationsWrite, app.idempotent(app.CreateOrganizationHandler)))
+	route(http.MethodGet, "/v1/organizations/:id", limiterClassRead, app.GetOrganizationHandler)
+	route(http.MethodPut, "/v1/organizations/:id", limiterClassWrite, app.requirePermission(data.PermissionOrganizationsWrite, app.UpdateOrganizationHandler))
+	route(http.MethodPatch, "/v1/organizations/:id", limiterClassWrite, app.requirePermission(data.PermissionOrganizationsWrite, app.requireMergePatch(app.PatchOrganizationHandler)))
+	route(http.MethodDelete, "/v1/organizations/:id", limiterClassWrite, app.requirePermission(data.PermissionOrganizationsWrite, app.DeleteOrganizationHandler))
+	route(http.MethodPost, "/v1/organizations/:id/restore", limiterClassWrite, app.requirePermission(data.PermissionOrganizationsWrite, app.RestoreOrganizationHandler))
+	route(http.MethodGet, "/v1/organizations/:id/tariff", limiterClassRead, app.GetOrganizationTariffHandler)
+	route(http.MethodPut, "/v1/organizations/:id/tariff", limiterClassWrite, app.requirePermission(data.PermissionOrganizationsWrite, app.UpdateOrganizationTariffHandler))
+	route(http.MethodGet, "/v1/organizations/:id/overdue-rentals", limiterClassRead, app.requirePermission(data.PermissionStationsWrite, app.ListOverdueRentalsHandler))
+	route(http.MethodGet, "/v1/organizations/:id/rebalance-plan", limiterClassRead, app.requirePermission(data.PermissionStationsWrite, app.GetOrganizationRebalancePlanHandler))
+	route(http.MethodGet, "/v1/organizations/:id/stations", limiterClassRead, app.ListOrganizationStationsHandler)
+	route(http.MethodGet, "/v1/organizations/:id/powerbanks", limiterClassRead, app.ListOrganizationPowerbanksHandler)
 
 	// Powerbank routes.
-	router.HandlerFunc(http.MethodGet, "/v1/powerbanks", app.ListPowerbankHandler)
-	router.HandlerFunc(http.MethodPost, "/v1/powerbanks", app.CreatePowerbankHandler)
-	router.HandlerFunc(http.MethodGet, "/v1/powerbanks/:id", app.GetPowerbankHandler)
-	router.HandlerFunc(http.MethodPut, "/v1/powerbanks/:id", app.UpdatePowerbankHandler)
-	router.HandlerFunc(http.MethodDelete, "/v1/powerbanks/:id", app.DeletePowerbankHandler)
+	route(http.MethodGet, "/v1/powerbanks", limiterClassRead, app.ListPowerbankHandler)
+	route(http.MethodPost, "/v1/powerbanks", limiterClassWrite, app.requirePermission(data.PermissionPowerbanksWrite, app.idempotent(app.CreatePowerbankHandler)))
+	route(http.MethodPost, "/v1/powerbanks/:id", limiterClassWrite, app.staticOr(map[string]http.HandlerFunc{
+		"import": app.requirePermission(data.PermissionPowerbanksWrite, app.ImportPowerbanksHandler),
+	}, app.notFoundResponse))
+	route(http.MethodGet, "/v1/powerbanks/:id", limiterClassRead, app.staticOr(map[string]http.HandlerFunc{
+		"export": app.requirePermission(data.PermissionPowerbanksWrite, app.ExportPowerbanksHandler),
+	}, app.GetPowerbankHandler))
+	route(http.MethodPut, "/v1/powerbanks/:id", limiterClassWrite, app.requirePermission(data.PermissionPowerbanksWrite, app.UpdatePowerbankHandler))
+	route(http.MethodPatch, "/v1/powerbanks/:id", limiterClassWrite, app.requirePermission(data.PermissionPowerbanksWrite, app.requireMergePatch(app.PatchPowerbankHandler)))
+	route(http.MethodDelete, "/v1/powerbanks/:id", limiterClassWrite, app.requirePermission(data.PermissionPowerbanksWrite, app.DeletePowerbankHandler))
+	route(http.MethodPost, "/v1/powerbanks/:id/restore", limiterClassWrite, app.requirePermission(data.PermissionPowerbanksWrite, app.RestorePowerbankHandler))
+	route(http.MethodGet, "/v1/powerbanks/:id/history", limiterClassRead, app.requirePermission(data.PermissionPowerbanksWrite, app.GetPowerbankHistoryHandler))
+	route(http.MethodGet, "/v1/powerbanks/:id/qr", limiterClassRead, app.requirePermission(data.PermissionPowerbanksWrite, app.GetPowerbankQRHandler))
+	route(http.MethodPost, "/v1/powerbanks/:id/qr/revoke", limiterClassWrite, app.requirePermission(data.PermissionPowerbanksWrite, app.RevokePowerbankQRHandler))
 
 	// Station routes.
-	router.HandlerFunc(http.MethodGet, "/v1/stations", app.ListStationHandler)
-	router.HandlerFunc(http.MethodPost, "/v1/stations", app.CreateStationHandler)
-	router.HandlerFunc(http.MethodGet, "/v1/stations/:id", app.GetStationHandler)
-	router.HandlerFunc(http.MethodPut, "/v1/stations/:id", app.UpdateStationHandler)
-	router.HandlerFunc(http.MethodDelete, "/v1/stations/:id", app.DeleteStationHandler)
+	route(http.MethodGet, "/v1/stations", limiterClassRead, app.ListStationHandler)
+	route(http.MethodPost, "/v1/stations", limiterClassWrite, app.requirePermission(data.PermissionStationsWrite, app.idempotent(app.CreateStationHandler)))
+	route(http.MethodPost, "/v1/stations/:id", limiterClassWrite, app.staticOr(map[string]http.HandlerFunc{
+		"import": app.requirePermission(data.PermissionStationsWrite, app.ImportStationsHandler),
+	}, app.notFoundResponse))
+	route(http.MethodGet, "/v1/stations/:id", limiterClassRead, app.staticOr(map[string]http.HandlerFunc{
+		"nearby": app.ListNearbyStationsHandler,
+		"export": app.requirePermission(data.PermissionStationsWrite, app.ExportStationsHandler),
+	}, app.GetStationHandler))
+	route(http.MethodGet, "/v1/stations/:id/availability", limiterClassRead, app.GetStationAvailabilityHandler)
+	route(http.MethodGet, "/v1/stations/:id/slots", limiterClassRead, app.ListStationSlotsHandler)
+	route(http.MethodGet, "/v1/stations/:id/powerbanks", limiterClassRead, app.ListStationPowerbanksHandler)
+	route(http.MethodPut, "/v1/stations/:id", limiterClassWrite, app.requirePermission(data.PermissionStationsWrite, app.UpdateStationHandler))
+	route(http.MethodPatch, "/v1/stations/:id", limiterClassWrite, app.requirePermission(data.PermissionStationsWrite, app.requireMergePatch(app.PatchStationHandler)))
+	route(http.MethodDelete, "/v1/stations/:id", limiterClassWrite, app.requirePermission(data.PermissionStationsWrite, app.DeleteStationHandler))
+	route(http.MethodPost, "/v1/stations/:id/restore", limiterClassWrite, app.requirePermission(data.PermissionStationsWrite, app.RestoreStationHandler))
+	route(http.MethodPost, "/v1/stations/:id/transfer", limiterClassWrite, app.requirePermission(data.PermissionPowerbanksWrite, app.idempotent(app.TransferStationPowerbanksHandler)))
+	route(http.MethodGet, "/v1/stations/:id/qr", limiterClassRead, app.requirePermission(data.PermissionStationsWrite, app.GetStationQRHandler))
+	route(http.MethodPost, "/v1/stations/:id/qr/revoke", limiterClassWrite, app.requirePermission(data.PermissionStationsWrite, app.RevokeStationQRHandler))
+	route(http.MethodGet, "/v1/stations/:id/tariff", limiterClassRead, app.GetStationTariffHandler)
+	route(http.MethodPut, "/v1/stations/:id/tariff", limiterClassWrite, app.requirePermission(data.PermissionStationsWrite, app.UpdateStationTariffHandler))
+	route(http.MethodDelete, "/v1/stations/:id/tariff", limiterClassWrite, app.requirePermission(data.PermissionStationsWrite, app.DeleteStationTariffHandler))
+
+	// Audit routes.
+	route(http.MethodGet, "/v1/audit", limiterClassRead, app.requireActivatedUser(app.ListAuditHandler))
+
+	// QR routes.
+	route(http.MethodPost, "/v1/scan", limiterClassWrite, app.requirePermission(data.PermissionRentalsCreate, app.ScanHandler))
+
+	// Rental routes.
+	route(http.MethodPost, "/v1/rentals", limiterClassRental, app.requirePermission(data.PermissionRentalsCreate, app.idempotent(app.StartRentalHandler)))
+	route(http.MethodGet, "/v1/rentals/:id", limiterClassRead, app.requireActivatedUser(app.GetRentalHandler))
+	route(http.MethodPost, "/v1/rentals/:id/return", limiterClassWrite, app.requireActivatedUser(app.idempotent(app.ReturnRentalHandler)))
+	route(http.MethodGet, "/v1/rentals/:id/payments", limiterClassRead, app.requireActivatedUser(app.ListRentalPaymentsHandler))
+	route(http.MethodPost, "/v1/rentals/:id/refund", limiterClassWrite, app.requirePermission(data.PermissionPaymentsRefund, app.idempotent(app.RefundRentalHandler)))
+
+	// User routes.
+	route(http.MethodPost, "/v1/users", limiterClassWrite, app.RegisterUserHandler)
+	route(http.MethodPut, "/v1/users/:id", limiterClassWrite, app.staticOr(map[string]http.HandlerFunc{
+		"activated": app.ActivateUserHandler,
+	}, app.notFoundResponse))
+	route(http.MethodPut, "/v1/users/:id/role", limiterClassWrite, app.requirePermission(data.PermissionUsersWrite, app.UpdateUserRoleHandler))
+	route(http.MethodPost, "/v1/tokens/authentication", limiterClassWrite, app.CreateAuthenticationTokenHandler)
+
+	return app.metrics(app.requestID(app.recoverPanic(app.rateLimitIP(app.authenticate(router)))))
+}
+
+// staticOr обходит ограничение httprouter v1.3: статический сегмент
+// (например /v1/users/activated) не может соседствовать с параметром :id
+// в одном методе. Маршрут регистрируется с :id, а значения из static
+// перехватываются до обработчика по идентификатору.
+func (app *application) staticOr(static map[string]http.HandlerFunc, byID http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := httprouter.ParamsFromContext(r.Context())
+		if handler, ok := static[params.ByName("id")]; ok {
+			handler(w, r)
+			return
+		}
 
-	return app.metrics(app.recoverPanic(app.rateLimit(router)))
+		byID(w, r)
+	}
 }
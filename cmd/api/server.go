@@ -46,6 +46,7 @@ func (app *application) serve() error {
 			"addr": srv.Addr,
 		}, "general")
 
+		close(app.shutdown)
 		app.wg.Wait()
 		shutdownError <- nil
 	}()
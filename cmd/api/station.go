@@ -2,22 +2,29 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"github.com/olzzhas/qrent/pkg/validator"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/olzzhas/qrent/internal/data"
 )
 
 // GetStationHandler godoc
 // @Summary Получает станцию по ID
-// @Description Возвращает станцию по идентификатору
+// @Description Возвращает станцию по идентификатору. ETag ответа — версия станции для If-Match в PUT
 // @Tags stations
 // @Accept json
 // @Produce json
 // @Param id path int true "Station ID"
+// @Param include_deleted query bool false "Вернуть и мягко удалённую станцию (только для администраторов)"
 // @Success 200 {object} StationResponse
+// @Header 200 {string} ETag "Версия станции"
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 422 {object} map[string]string
 // @Router /stations/{id} [get]
 func (app *application) GetStationHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
@@ -26,13 +33,97 @@ func (app *application) GetStationHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	station, err := app.models.Station.Get(int(id))
+	v := validator.New()
+	includeDeleted := app.readIncludeDeleted(r, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	get := app.models.Station.Get
+	if includeDeleted {
+		get = app.models.Station.GetWithDeleted
+	}
+
+	station, err := get(int(id))
 	if err != nil {
 		app.notFoundResponse(w, r)
 		return
 	}
 
 	env := envelope{"station": station}
+	if err := app.writeJSON(w, http.StatusOK, env, app.etagHeader(station.Version)); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// GetStationAvailabilityHandler godoc
+// @Summary Возвращает доступность станции
+// @Description Возвращает число повербанков на станции по статусам: доступные, заряжающиеся и взятые с неё в аренду
+// @Tags stations
+// @Accept json
+// @Produce json
+// @Param id path int true "Station ID"
+// @Success 200 {object} StationAvailabilityResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /stations/{id}/availability [get]
+func (app *application) GetStationAvailabilityHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if _, err := app.models.Station.Get(int(id)); err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	availability, err := app.models.Station.Availability(int(id))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{"availability": availability}
+	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// ListStationSlotsHandler godoc
+// @Summary Возвращает слоты станции
+// @Description Возвращает все слоты станции от 1 до capacity с повербанками, которые в них стоят
+// @Tags stations
+// @Accept json
+// @Produce json
+// @Param id path int true "Station ID"
+// @Success 200 {object} StationSlotListResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /stations/{id}/slots [get]
+func (app *application) ListStationSlotsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if _, err := app.models.Station.Get(int(id)); err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	slots, err := app.models.Station.Slots(int(id))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{"slots": slots}
 	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -40,18 +131,26 @@ func (app *application) GetStationHandler(w http.ResponseWriter, r *http.Request
 
 // CreateStationHandler godoc
 // @Summary Создаёт новую станцию
-// @Description Создаёт станцию, привязанную к организации (org_id)
+// @Description Создаёт станцию, привязанную к организации (org_id), с координатами, адресом и числом слотов (capacity)
 // @Tags stations
 // @Accept json
 // @Produce json
 // @Param station body CreateStationRequest true "Station Data"
+// @Param Idempotency-Key header string false "Ключ идемпотентности: повтор с тем же ключом вернёт исходный ответ"
 // @Success 201 {object} StationResponse
 // @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 422 {object} map[string]string
+// @Security BearerAuth
 // @Router /stations [post]
 func (app *application) CreateStationHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		OrgID int `json:"org_id"`
+		OrgID     int      `json:"org_id"`
+		Latitude  *float64 `json:"latitude"`
+		Longitude *float64 `json:"longitude"`
+		Address   string   `json:"address"`
+		Capacity  int      `json:"capacity"`
 	}
 
 	if err := app.readJSON(w, r, &input); err != nil {
@@ -60,7 +159,11 @@ func (app *application) CreateStationHandler(w http.ResponseWriter, r *http.Requ
 	}
 
 	station := &data.Station{
-		OrgID: input.OrgID,
+		OrgID:     input.OrgID,
+		Latitude:  input.Latitude,
+		Longitude: input.Longitude,
+		Address:   input.Address,
+		Capacity:  input.Capacity,
 	}
 
 	v := validator.New()
@@ -70,8 +173,19 @@ func (app *application) CreateStationHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	if err := app.models.Station.Insert(station); err != nil {
-		if errors.As(data.ErrInvalidForeignKey, &err) {
+	if !app.contextGetUser(r).CanManageOrganization(station.OrgID) {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	// Внешний ключ не отличает мягко удалённую организацию от живой.
+	if _, err := app.models.Organization.Get(station.OrgID); err != nil {
+		app.badRequestResponse(w, r, data.ErrInvalidForeignKey)
+		return
+	}
+
+	if err := app.models.Station.Insert(station, app.auditFor(r, nil)); err != nil {
+		if errors.Is(err, data.ErrInvalidForeignKey) {
 			app.badRequestResponse(w, r, err)
 			return
 		}
@@ -86,19 +200,89 @@ func (app *application) CreateStationHandler(w http.ResponseWriter, r *http.Requ
 }
 
 // UpdateStationHandler godoc
-// @Summary Обновляет станцию по ID
-// @Description Обновляет данные станции. Обновляются только переданные поля.
+// @Summary Заменяет станцию по ID
+// @Description Заменяет данные станции целиком: тело должно содержать все поля. Для частичного обновления используйте PATCH. С If-Match обновление пройдёт, только если версия не изменилась
 // @Tags stations
 // @Accept json
 // @Produce json
 // @Param id path int true "Station ID"
+// @Param If-Match header string false "ETag из GET /stations/{id}"
 // @Param station body UpdateStationRequest true "Station Data"
 // @Success 200 {object} StationResponse
+// @Header 200 {string} ETag "Новая версия станции"
 // @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 412 {object} map[string]string
 // @Failure 422 {object} map[string]string
+// @Security BearerAuth
 // @Router /stations/{id} [put]
 func (app *application) UpdateStationHandler(w http.ResponseWriter, r *http.Request) {
+	app.updateStation(w, r, func(station *data.Station, v *validator.Validator) error {
+		var input struct {
+			OrgID     int      `json:"org_id"`
+			Latitude  *float64 `json:"latitude"`
+			Longitude *float64 `json:"longitude"`
+			Address   string   `json:"address"`
+			Capacity  int      `json:"capacity"`
+		}
+
+		if err := app.readJSON(w, r, &input); err != nil {
+			return err
+		}
+
+		station.OrgID = input.OrgID
+		station.Latitude = input.Latitude
+		station.Longitude = input.Longitude
+		station.Address = input.Address
+		station.Capacity = input.Capacity
+		return nil
+	})
+}
+
+// PatchStationHandler godoc
+// @Summary Частично обновляет станцию по ID
+// @Description Применяет JSON Merge Patch (RFC 7396): меняются только переданные поля, null сбрасывает поле. Ошибки возвращаются по полям. С If-Match обновление пройдёт, только если версия не изменилась
+// @Tags stations
+// @Accept application/merge-patch+json
+// @Produce json
+// @Param id path int true "Station ID"
+// @Param If-Match header string false "ETag из GET /stations/{id}"
+// @Param station body PatchStationRequest true "Changed fields"
+// @Success 200 {object} StationResponse
+// @Header 200 {string} ETag "Новая версия станции"
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Security BearerAuth
+// @Router /stations/{id} [patch]
+func (app *application) PatchStationHandler(w http.ResponseWriter, r *http.Request) {
+	app.updateStation(w, r, func(station *data.Station, v *validator.Validator) error {
+		patch, err := app.readMergePatch(w, r, v, "org_id", "latitude", "longitude", "address", "capacity")
+		if err != nil {
+			return err
+		}
+
+		patchField(patch, "org_id", &station.OrgID, v)
+		patchField(patch, "latitude", &station.Latitude, v)
+		patchField(patch, "longitude", &station.Longitude, v)
+		patchField(patch, "address", &station.Address, v)
+		patchField(patch, "capacity", &station.Capacity, v)
+		return nil
+	})
+}
+
+// updateStation — общая часть PUT и PATCH: apply переносит тело запроса в
+// станцию, ошибка apply означает неразборчивое тело, а ошибки полей apply
+// добавляет в v.
+func (app *application) updateStation(w http.ResponseWriter, r *http.Request, apply func(*data.Station, *validator.Validator) error) {
 	id, err := app.readIDParam(r)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
@@ -111,51 +295,79 @@ func (app *application) UpdateStationHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	var input struct {
-		OrgID *int `json:"org_id"`
+	user := app.contextGetUser(r)
+	if !user.CanManageOrganization(station.OrgID) {
+		app.notPermittedResponse(w, r)
+		return
 	}
 
-	if err := app.readJSON(w, r, &input); err != nil {
-		app.badRequestResponse(w, r, err)
+	if !app.checkIfMatch(r, station.Version) {
+		app.preconditionFailedResponse(w, r)
 		return
 	}
 
-	if input.OrgID != nil {
-		station.OrgID = *input.OrgID
-	}
+	before := *station
 
 	v := validator.New()
+	if err := apply(station, v); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
 	data.ValidateStation(v, station)
 	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	if err := app.models.Station.Update(station); err != nil {
-		if errors.As(data.ErrInvalidForeignKey, &err) {
-			app.badRequestResponse(w, r, err)
+	if !user.CanManageOrganization(station.OrgID) {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	if station.OrgID != before.OrgID {
+		if _, err := app.models.Organization.Get(station.OrgID); err != nil {
+			app.badRequestResponse(w, r, data.ErrInvalidForeignKey)
 			return
 		}
-		app.serverErrorResponse(w, r, err)
+	}
+
+	if err := app.models.Station.Update(station, app.auditFor(r, &before)); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrInvalidForeignKey):
+			app.badRequestResponse(w, r, err)
+		case errors.Is(err, data.ErrCapacityBelowOccupied):
+			v.AddError("capacity", err.Error())
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
 	env := envelope{"station": station}
-	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+	if err := app.writeJSON(w, http.StatusOK, env, app.etagHeader(station.Version)); err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
 // DeleteStationHandler godoc
 // @Summary Удаляет станцию по ID
-// @Description Удаляет станцию с заданным идентификатором
+// @Description Мягко удаляет станцию вместе с повербанками на ней, кроме выданных в аренду. Восстанавливается через POST /stations/{id}/restore
 // @Tags stations
 // @Accept json
 // @Produce json
 // @Param id path int true "Station ID"
 // @Success 200 {object} MessageResponse
 // @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Security BearerAuth
 // @Router /stations/{id} [delete]
 func (app *application) DeleteStationHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
@@ -164,35 +376,512 @@ func (app *application) DeleteStationHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	if err := app.models.Station.Delete(int(id)); err != nil {
+	station, err := app.models.Station.Get(int(id))
+	if err != nil {
 		app.notFoundResponse(w, r)
 		return
 	}
 
+	if !app.contextGetUser(r).CanManageOrganization(station.OrgID) {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	if err := app.models.Station.Delete(int(id), app.auditFor(r, station)); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
 	env := envelope{"message": "station successfully deleted"}
 	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
+// RestoreStationHandler godoc
+// @Summary Восстанавливает удалённую станцию
+// @Description Восстанавливает мягко удалённую станцию вместе с повербанками, удалёнными вместе с ней. Станцию удалённой организации восстановить нельзя
+// @Tags stations
+// @Accept json
+// @Produce json
+// @Param id path int true "Station ID"
+// @Success 200 {object} StationResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /stations/{id}/restore [post]
+func (app *application) RestoreStationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	station, err := app.models.Station.GetWithDeleted(int(id))
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	if !app.contextGetUser(r).CanManageOrganization(station.OrgID) {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	station, err = app.models.Station.Restore(station.ID, app.auditFor(r, nil))
+	if err != nil {
+		app.restoreErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{"station": station}
+	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// TransferStationPowerbanksHandler godoc
+// @Summary Переносит повербанки на другую станцию
+// @Description Переносит повербанки powerbank_ids (до 100) со станции id на станцию to_station_id одной транзакцией: либо все, либо ни одного. Повербанки занимают первые свободные слоты станции назначения; если слотов не хватает, повербанк не на этой станции, в аренде или потерян, возвращается 409. Каждый перенос записывается в историю повербанка
+// @Tags stations
+// @Accept json
+// @Produce json
+// @Param id path int true "Station ID"
+// @Param transfer body TransferPowerbanksRequest true "Transfer Data"
+// @Param Idempotency-Key header string false "Ключ идемпотентности: повтор с тем же ключом вернёт исходный ответ"
+// @Success 200 {object} PowerbankTransferResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Security BearerAuth
+// @Router /stations/{id}/transfer [post]
+func (app *application) TransferStationPowerbanksHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var input struct {
+		PowerbankIDs []int `json:"powerbank_ids"`
+		ToStationID  int   `json:"to_station_id"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(len(input.PowerbankIDs) > 0, "powerbank_ids", "must contain at least one id")
+	v.Check(len(input.PowerbankIDs) <= 100, "powerbank_ids", "must not contain more than 100 ids")
+	v.Check(validator.Unique(input.PowerbankIDs), "powerbank_ids", "must not contain duplicate values")
+	for _, pid := range input.PowerbankIDs {
+		v.Check(pid > 0, "powerbank_ids", "must contain only positive integers")
+	}
+	v.Check(input.ToStationID > 0, "to_station_id", "must be a positive integer")
+	v.Check(input.ToStationID != int(id), "to_station_id", "must differ from the source station")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	allowed, err := app.canManageStation(r, int(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if !allowed {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	allowed, err = app.canManageStation(r, input.ToStationID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.badRequestResponse(w, r, data.ErrInvalidForeignKey)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if !allowed {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	moved, err := app.models.Powerbank.Transfer(int(id), input.ToStationID, input.PowerbankIDs, &app.contextGetUser(r).ID, app.auditFor(r, nil))
+	if err != nil {
+		var bulkErr *data.BulkError
+		switch {
+		case errors.As(err, &bulkErr) && errors.Is(err, data.ErrRecordNotFound):
+			app.failedValidationResponse(w, r, map[string]string{
+				"powerbank_ids": fmt.Sprintf("powerbank %d does not exist", input.PowerbankIDs[bulkErr.Index]),
+			})
+		case errors.As(err, &bulkErr):
+			message := fmt.Sprintf("powerbank %d: %s", input.PowerbankIDs[bulkErr.Index], bulkErr.Err)
+			app.errorResponse(w, r, http.StatusConflict, message)
+		case errors.Is(err, data.ErrStationFull):
+			app.errorResponse(w, r, http.StatusConflict, "destination station does not have enough free slots")
+		case errors.Is(err, data.ErrInvalidForeignKey):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env := envelope{"powerbanks": moved}
+	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
 // ListStationHandler godoc
 // @Summary Возвращает список станций
-// @Description Возвращает все станции
+// @Description Возвращает страницу станций с фильтром по организации
 // @Tags stations
 // @Accept json
 // @Produce json
+// @Param org_id query int false "Фильтр по организации"
+// @Param include_deleted query bool false "Включить мягко удалённые станции (только для администраторов)"
+// @Param page query int false "Номер страницы" default(1)
+// @Param page_size query int false "Размер страницы (до 100)" default(20)
+// @Param sort query string false "Сортировка: id, org_id, created_at; префикс - для убывания" default(id)
 // @Success 200 {object} StationListResponse
+// @Failure 422 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /stations [get]
 func (app *application) ListStationHandler(w http.ResponseWriter, r *http.Request) {
-	stations, err := app.models.Station.List()
+	var input struct {
+		OrgID          int
+		IncludeDeleted bool
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.OrgID = app.readInt(qs, "org_id", 0, v)
+	input.IncludeDeleted = app.readIncludeDeleted(r, v)
+	input.Filters = app.readStationFilters(qs, v)
+
+	v.Check(input.OrgID >= 0, "org_id", "must be a positive integer")
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	stations, metadata, err := app.models.Station.List(input.OrgID, input.IncludeDeleted, input.Filters)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	env := envelope{"stations": stations}
+	env := envelope{"stations": stations, "metadata": metadata}
 	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+// ListNearbyStationsHandler godoc
+// @Summary Ищет ближайшие станции
+// @Description Возвращает станции в радиусе radius метров от точки, отсортированные по расстоянию, вместе с доступностью повербанков
+// @Tags stations
+// @Accept json
+// @Produce json
+// @Param lat query number true "Широта"
+// @Param lon query number true "Долгота"
+// @Param radius query number false "Радиус поиска в метрах (до 50000)" default(2000)
+// @Param page query int false "Номер страницы" default(1)
+// @Param page_size query int false "Размер страницы (до 100)" default(20)
+// @Success 200 {object} StationListResponse
+// @Failure 422 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /stations/nearby [get]
+func (app *application) ListNearbyStationsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Latitude  float64
+		Longitude float64
+		Radius    float64
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	v.Check(qs.Get("lat") != "", "lat", "must be provided")
+	v.Check(qs.Get("lon") != "", "lon", "must be provided")
+
+	input.Latitude = app.readFloat(qs, "lat", 0, v)
+	input.Longitude = app.readFloat(qs, "lon", 0, v)
+	input.Radius = app.readFloat(qs, "radius", 2000, v)
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = "distance"
+	input.Filters.SortSafelist = []string{"distance"}
+
+	data.ValidateLatitude(v, "lat", input.Latitude)
+	data.ValidateLongitude(v, "lon", input.Longitude)
+	v.Check(input.Radius > 0, "radius", "must be greater than zero")
+	v.Check(input.Radius <= 50000, "radius", "must be a maximum of 50000 meters")
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	stations, metadata, err := app.models.Station.Nearby(input.Latitude, input.Longitude, input.Radius, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{"stations": stations, "metadata": metadata}
+	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// ImportStationsHandler godoc
+// @Summary Импортирует станции из CSV или NDJSON
+// @Description Создаёт станции одной транзакцией: либо все, либо ни одной. Тело — CSV с заголовком (колонки org_id, latitude, longitude, address, capacity в любом порядке) или NDJSON с теми же ключами, до 10000 строк. Каждая строка проверяется как при создании станции; при ошибках возвращается 422 со списком строк файла и их ошибок. С dry_run=true строки только проверяются, станции не создаются
+// @Tags stations
+// @Accept text/csv
+// @Accept application/x-ndjson
+// @Produce json
+// @Param dry_run query bool false "Только проверить файл" default(false)
+// @Success 200 {object} ImportResponse "dry_run: файл прошёл проверку"
+// @Success 201 {object} StationImportResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Failure 422 {object} ImportErrorResponse
+// @Security BearerAuth
+// @Router /stations/import [post]
+func (app *application) ImportStationsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	dryRun := app.readBool(r.URL.Query(), "dry_run", false, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	columns := []string{"org_id", "latitude", "longitude", "address", "capacity"}
+
+	rows, err := app.readImport(w, r, columns...)
+	if err != nil {
+		if errors.Is(err, errUnsupportedImportFormat) {
+			app.unsupportedImportFormatResponse(w, r)
+			return
+		}
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+	orgErrors := make(map[int]string)
+
+	stations := make([]*data.Station, len(rows))
+	var rowErrors []importRowError
+	for i, row := range rows {
+		station := &data.Station{}
+
+		v := row.check(columns...)
+		importField(row, "org_id", &station.OrgID, v)
+		importField(row, "latitude", &station.Latitude, v)
+		importField(row, "longitude", &station.Longitude, v)
+		importField(row, "address", &station.Address, v)
+		importField(row, "capacity", &station.Capacity, v)
+		data.ValidateStation(v, station)
+
+		if _, invalid := v.Errors["org_id"]; !invalid {
+			message, checked := orgErrors[station.OrgID]
+			if !checked {
+				switch {
+				case !user.CanManageOrganization(station.OrgID):
+					message = "must be an organization you manage"
+				default:
+					// Внешний ключ не отличает мягко удалённую организацию от живой.
+					if _, err := app.models.Organization.Get(station.OrgID); err != nil {
+						message = "organization does not exist"
+					}
+				}
+				orgErrors[station.OrgID] = message
+			}
+			if message != "" {
+				v.AddError("org_id", message)
+			}
+		}
+
+		if !v.Valid() {
+			rowErrors = append(rowErrors, importRowError{Line: row.line, Errors: v.Errors})
+		}
+		stations[i] = station
+	}
+
+	if len(rowErrors) > 0 {
+		app.importFailedResponse(w, r, rowErrors)
+		return
+	}
+
+	// Строки уже проверены целиком, включая организации: пробному импорту
+	// в базу идти незачем.
+	report := importReport{Rows: len(stations), DryRun: dryRun}
+	if dryRun {
+		if err := app.writeJSON(w, http.StatusOK, envelope{"import": report}, nil); err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Station.Import(stations, app.auditFor(r, nil))
+	if err != nil {
+		rowErrors, ok := importRowErrors(rows, err, func(err error) (string, string, bool) {
+			if errors.Is(err, data.ErrInvalidForeignKey) {
+				return "org_id", "organization does not exist", true
+			}
+			return "", "", false
+		})
+		if !ok {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		app.importFailedResponse(w, r, rowErrors)
+		return
+	}
+
+	env := envelope{"import": report, "stations": stations}
+	if err := app.writeJSON(w, http.StatusCreated, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// ExportStationsHandler godoc
+// @Summary Выгружает станции в CSV
+// @Description Возвращает все неудалённые станции по возрастанию id одним CSV-файлом, который отдаётся по мере чтения из базы. Оператор выгружает только станции своей организации, администратор без org_id — все станции. Адрес, начинающийся с = + - @, выгружается с апострофом в начале, чтобы табличный редактор не выполнил его как формулу Если база отказала посреди выгрузки, файл заканчивается строкой #export-aborted, а трейлер X-Export-Status равен error; у полной выгрузки он равен complete
+// @Tags stations
+// @Produce text/csv
+// @Param org_id query int false "ID организации"
+// @Success 200 {string} string "CSV: id, org_id, latitude, longitude, address, capacity, created_at, updated_at, version"
+// @Header 200 {string} X-Export-Status "Трейлер: complete или error"
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /stations/export [get]
+func (app *application) ExportStationsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	orgID, allowed := app.readExportOrgID(r, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	if !allowed {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	export := newCSVExport(w, "stations.csv",
+		"id", "org_id", "latitude", "longitude", "address", "capacity", "created_at", "updated_at", "version")
+
+	err := app.models.Station.Export(orgID, func(s *data.Station) error {
+		return export.write([]string{
+			strconv.Itoa(s.ID),
+			strconv.Itoa(s.OrgID),
+			csvOptionalFloat(s.Latitude),
+			csvOptionalFloat(s.Longitude),
+			csvText(s.Address),
+			strconv.Itoa(s.Capacity),
+			s.CreatedAt.Format(time.RFC3339),
+			s.UpdatedAt.Format(time.RFC3339),
+			strconv.Itoa(s.Version),
+		})
+	})
+	app.finishCSVExport(w, r, export, err)
+}
+
+// ListStationPowerbanksHandler godoc
+// @Summary Возвращает повербанки станции
+// @Description Возвращает страницу повербанков, числящихся за станцией, с фильтром по статусу. Для несуществующей станции возвращает 404
+// @Tags stations
+// @Accept json
+// @Produce json
+// @Param id path int true "Station ID"
+// @Param status query string false "Статусы через запятую: rented, available, charging, lost, maintenance"
+// @Param include_deleted query bool false "Включить мягко удалённые повербанки и станцию (только для администраторов)"
+// @Param page query int false "Номер страницы" default(1)
+// @Param page_size query int false "Размер страницы (до 100)" default(20)
+// @Param sort query string false "Сортировка: id, status, current_station_id, created_at; префикс - для убывания" default(id)
+// @Success 200 {object} PowerbankListResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /stations/{id}/powerbanks [get]
+func (app *application) ListStationPowerbanksHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	statuses := app.readPowerbankStatuses(qs, v)
+	includeDeleted := app.readIncludeDeleted(r, v)
+	filters := app.readPowerbankFilters(qs, v)
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	getStation := app.models.Station.Get
+	if includeDeleted {
+		getStation = app.models.Station.GetWithDeleted
+	}
+	if _, err := getStation(int(id)); err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	powerbanks, metadata, err := app.models.Powerbank.List(statuses, int(id), includeDeleted, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{"powerbanks": powerbanks, "metadata": metadata}
+	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readStationFilters читает страницу и сортировку списка станций.
+func (app *application) readStationFilters(qs url.Values, v *validator.Validator) data.Filters {
+	return data.Filters{
+		Page:         app.readInt(qs, "page", 1, v),
+		PageSize:     app.readInt(qs, "page_size", 20, v),
+		Sort:         app.readString(qs, "sort", "id"),
+		SortSafelist: []string{"id", "org_id", "created_at", "-id", "-org_id", "-created_at"},
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/olzzhas/qrent/internal/data"
+	"github.com/olzzhas/qrent/pkg/validator"
+)
+
+// GetStationTariffHandler godoc
+// @Summary Возвращает тариф станции
+// @Description Возвращает действующий тариф станции: собственный тариф станции или тариф её организации. Для несуществующей или удалённой станции — 404
+// @Tags tariffs
+// @Accept json
+// @Produce json
+// @Param id path int true "Station ID"
+// @Success 200 {object} TariffResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /stations/{id}/tariff [get]
+func (app *application) GetStationTariffHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Тариф организации подошёл бы к любому id, поэтому сначала убеждаемся,
+	// что станция существует и не удалена.
+	if _, err := app.models.Station.Get(int(id)); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	tariff, err := app.models.Tariff.GetForStation(int(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env := envelope{"tariff": tariff}
+	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// GetOrganizationTariffHandler godoc
+// @Summary Возвращает тариф организации
+// @Description Возвращает тариф организации, действующий на станциях без собственного тарифа
+// @Tags tariffs
+// @Accept json
+// @Produce json
+// @Param id path int true "Organization ID"
+// @Success 200 {object} TariffResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /organizations/{id}/tariff [get]
+func (app *application) GetOrganizationTariffHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	tariff, err := app.models.Tariff.GetForOrganization(int(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env := envelope{"tariff": tariff}
+	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// UpdateOrganizationTariffHandler godoc
+// @Summary Задаёт тариф организации
+// @Description Создаёт или заменяет тариф организации по умолчанию. Суммы указываются в минимальных единицах валюты
+// @Tags tariffs
+// @Accept json
+// @Produce json
+// @Param id path int true "Organization ID"
+// @Param tariff body UpsertTariffRequest true "Tariff Data"
+// @Success 200 {object} TariffResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Security BearerAuth
+// @Router /organizations/{id}/tariff [put]
+func (app *application) UpdateOrganizationTariffHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	org, err := app.models.Organization.Get(int(id))
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	if !app.contextGetUser(r).CanManageOrganization(org.ID) {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	app.upsertTariff(w, r, &data.Tariff{OrgID: &org.ID})
+}
+
+// UpdateStationTariffHandler godoc
+// @Summary Задаёт тариф станции
+// @Description Создаёт или заменяет собственный тариф станции, который действует вместо тарифа организации
+// @Tags tariffs
+// @Accept json
+// @Produce json
+// @Param id path int true "Station ID"
+// @Param tariff body UpsertTariffRequest true "Tariff Data"
+// @Success 200 {object} TariffResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Security BearerAuth
+// @Router /stations/{id}/tariff [put]
+func (app *application) UpdateStationTariffHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	station, err := app.models.Station.Get(int(id))
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	if !app.contextGetUser(r).CanManageOrganization(station.OrgID) {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	app.upsertTariff(w, r, &data.Tariff{StationID: &station.ID})
+}
+
+// upsertTariff читает полное представление тарифа из тела запроса, проверяет
+// его и сохраняет для владельца, уже заданного в tariff.
+func (app *application) upsertTariff(w http.ResponseWriter, r *http.Request, tariff *data.Tariff) {
+	var input struct {
+		Currency      string `json:"currency"`
+		UnlockFee     int64  `json:"unlock_fee"`
+		PeriodPrice   int64  `json:"period_price"`
+		PeriodMinutes *int   `json:"period_minutes"`
+		FreeMinutes   int    `json:"free_minutes"`
+		DailyCap      *int64 `json:"daily_cap"`
+		LostPenalty   int64  `json:"lost_penalty"`
+		LateFee       int64  `json:"late_fee"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	tariff.Currency = input.Currency
+	if tariff.Currency == "" {
+		tariff.Currency = "KZT"
+	}
+	tariff.UnlockFee = input.UnlockFee
+	tariff.PeriodPrice = input.PeriodPrice
+	tariff.PeriodMinutes = 60
+	if input.PeriodMinutes != nil {
+		tariff.PeriodMinutes = *input.PeriodMinutes
+	}
+	tariff.FreeMinutes = input.FreeMinutes
+	tariff.DailyCap = input.DailyCap
+	tariff.LostPenalty = input.LostPenalty
+	tariff.LateFee = input.LateFee
+
+	v := validator.New()
+	data.ValidateTariff(v, tariff)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Tariff.Upsert(tariff, app.auditFor(r, nil)); err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidForeignKey):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env := envelope{"tariff": tariff}
+	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// DeleteStationTariffHandler godoc
+// @Summary Удаляет тариф станции
+// @Description Удаляет собственный тариф станции; после этого на ней действует тариф организации
+// @Tags tariffs
+// @Accept json
+// @Produce json
+// @Param id path int true "Station ID"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /stations/{id}/tariff [delete]
+func (app *application) DeleteStationTariffHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	station, err := app.models.Station.Get(int(id))
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	if !app.contextGetUser(r).CanManageOrganization(station.OrgID) {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	if err := app.models.Tariff.DeleteForStation(station.ID, app.auditFor(r, nil)); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env := envelope{"message": "station tariff successfully deleted"}
+	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"github.com/olzzhas/qrent/internal/data"
+	"github.com/olzzhas/qrent/pkg/validator"
+	"net/http"
+	"time"
+)
+
+// CreateAuthenticationTokenHandler godoc
+// @Summary Выдаёт токен аутентификации
+// @Description Проверяет email и пароль и возвращает bearer-токен
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param credentials body CreateAuthenticationTokenRequest true "Credentials"
+// @Success 201 {object} AuthenticationTokenResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 422 {object} map[string]string
+// @Router /tokens/authentication [post]
+func (app *application) CreateAuthenticationTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	data.ValidateEmail(v, input.Email)
+	data.ValidatePasswordPlaintext(v, input.Password)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user, err := app.models.User.GetByEmail(input.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.invalidCredentialsResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	match, err := user.Password.Matches(input.Password)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !match {
+		app.invalidCredentialsResponse(w, r)
+		return
+	}
+
+	token, err := app.models.Token.New(user.ID, 24*time.Hour, data.ScopeAuthentication)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{"authentication_token": token}
+	if err := app.writeJSON(w, http.StatusCreated, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -10,6 +10,12 @@ type OrganizationResponse struct {
 // OrganizationListResponse описывает структуру ответа со списком объектов Organization.
 type OrganizationListResponse struct {
 	Organizations []data.Organization `json:"organizations"`
+	Metadata      data.Metadata       `json:"metadata"`
+}
+
+// RebalancePlanResponse описывает ответ с планом перебалансировки станций.
+type RebalancePlanResponse struct {
+	RebalancePlan data.RebalancePlan `json:"rebalance_plan"`
 }
 
 // MessageResponse описывает структуру ответа с полем message (например, при удалении).
@@ -27,6 +33,7 @@ type PowerbankResponse struct {
 // swagger:model
 type PowerbankListResponse struct {
 	Powerbanks []data.Powerbank `json:"powerbanks"`
+	Metadata   data.Metadata    `json:"metadata"`
 }
 
 // StationResponse описывает ответ с одной Station
@@ -39,6 +46,71 @@ type StationResponse struct {
 // swagger:model
 type StationListResponse struct {
 	Stations []data.Station `json:"stations"`
+	Metadata data.Metadata  `json:"metadata"`
+}
+
+// TransferPowerbanksRequest описывает тело запроса для переноса повербанков
+// на другую станцию.
+type TransferPowerbanksRequest struct {
+	PowerbankIDs []int `json:"powerbank_ids"`
+	ToStationID  int   `json:"to_station_id"`
+}
+
+// PowerbankTransferResponse описывает ответ с перенесёнными Powerbank
+// swagger:model
+type PowerbankTransferResponse struct {
+	Powerbanks []data.Powerbank `json:"powerbanks"`
+}
+
+// StationSlotListResponse описывает ответ со слотами Station
+// swagger:model
+type StationSlotListResponse struct {
+	Slots []data.StationSlot `json:"slots"`
+}
+
+// StationAvailabilityResponse описывает ответ с доступностью Station
+// swagger:model
+type StationAvailabilityResponse struct {
+	Availability data.StationAvailability `json:"availability"`
+}
+
+// RentalResponse описывает ответ с одной Rental
+// swagger:model
+type RentalResponse struct {
+	Rental data.Rental `json:"rental"`
+}
+
+// RentalListResponse описывает ответ со списком Rental
+// swagger:model
+type RentalListResponse struct {
+	Rentals  []data.Rental `json:"rentals"`
+	Metadata data.Metadata `json:"metadata"`
+}
+
+// PowerbankEventListResponse описывает ответ с историей повербанка
+// swagger:model
+type PowerbankEventListResponse struct {
+	Events   []data.PowerbankEvent `json:"events"`
+	Metadata data.Metadata         `json:"metadata"`
+}
+
+// AuditListResponse описывает ответ с журналом аудита
+// swagger:model
+type AuditListResponse struct {
+	Audit    []data.AuditEntry `json:"audit"`
+	Metadata data.Metadata     `json:"metadata"`
+}
+
+// UserResponse описывает ответ с одним User
+// swagger:model
+type UserResponse struct {
+	User data.User `json:"user"`
+}
+
+// AuthenticationTokenResponse описывает ответ с токеном аутентификации
+// swagger:model
+type AuthenticationTokenResponse struct {
+	AuthenticationToken data.Token `json:"authentication_token"`
 }
 
 // requests
@@ -51,10 +123,17 @@ type CreateOrganizationRequest struct {
 	Location string `json:"location"`
 }
 
-// UpdateOrganizationRequest описывает тело запроса для обновления организации.
+// UpdateOrganizationRequest описывает полное представление организации для PUT.
 type UpdateOrganizationRequest struct {
-	Name     *string `json:"name"`
-	Location *string `json:"location"`
+	Name     string `json:"name"`
+	Location string `json:"location"`
+}
+
+// PatchOrganizationRequest описывает JSON Merge Patch организации: все поля
+// необязательны.
+type PatchOrganizationRequest struct {
+	Name     *string `json:"name,omitempty"`
+	Location *string `json:"location,omitempty"`
 }
 
 // Powerbank
@@ -62,25 +141,142 @@ type UpdateOrganizationRequest struct {
 // CreatePowerbankRequest описывает тело запроса для создания повербанка.
 type CreatePowerbankRequest struct {
 	CurrentStationID int    `json:"current_station_id"`
+	SlotNumber       *int   `json:"slot_number"`
 	Status           string `json:"status"`
 }
 
-// UpdatePowerbankRequest описывает тело запроса для обновления повербанка.
+// UpdatePowerbankRequest описывает полное представление повербанка для PUT.
 type UpdatePowerbankRequest struct {
-	CurrentStationID *int    `json:"current_station_id"`
-	Status           *string `json:"status"`
+	CurrentStationID int    `json:"current_station_id"`
+	SlotNumber       *int   `json:"slot_number"`
+	Status           string `json:"status"`
+}
+
+// PatchPowerbankRequest описывает JSON Merge Patch повербанка: все поля
+// необязательны.
+type PatchPowerbankRequest struct {
+	CurrentStationID *int    `json:"current_station_id,omitempty"`
+	SlotNumber       *int    `json:"slot_number,omitempty"`
+	Status           *string `json:"status,omitempty"`
 }
 
 // Station
 
 // CreateStationRequest описывает тело запроса для создания станции.
 type CreateStationRequest struct {
-	OrgID int `json:"org_id"`
+	OrgID     int     `json:"org_id"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Address   string  `json:"address"`
+	Capacity  int     `json:"capacity"`
 }
 
-// UpdateStationRequest описывает тело запроса для обновления станции.
+// UpdateStationRequest описывает полное представление станции для PUT.
 type UpdateStationRequest struct {
-	OrgID *int `json:"org_id"`
+	OrgID     int     `json:"org_id"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Address   string  `json:"address"`
+	Capacity  int     `json:"capacity"`
+}
+
+// PatchStationRequest описывает JSON Merge Patch станции: все поля
+// необязательны.
+type PatchStationRequest struct {
+	OrgID     *int     `json:"org_id,omitempty"`
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+	Address   *string  `json:"address,omitempty"`
+	Capacity  *int     `json:"capacity,omitempty"`
+}
+
+// Rental
+
+// StartRentalRequest описывает тело запроса для начала аренды.
+type StartRentalRequest struct {
+	PowerbankID int `json:"powerbank_id"`
+	StationID   int `json:"station_id"`
+}
+
+// ReturnRentalRequest описывает тело запроса для возврата повербанка.
+type ReturnRentalRequest struct {
+	StationID  int  `json:"station_id"`
+	SlotNumber *int `json:"slot_number"`
+}
+
+// User
+
+// RegisterUserRequest описывает тело запроса для регистрации пользователя.
+type RegisterUserRequest struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// ActivateUserRequest описывает тело запроса для активации пользователя.
+type ActivateUserRequest struct {
+	Token string `json:"token"`
+}
+
+// CreateAuthenticationTokenRequest описывает тело запроса для получения токена.
+type CreateAuthenticationTokenRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// UpdateUserRoleRequest описывает тело запроса для назначения роли пользователю.
+type UpdateUserRoleRequest struct {
+	Role  string `json:"role"`
+	OrgID *int   `json:"org_id"`
+}
+
+// QR
+
+// ScanRequest описывает тело запроса с отсканированным QR-кодом.
+type ScanRequest struct {
+	Payload string `json:"payload"`
+}
+
+// ScanResponse описывает станцию и повербанки, найденные по QR-коду.
+// swagger:model
+type ScanResponse struct {
+	Station    data.Station     `json:"station"`
+	Powerbank  *data.Powerbank  `json:"powerbank,omitempty"`
+	Powerbanks []data.Powerbank `json:"powerbanks"`
+}
+
+// Tariff
+
+// TariffResponse описывает ответ с одним Tariff
+// swagger:model
+type TariffResponse struct {
+	Tariff data.Tariff `json:"tariff"`
+}
+
+// UpsertTariffRequest описывает полное представление тарифа. Суммы указываются
+// в минимальных единицах валюты.
+type UpsertTariffRequest struct {
+	Currency      string `json:"currency"`
+	UnlockFee     int64  `json:"unlock_fee"`
+	PeriodPrice   int64  `json:"period_price"`
+	PeriodMinutes int    `json:"period_minutes"`
+	FreeMinutes   int    `json:"free_minutes"`
+	DailyCap      *int64 `json:"daily_cap"`
+	LostPenalty   int64  `json:"lost_penalty"`
+	LateFee       int64  `json:"late_fee"`
+}
+
+// Payment
+
+// PaymentListResponse описывает ответ со списком Payment
+// swagger:model
+type PaymentListResponse struct {
+	Payments []data.Payment `json:"payments"`
+}
+
+// RefundRequest описывает тело запроса для возврата денег за аренду.
+type RefundRequest struct {
+	Amount int64 `json:"amount"`
 }
 
 // ErrorResponse описывает ответ с ошибкой.
@@ -88,3 +284,45 @@ type UpdateStationRequest struct {
 type ErrorResponse struct {
 	Error string `json:"error"`
 }
+
+// Import
+
+// ImportReport описывает итог импорта.
+type ImportReport struct {
+	Rows   int  `json:"rows"`
+	DryRun bool `json:"dry_run"`
+}
+
+// ImportResponse описывает ответ на проверку файла импорта (dry_run).
+// swagger:model
+type ImportResponse struct {
+	Import ImportReport `json:"import"`
+}
+
+// StationImportResponse описывает ответ с импортированными Station
+// swagger:model
+type StationImportResponse struct {
+	Import   ImportReport   `json:"import"`
+	Stations []data.Station `json:"stations"`
+}
+
+// PowerbankImportResponse описывает ответ с импортированными Powerbank
+// swagger:model
+type PowerbankImportResponse struct {
+	Import     ImportReport     `json:"import"`
+	Powerbanks []data.Powerbank `json:"powerbanks"`
+}
+
+// ImportRowError описывает ошибки одной строки файла импорта.
+type ImportRowError struct {
+	Line   int               `json:"line"`
+	Errors map[string]string `json:"errors"`
+}
+
+// ImportErrorResponse описывает ответ 422 на файл импорта с ошибками.
+// swagger:model
+type ImportErrorResponse struct {
+	Error struct {
+		Rows []ImportRowError `json:"rows"`
+	} `json:"error"`
+}
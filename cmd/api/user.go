@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/olzzhas/qrent/internal/data"
+	"github.com/olzzhas/qrent/pkg/validator"
+	"github.com/streadway/amqp"
+	"net/http"
+	"time"
+)
+
+// activationQueue — очередь RabbitMQ с письмами активации. API писем не
+// отправляет: их забирает из очереди почтовый сервис.
+const activationQueue = "activations"
+
+// publishActivation ставит письмо активации для user с токеном token в
+// очередь activationQueue. Очередь и сообщения устойчивы к перезапуску
+// брокера.
+func (app *application) publishActivation(user *data.User, token *data.Token) error {
+	body, err := json.Marshal(map[string]any{
+		"user_id": user.ID,
+		"name":    user.Name,
+		"email":   user.Email,
+		"token":   token.Plaintext,
+		"expiry":  token.Expiry,
+	})
+	if err != nil {
+		return err
+	}
+
+	ch, err := app.rabbitMQ.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	q, err := ch.QueueDeclare(activationQueue, true, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+
+	return ch.Publish("", q.Name, false, false, amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		Body:         body,
+	})
+}
+
+// RegisterUserHandler godoc
+// @Summary Регистрирует нового пользователя
+// @Description Создаёт неактивированного пользователя и выпускает токен активации. Токен уходит письмом: API ставит его в очередь RabbitMQ activations, откуда письмо отправляет почтовый сервис. Если поставить письмо в очередь не удалось, пользователь не создаётся
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param user body RegisterUserRequest true "User Data"
+// @Success 201 {object} UserResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 422 {object} map[string]string
+// @Router /users [post]
+func (app *application) RegisterUserHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name     string `json:"name"`
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := &data.User{
+		Name:      input.Name,
+		Email:     input.Email,
+		Activated: false,
+		Role:      data.RoleRenter,
+	}
+
+	if err := user.Password.Set(input.Password); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	data.ValidateUser(v, user)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	token, err := app.models.User.Register(user, 3*24*time.Hour, func(token *data.Token) error {
+		return app.publishActivation(user, token)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateEmail):
+			v.AddError("email", "a user with this email address already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env := envelope{"user": user}
+
+	// Токен активации отдаётся в ответе только при явно включённом
+	// EXPOSE_ACTIVATION_TOKEN: так регистрацию можно завершить при разработке
+	// без почтового сервиса.
+	if app.config.auth.exposeActivationToken {
+		env["activation_token"] = token
+	}
+
+	if err := app.writeJSON(w, http.StatusCreated, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// ActivateUserHandler godoc
+// @Summary Активирует пользователя
+// @Description Активирует учётную запись по токену активации
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param token body ActivateUserRequest true "Activation Token"
+// @Success 200 {object} UserResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 422 {object} map[string]string
+// @Router /users/activated [put]
+func (app *application) ActivateUserHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		TokenPlaintext string `json:"token"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateTokenPlaintext(v, input.TokenPlaintext); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user, err := app.models.User.GetForToken(data.ScopeActivation, input.TokenPlaintext)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired activation token")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user.Activated = true
+
+	if err := app.models.User.Update(user); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.models.Token.DeleteAllForUser(data.ScopeActivation, user.ID); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{"user": user}
+	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// UpdateUserRoleHandler godoc
+// @Summary Назначает роль пользователю
+// @Description Меняет роль пользователя (admin, operator, renter) и привязку оператора к организации; права пересчитываются по роли
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param role body UpdateUserRoleRequest true "Role Data"
+// @Success 200 {object} UserResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 422 {object} map[string]string
+// @Security BearerAuth
+// @Router /users/{id}/role [put]
+func (app *application) UpdateUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user, err := app.models.User.Get(int(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Role  string `json:"role"`
+		OrgID *int   `json:"org_id"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	before := *user
+	user.Role = data.Role(input.Role)
+	user.OrgID = input.OrgID
+
+	v := validator.New()
+	data.ValidateUser(v, user)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.User.UpdateRole(user, app.auditFor(r, &before)); err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidForeignKey):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env := envelope{"user": user}
+	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
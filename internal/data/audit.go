@@ -0,0 +1,292 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+type AuditAction string
+
+const (
+	AuditActionCreate  AuditAction = "create"
+	AuditActionUpdate  AuditAction = "update"
+	AuditActionDelete  AuditAction = "delete"
+	AuditActionRestore AuditAction = "restore"
+)
+
+const (
+	AuditResourceOrganization = "organization"
+	AuditResourceStation      = "station"
+	AuditResourcePowerbank    = "powerbank"
+	AuditResourceUser         = "user"
+	AuditResourceTariff       = "tariff"
+	AuditResourcePayment      = "payment"
+)
+
+// AuditResources — типы ресурсов, по которым ведётся аудит.
+var AuditResources = []string{
+	AuditResourceOrganization,
+	AuditResourceStation,
+	AuditResourcePowerbank,
+	AuditResourceUser,
+	AuditResourceTariff,
+	AuditResourcePayment,
+}
+
+// AuditEntry — запись журнала аудита. Before и After содержат только
+// изменившиеся поля; у создания Before пуст, у удаления пуст After.
+type AuditEntry struct {
+	ID           int64           `json:"id"`
+	ActorID      *int            `json:"actor_id"`
+	Action       AuditAction     `json:"action"`
+	ResourceType string          `json:"resource_type"`
+	ResourceID   int             `json:"resource_id"`
+	Before       json.RawMessage `json:"before" swaggertype:"object"`
+	After        json.RawMessage `json:"after" swaggertype:"object"`
+	IP           string          `json:"ip"`
+	RequestID    string          `json:"request_id"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+// AuditFilter — условия выборки журнала; нулевые значения не ограничивают.
+type AuditFilter struct {
+	ResourceType string
+	ResourceID   int
+	ActorID      int
+	From         *time.Time
+	To           *time.Time
+}
+
+type AuditModel struct {
+	DB *sql.DB
+}
+
+// Audit — автор изменения для журнала аудита. Модели пишут запись в той же
+// транзакции, что и само изменение, поэтому изменение и запись о нём
+// фиксируются или откатываются вместе. nil — изменение без аудита.
+type Audit struct {
+	ActorID   *int
+	IP        string
+	RequestID string
+	// Before — ресурс до правки или удаления, каким его видел вызывающий код.
+	Before any
+}
+
+// execer — общее у *sql.DB и *sql.Tx для запросов без результата.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// entry строит запись об изменении ресурса resourceType с идентификатором
+// resourceID.
+func (a *Audit) entry(action AuditAction, resourceType string, resourceID int, before, after any) (*AuditEntry, error) {
+	e := &AuditEntry{
+		ActorID:      a.ActorID,
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		IP:           a.IP,
+		RequestID:    a.RequestID,
+	}
+
+	var err error
+	e.Before, e.After, err = AuditDiff(before, after)
+	if err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// record пишет в журнал одно изменение в транзакции q.
+func (a *Audit) record(ctx context.Context, q execer, action AuditAction, resourceType string, resourceID int, before, after any) error {
+	if a == nil {
+		return nil
+	}
+
+	e, err := a.entry(action, resourceType, resourceID, before, after)
+	if err != nil {
+		return err
+	}
+
+	return insertAuditEntries(ctx, q, e)
+}
+
+// auditChange — изменение одного ресурса в пакетной операции.
+type auditChange struct {
+	ID            int
+	Before, After any
+}
+
+// recordBatch пишет в журнал изменения пакетной операции одним запросом в
+// транзакции q.
+func (a *Audit) recordBatch(ctx context.Context, q execer, action AuditAction, resourceType string, changes []auditChange) error {
+	if a == nil {
+		return nil
+	}
+
+	entries := make([]*AuditEntry, 0, len(changes))
+	for _, c := range changes {
+		e, err := a.entry(action, resourceType, c.ID, c.Before, c.After)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, e)
+	}
+
+	return insertAuditEntries(ctx, q, entries...)
+}
+
+// before возвращает Before или nil, если аудита нет.
+func (a *Audit) before() any {
+	if a == nil {
+		return nil
+	}
+	return a.Before
+}
+
+// insertAuditEntries пишет записи одним запросом, сколько бы их ни было:
+// пакетные операции не должны делать по запросу на каждую строку.
+func insertAuditEntries(ctx context.Context, q execer, entries ...*AuditEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	js, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.ExecContext(ctx, `
+		INSERT INTO audit_log (actor_id, action, resource_type, resource_id, before, after, ip, request_id)
+		SELECT actor_id, action, resource_type, resource_id, NULLIF(before, 'null'), NULLIF(after, 'null'), ip, request_id
+		FROM jsonb_to_recordset($1::jsonb) AS e(actor_id integer, action text, resource_type text,
+			resource_id integer, before jsonb, after jsonb, ip text, request_id text)
+	`, string(js))
+	return err
+}
+
+// AuditDiff сравнивает JSON-представления ресурса до и после изменения и
+// возвращает только отличающиеся поля. nil (в том числе nil-указатель)
+// означает отсутствие ресурса: тогда другая сторона возвращается целиком.
+func AuditDiff(before, after any) (json.RawMessage, json.RawMessage, error) {
+	b, err := auditFields(before)
+	if err != nil {
+		return nil, nil, err
+	}
+	a, err := auditFields(after)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if b == nil || a == nil {
+		return marshalAuditFields(b), marshalAuditFields(a), nil
+	}
+
+	changedBefore := make(map[string]any)
+	changedAfter := make(map[string]any)
+	for key, value := range a {
+		if old, ok := b[key]; !ok || !reflect.DeepEqual(old, value) {
+			changedBefore[key] = b[key]
+			changedAfter[key] = value
+		}
+	}
+	for key, old := range b {
+		if _, ok := a[key]; !ok {
+			changedBefore[key] = old
+			changedAfter[key] = nil
+		}
+	}
+
+	return marshalAuditFields(changedBefore), marshalAuditFields(changedAfter), nil
+}
+
+func auditFields(v any) (map[string]any, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	js, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(js, &fields); err != nil {
+		return nil, fmt.Errorf("audit: resource must be a JSON object: %w", err)
+	}
+
+	return fields, nil
+}
+
+func marshalAuditFields(fields map[string]any) json.RawMessage {
+	if fields == nil {
+		return nil
+	}
+
+	// Карта из json.Unmarshal всегда сериализуется обратно без ошибок.
+	js, _ := json.Marshal(fields)
+	return js
+}
+
+// List возвращает страницу журнала аудита по условиям f.
+func (m AuditModel) List(f AuditFilter, filters Filters) ([]*AuditEntry, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, actor_id, action, resource_type, resource_id, before, after,
+			ip, request_id, created_at
+		FROM audit_log
+		WHERE (resource_type = $1 OR $1 = '')
+		AND (resource_id = $2 OR $2 = 0)
+		AND (actor_id = $3 OR $3 = 0)
+		AND (created_at >= $4 OR $4 IS NULL)
+		AND (created_at < $5 OR $5 IS NULL)
+		ORDER BY %s %s
+		LIMIT $6 OFFSET $7
+	`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []any{f.ResourceType, f.ResourceID, f.ActorID, f.From, f.To, filters.limit(), filters.offset()}
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	entries := make([]*AuditEntry, 0)
+	for rows.Next() {
+		var (
+			e             AuditEntry
+			before, after []byte
+		)
+		if err := rows.Scan(
+			&totalRecords,
+			&e.ID,
+			&e.ActorID,
+			&e.Action,
+			&e.ResourceType,
+			&e.ResourceID,
+			&before,
+			&after,
+			&e.IP,
+			&e.RequestID,
+			&e.CreatedAt,
+		); err != nil {
+			return nil, Metadata{}, err
+		}
+		e.Before, e.After = before, after
+		entries = append(entries, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return entries, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
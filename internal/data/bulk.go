@@ -0,0 +1,25 @@
+package data
+
+import (
+	"fmt"
+	"time"
+)
+
+// bulkTimeout — таймаут импорта и экспорта: тысячи строк не укладываются в
+// обычные 3 секунды одного запроса.
+const bulkTimeout = time.Minute
+
+// BulkError — ошибка одной записи пакетной вставки. Index — позиция записи
+// в пакете, Err — ошибка, которую вернула бы вставка этой записи по одной.
+type BulkError struct {
+	Index int
+	Err   error
+}
+
+func (e *BulkError) Error() string {
+	return fmt.Sprintf("record %d: %v", e.Index, e.Err)
+}
+
+func (e *BulkError) Unwrap() error {
+	return e.Err
+}
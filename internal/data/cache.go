@@ -0,0 +1,152 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"time"
+)
+
+const (
+	organizationCacheTTL = 5 * time.Minute
+	stationCacheTTL      = 30 * time.Second
+	powerbankCacheTTL    = 15 * time.Second
+)
+
+var (
+	cacheHits   = expvar.NewInt("cache_hits")
+	cacheMisses = expvar.NewInt("cache_misses")
+)
+
+// cacheGet читает значение из Redis в dst. Ошибки Redis считаются промахом:
+// кэш не должен ломать чтение из Postgres. Без клиента Redis кэш выключен.
+func cacheGet(rdb *redis.Client, key string, dst any) bool {
+	if rdb == nil || key == "" {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	js, err := rdb.Get(ctx, key).Bytes()
+	if err != nil {
+		cacheMisses.Add(1)
+		return false
+	}
+
+	if err := json.Unmarshal(js, dst); err != nil {
+		cacheMisses.Add(1)
+		return false
+	}
+
+	cacheHits.Add(1)
+	return true
+}
+
+func cacheSet(rdb *redis.Client, key string, value any, ttl time.Duration) {
+	if rdb == nil || key == "" {
+		return
+	}
+
+	js, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rdb.Set(ctx, key, js, ttl)
+}
+
+func cacheDelete(rdb *redis.Client, keys ...string) {
+	if rdb == nil || len(keys) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rdb.Del(ctx, keys...)
+}
+
+// cacheListKey строит ключ списка ресурса с учётом поколения. Любая запись
+// в ресурс увеличивает поколение, и все закэшированные списки разом
+// становятся недостижимыми, не требуя перебора ключей.
+func cacheListKey(rdb *redis.Client, resource string, params ...any) string {
+	if rdb == nil {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	generation, err := rdb.Get(ctx, resource+":list:generation").Int64()
+	if err != nil && err != redis.Nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%s:list:%d:%v", resource, generation, params)
+}
+
+func cacheBumpList(rdb *redis.Client, resource string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rdb.Incr(ctx, resource+":list:generation")
+}
+
+func organizationCacheKey(id int) string {
+	return fmt.Sprintf("organizations:%d", id)
+}
+
+func stationCacheKey(id int) string {
+	return fmt.Sprintf("stations:%d", id)
+}
+
+func powerbankCacheKey(id int) string {
+	return fmt.Sprintf("powerbanks:%d", id)
+}
+
+func invalidateOrganizations(rdb *redis.Client, ids ...int) {
+	if rdb == nil {
+		return
+	}
+
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, organizationCacheKey(id))
+	}
+	cacheDelete(rdb, keys...)
+	cacheBumpList(rdb, "organizations")
+}
+
+func invalidateStations(rdb *redis.Client, ids ...int) {
+	if rdb == nil {
+		return
+	}
+
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, stationCacheKey(id))
+	}
+	cacheDelete(rdb, keys...)
+	cacheBumpList(rdb, "stations")
+}
+
+func invalidatePowerbanks(rdb *redis.Client, ids ...int) {
+	if rdb == nil {
+		return
+	}
+
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, powerbankCacheKey(id))
+	}
+	cacheDelete(rdb, keys...)
+	cacheBumpList(rdb, "powerbanks")
+	// Список станций содержит счётчики доступности повербанков.
+	cacheBumpList(rdb, "stations")
+}
@@ -9,12 +9,23 @@ import (
 var (
 	ErrInvalidForeignKey = errors.New("invalid foreign key: related record does not exist")
 	ErrRecordNotFound    = errors.New("record not found")
+	ErrNotDeleted        = errors.New("record is not deleted")
+	ErrParentDeleted     = errors.New("parent record is deleted, restore it first")
+	ErrEditConflict      = errors.New("edit conflict")
 )
 
 type Models struct {
 	Organization OrganizationModel
 	Powerbank    PowerbankModel
 	Station      StationModel
+	Rental       RentalModel
+	User         UserModel
+	Token        TokenModel
+	Permission   PermissionModel
+	QRCode       QRCodeModel
+	Tariff       TariffModel
+	Payment      PaymentModel
+	Audit        AuditModel
 }
 
 func NewModels(db *sql.DB, redis *redis.Client) Models {
@@ -22,5 +33,13 @@ func NewModels(db *sql.DB, redis *redis.Client) Models {
 		Organization: OrganizationModel{DB: db, Redis: redis},
 		Powerbank:    PowerbankModel{DB: db, Redis: redis},
 		Station:      StationModel{DB: db, Redis: redis},
+		Rental:       RentalModel{DB: db, Redis: redis},
+		User:         UserModel{DB: db, Redis: redis},
+		Token:        TokenModel{DB: db, Redis: redis},
+		Permission:   PermissionModel{DB: db, Redis: redis},
+		QRCode:       QRCodeModel{DB: db, Redis: redis},
+		Tariff:       TariffModel{DB: db, Redis: redis},
+		Payment:      PaymentModel{DB: db, Redis: redis},
+		Audit:        AuditModel{DB: db},
 	}
 }
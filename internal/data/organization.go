@@ -6,16 +6,19 @@ import (
 	"errors"
 	"fmt"
 	"github.com/go-redis/redis/v8"
+	"github.com/lib/pq"
 	"github.com/olzzhas/qrent/pkg/validator"
 	"time"
 )
 
 type Organization struct {
-	ID        int       `json:"id"`
-	Name      string    `json:"name"`
-	Location  string    `json:"location"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        int        `json:"id"`
+	Name      string     `json:"name"`
+	Location  string     `json:"location"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	Version   int        `json:"version"`
 }
 
 type OrganizationModel struct {
@@ -28,31 +31,74 @@ func ValidateOrganization(v *validator.Validator, org *Organization) {
 	v.Check(org.Location != "", "location", "must be provided")
 }
 
-func (m OrganizationModel) Insert(org *Organization) error {
+func (m OrganizationModel) Insert(org *Organization, audit *Audit) error {
 	query := `
         INSERT INTO organizations (name, location)
         VALUES ($1, $2)
-        RETURNING id, created_at, updated_at
+        RETURNING id, created_at, updated_at, version
     `
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	return m.DB.QueryRowContext(ctx, query, org.Name, org.Location).
-		Scan(&org.ID, &org.CreatedAt, &org.UpdatedAt)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, org.Name, org.Location).
+		Scan(&org.ID, &org.CreatedAt, &org.UpdatedAt, &org.Version)
+	if err != nil {
+		return err
+	}
+
+	if err := audit.record(ctx, tx, AuditActionCreate, AuditResourceOrganization, org.ID, nil, org); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	invalidateOrganizations(m.Redis)
+	return nil
 }
 
+// Get возвращает организацию, если она не удалена.
 func (m OrganizationModel) Get(id int) (*Organization, error) {
+	var org Organization
+	if cacheGet(m.Redis, organizationCacheKey(id), &org) {
+		return &org, nil
+	}
+
+	found, err := m.get(id, false)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheSet(m.Redis, organizationCacheKey(id), found, organizationCacheTTL)
+	return found, nil
+}
+
+// GetWithDeleted возвращает организацию, в том числе мягко удалённую.
+// Результат не кэшируется.
+func (m OrganizationModel) GetWithDeleted(id int) (*Organization, error) {
+	return m.get(id, true)
+}
+
+func (m OrganizationModel) get(id int, includeDeleted bool) (*Organization, error) {
 	query := `
-        SELECT id, name, location, created_at, updated_at
+        SELECT id, name, location, created_at, updated_at, version, deleted_at
         FROM organizations
-        WHERE id = $1
+        WHERE id = $1 AND (deleted_at IS NULL OR $2)
     `
 	var org Organization
+
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, id).
-		Scan(&org.ID, &org.Name, &org.Location, &org.CreatedAt, &org.UpdatedAt)
+	err := m.DB.QueryRowContext(ctx, query, id, includeDeleted).
+		Scan(&org.ID, &org.Name, &org.Location, &org.CreatedAt, &org.UpdatedAt, &org.Version, &org.DeletedAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, fmt.Errorf("organization not found: id %d", id)
@@ -63,39 +109,67 @@ func (m OrganizationModel) Get(id int) (*Organization, error) {
 	return &org, nil
 }
 
-func (m OrganizationModel) Update(org *Organization) error {
+// Update сохраняет организацию, если её версия в базе всё ещё org.Version,
+// иначе возвращает ErrEditConflict.
+func (m OrganizationModel) Update(org *Organization, audit *Audit) error {
 	query := `
         UPDATE organizations
         SET name = $1,
             location = $2,
+            version = version + 1,
             updated_at = NOW()
-        WHERE id = $3
-        RETURNING updated_at
+        WHERE id = $3 AND version = $4 AND deleted_at IS NULL
+        RETURNING updated_at, version
     `
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, org.Name, org.Location, org.ID).
-		Scan(&org.UpdatedAt)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, org.Name, org.Location, org.ID, org.Version).
+		Scan(&org.UpdatedAt, &org.Version)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return fmt.Errorf("no organization found with id %d", org.ID)
+			return ErrEditConflict
 		}
 		return err
 	}
 
+	if err := audit.record(ctx, tx, AuditActionUpdate, AuditResourceOrganization, org.ID, audit.before(), org); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	invalidateOrganizations(m.Redis, org.ID)
 	return nil
 }
 
-func (m OrganizationModel) Delete(id int) error {
-	query := `
-        DELETE FROM organizations
-        WHERE id = $1
-    `
+// Delete мягко удаляет организацию вместе с её станциями и повербанками на
+// них, кроме выданных в аренду: арендатор должен суметь вернуть повербанк.
+// Все строки получают одну отметку deleted_at, по ней Restore вернёт их.
+func (m OrganizationModel) Delete(id int, audit *Audit) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	result, err := m.DB.ExecContext(ctx, query, id)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+        UPDATE organizations
+        SET deleted_at = NOW(),
+            version = version + 1
+        WHERE id = $1 AND deleted_at IS NULL
+    `, id)
 	if err != nil {
 		return err
 	}
@@ -105,44 +179,180 @@ func (m OrganizationModel) Delete(id int) error {
 		return err
 	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("no organization found with id %d", id)
+		return ErrRecordNotFound
+	}
+
+	stationIDs, err := returningIDs(ctx, tx, `
+        UPDATE stations
+        SET deleted_at = NOW(),
+            version = version + 1
+        WHERE org_id = $1 AND deleted_at IS NULL
+        RETURNING id
+    `, id)
+	if err != nil {
+		return err
+	}
+
+	powerbankIDs, err := returningIDs(ctx, tx, `
+        UPDATE powerbanks
+        SET deleted_at = NOW(),
+            version = version + 1
+        WHERE current_station_id = ANY($1) AND deleted_at IS NULL AND status <> $2
+        RETURNING id
+    `, pq.Array(stationIDs), PowerbankStatusRented)
+	if err != nil {
+		return err
+	}
+
+	if err := audit.record(ctx, tx, AuditActionDelete, AuditResourceOrganization, id, audit.before(), nil); err != nil {
+		return err
 	}
 
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	invalidateOrganizations(m.Redis, id)
+	invalidateStations(m.Redis, stationIDs...)
+	invalidatePowerbanks(m.Redis, powerbankIDs...)
 	return nil
 }
 
-func (m OrganizationModel) List() ([]*Organization, error) {
-	query := `
-        SELECT id, name, location, created_at, updated_at
-        FROM organizations
-        ORDER BY name
-    `
+// Restore возвращает мягко удалённую организацию и всё, что было удалено
+// вместе с ней. Станции и повербанки, удалённые раньше отдельно, остаются
+// удалёнными. Возвращает восстановленную организацию.
+func (m OrganizationModel) Restore(id int, audit *Audit) (*Organization, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	rows, err := m.DB.QueryContext(ctx, query)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var deletedAt *time.Time
+	err = tx.QueryRowContext(ctx, `
+        SELECT deleted_at
+        FROM organizations
+        WHERE id = $1
+        FOR UPDATE
+    `, id).Scan(&deletedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	if deletedAt == nil {
+		return nil, ErrNotDeleted
+	}
+
+	org := Organization{ID: id}
+	err = tx.QueryRowContext(ctx, `
+        UPDATE organizations
+        SET deleted_at = NULL,
+            version = version + 1,
+            updated_at = NOW()
+        WHERE id = $1
+        RETURNING name, location, created_at, updated_at, version
+    `, id).Scan(&org.Name, &org.Location, &org.CreatedAt, &org.UpdatedAt, &org.Version)
 	if err != nil {
 		return nil, err
 	}
+
+	stationIDs, err := returningIDs(ctx, tx, `
+        UPDATE stations
+        SET deleted_at = NULL,
+            version = version + 1
+        WHERE org_id = $1 AND deleted_at = $2
+        RETURNING id
+    `, id, *deletedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	powerbankIDs, err := returningIDs(ctx, tx, `
+        UPDATE powerbanks
+        SET deleted_at = NULL,
+            version = version + 1
+        WHERE current_station_id = ANY($1) AND deleted_at = $2
+        RETURNING id
+    `, pq.Array(stationIDs), *deletedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := audit.record(ctx, tx, AuditActionRestore, AuditResourceOrganization, id, nil, &org); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	invalidateOrganizations(m.Redis, id)
+	invalidateStations(m.Redis, stationIDs...)
+	invalidatePowerbanks(m.Redis, powerbankIDs...)
+	return &org, nil
+}
+
+// List возвращает страницу организаций. Непустой name выполняет полнотекстовый
+// поиск по названию; includeDeleted добавляет мягко удалённые.
+func (m OrganizationModel) List(name string, includeDeleted bool, filters Filters) ([]*Organization, Metadata, error) {
+	query := fmt.Sprintf(`
+        SELECT count(*) OVER(), id, name, location, created_at, updated_at, version, deleted_at
+        FROM organizations
+        WHERE (to_tsvector('simple', name) @@ plainto_tsquery('simple', $1) OR $1 = '')
+        AND (deleted_at IS NULL OR $2)
+        ORDER BY %s %s, id ASC
+        LIMIT $3 OFFSET $4
+    `, filters.sortColumn(), filters.sortDirection())
+
+	var cached struct {
+		Organizations []*Organization
+		Metadata      Metadata
+	}
+	cacheKey := cacheListKey(m.Redis, "organizations", name, includeDeleted, filters.Sort, filters.Page, filters.PageSize)
+	if cacheGet(m.Redis, cacheKey, &cached) {
+		return cached.Organizations, cached.Metadata, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, name, includeDeleted, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
 	defer rows.Close()
 
+	totalRecords := 0
 	orgs := make([]*Organization, 0)
 	for rows.Next() {
 		var org Organization
 		if err := rows.Scan(
+			&totalRecords,
 			&org.ID,
 			&org.Name,
 			&org.Location,
 			&org.CreatedAt,
 			&org.UpdatedAt,
+			&org.Version,
+			&org.DeletedAt,
 		); err != nil {
-			return nil, err
+			return nil, Metadata{}, err
 		}
 		orgs = append(orgs, &org)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return nil, Metadata{}, err
 	}
 
-	return orgs, nil
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	cached.Organizations, cached.Metadata = orgs, metadata
+	cacheSet(m.Redis, cacheKey, cached, organizationCacheTTL)
+	return orgs, metadata, nil
 }
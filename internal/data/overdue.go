@@ -0,0 +1,262 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MarkOverdue переводит в статус overdue активные аренды, начатые раньше,
+// чем after назад, и возвращает их число.
+func (m RentalModel) MarkOverdue(after time.Duration) (int64, error) {
+	query := `
+		UPDATE rentals
+		SET status = $1,
+			updated_at = NOW()
+		WHERE status = $2 AND started_at < NOW() - make_interval(secs => $3)
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, RentalStatusOverdue, RentalStatusActive, after.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// OverdueSince возвращает идентификаторы просроченных аренд, начатых раньше,
+// чем after назад: такие повербанки считаются потерянными.
+func (m RentalModel) OverdueSince(after time.Duration) ([]int, error) {
+	query := `
+		SELECT id
+		FROM rentals
+		WHERE status = $1 AND started_at < NOW() - make_interval(secs => $2)
+		ORDER BY started_at ASC
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, RentalStatusOverdue, after.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// MarkLost закрывает просроченную аренду как потерю: повербанк получает статус
+// lost, а стоимостью аренды становится штраф за потерю по тарифу,
+// скопированному в аренду при выдаче. Без тарифа аренда закрывается без цены. Аренда остаётся
+// нерассчитанной, пока по ней не пройдёт списание (MarkSettled).
+func (m RentalModel) MarkLost(r *Rental) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var (
+		status   RentalStatus
+		snapshot tariffSnapshot
+	)
+	err = tx.QueryRowContext(ctx, `
+		SELECT status, tariff_snapshot
+		FROM rentals
+		WHERE id = $1
+		FOR UPDATE
+	`, r.ID).Scan(&status, &snapshot)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return err
+	}
+
+	if status != RentalStatusOverdue {
+		return ErrRentalNotOverdue
+	}
+
+	pbStatus, stationID, err := lockPowerbank(ctx, tx, r.PowerbankID)
+	if err != nil {
+		return err
+	}
+	if !pbStatus.CanTransitionTo(PowerbankStatusLost) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, pbStatus, PowerbankStatusLost)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE powerbanks
+		SET status = $1,
+			slot_number = NULL,
+			version = version + 1,
+			updated_at = NOW()
+		WHERE id = $2
+	`, PowerbankStatusLost, r.PowerbankID)
+	if err != nil {
+		return err
+	}
+
+	err = recordPowerbankEvent(ctx, tx, &PowerbankEvent{
+		PowerbankID:   r.PowerbankID,
+		OldStatus:     &pbStatus,
+		NewStatus:     PowerbankStatusLost,
+		FromStationID: &stationID,
+		ToStationID:   &stationID,
+		RentalID:      &r.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if tariff := snapshot.tariff; tariff != nil {
+		r.TariffID, r.Price, r.Currency = &tariff.ID, &tariff.LostPenalty, &tariff.Currency
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		UPDATE rentals
+		SET status = $1,
+			ended_at = NOW(),
+			tariff_id = $2,
+			price = $3,
+			currency = $4,
+			updated_at = NOW()
+		WHERE id = $5
+		RETURNING status, ended_at, updated_at
+	`, RentalStatusLost, r.TariffID, r.Price, r.Currency, r.ID).
+		Scan(&r.Status, &r.EndedAt, &r.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	invalidatePowerbanks(m.Redis, r.PowerbankID)
+	return nil
+}
+
+// Unsettled возвращает идентификаторы закрытых аренд, расчёт по которым так и
+// не завершился, хотя аренда закрыта раньше, чем after назад.
+func (m RentalModel) Unsettled(after time.Duration) ([]int, error) {
+	query := `
+		SELECT id
+		FROM rentals
+		WHERE settled_at IS NULL AND status IN ($1, $2)
+		AND ended_at < NOW() - make_interval(secs => $3)
+		ORDER BY ended_at ASC
+		LIMIT 100
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, RentalStatusFinished, RentalStatusLost, after.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// MarkSettled отмечает, что расчёт по закрытой аренде завершён.
+func (m RentalModel) MarkSettled(id int) error {
+	query := `
+		UPDATE rentals
+		SET settled_at = NOW()
+		WHERE id = $1 AND settled_at IS NULL
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, id)
+	return err
+}
+
+// ListOverdue возвращает страницу просроченных аренд, начатых на станциях
+// организации orgID.
+func (m RentalModel) ListOverdue(orgID int, filters Filters) ([]*Rental, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), r.id, r.user_id, r.powerbank_id, r.start_station_id, r.start_slot_number,
+			r.end_station_id, r.end_slot_number, r.status, r.tariff_id, r.price, r.currency,
+			r.started_at, r.ended_at, r.created_at, r.updated_at
+		FROM rentals r
+		JOIN stations s ON s.id = r.start_station_id
+		WHERE s.org_id = $1 AND r.status = $2
+		ORDER BY r.%s %s, r.id ASC
+		LIMIT $3 OFFSET $4
+	`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, orgID, RentalStatusOverdue, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	rentals := make([]*Rental, 0)
+	for rows.Next() {
+		var r Rental
+		if err := rows.Scan(
+			&totalRecords,
+			&r.ID,
+			&r.UserID,
+			&r.PowerbankID,
+			&r.StartStationID,
+			&r.StartSlot,
+			&r.EndStationID,
+			&r.EndSlot,
+			&r.Status,
+			&r.TariffID,
+			&r.Price,
+			&r.Currency,
+			&r.StartedAt,
+			&r.EndedAt,
+			&r.CreatedAt,
+			&r.UpdatedAt,
+		); err != nil {
+			return nil, Metadata{}, err
+		}
+		rentals = append(rentals, &r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return rentals, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
@@ -0,0 +1,283 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"time"
+)
+
+type PaymentKind string
+
+const (
+	PaymentKindAuthorize PaymentKind = "authorize"
+	PaymentKindCapture   PaymentKind = "capture"
+	PaymentKindRefund    PaymentKind = "refund"
+	PaymentKindVoid      PaymentKind = "void"
+)
+
+type PaymentStatus string
+
+const (
+	PaymentStatusPending   PaymentStatus = "pending"
+	PaymentStatusSucceeded PaymentStatus = "succeeded"
+	PaymentStatusFailed    PaymentStatus = "failed"
+)
+
+// Payment — одна попытка операции у платёжного провайдера. Строка создаётся
+// в статусе pending до обращения к провайдеру и закрывается его результатом.
+// AuthorizationID — заморозка, к которой относятся списание, возврат и снятие
+// заморозки; у самих заморозок он пуст.
+type Payment struct {
+	ID              int64         `json:"id"`
+	RentalID        *int          `json:"rental_id"`
+	AuthorizationID *int64        `json:"authorization_id"`
+	Kind            PaymentKind   `json:"kind"`
+	Status          PaymentStatus `json:"status"`
+	Amount          int64         `json:"amount"`
+	Currency        string        `json:"currency"`
+	Provider        string        `json:"provider"`
+	ProviderRef     *string       `json:"provider_ref"`
+	IdempotencyKey  string        `json:"-"`
+	Error           *string       `json:"error"`
+	CreatedAt       time.Time     `json:"created_at"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+}
+
+type PaymentModel struct {
+	DB    *sql.DB
+	Redis *redis.Client
+}
+
+const paymentColumns = `id, rental_id, authorization_id, kind, status, amount, currency, provider,
+		provider_ref, idempotency_key, error, created_at, updated_at`
+
+func scanPayment(scan func(dest ...any) error, p *Payment) error {
+	return scan(
+		&p.ID,
+		&p.RentalID,
+		&p.AuthorizationID,
+		&p.Kind,
+		&p.Status,
+		&p.Amount,
+		&p.Currency,
+		&p.Provider,
+		&p.ProviderRef,
+		&p.IdempotencyKey,
+		&p.Error,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+	)
+}
+
+// Begin записывает попытку платежа в статусе pending и возвращает true. Если
+// попытка с тем же ключом идемпотентности уже есть, p заполняется ею и
+// возвращается false: вызывающий код по её статусу решает, нужно ли
+// обращаться к провайдеру.
+func (m PaymentModel) Begin(p *Payment) (bool, error) {
+	query := `
+		INSERT INTO payments (rental_id, authorization_id, kind, status, amount, currency, provider, idempotency_key)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (idempotency_key) DO NOTHING
+		RETURNING id, status, created_at, updated_at
+	`
+	args := []any{p.RentalID, p.AuthorizationID, p.Kind, PaymentStatusPending, p.Amount, p.Currency, p.Provider, p.IdempotencyKey}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&p.ID, &p.Status, &p.CreatedAt, &p.UpdatedAt)
+	if err == nil {
+		return true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+
+	err = scanPayment(m.DB.QueryRowContext(ctx, `
+		SELECT `+paymentColumns+`
+		FROM payments
+		WHERE idempotency_key = $1
+	`, p.IdempotencyKey).Scan, p)
+	if err != nil {
+		return false, err
+	}
+
+	return false, nil
+}
+
+// Complete сохраняет результат обращения к провайдеру. С непустым audit
+// платёж пишется в журнал аудита в той же транзакции.
+func (m PaymentModel) Complete(p *Payment, audit *Audit) error {
+	query := `
+		UPDATE payments
+		SET status = $1,
+			provider_ref = $2,
+			error = $3,
+			updated_at = NOW()
+		WHERE id = $4
+		RETURNING updated_at
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, p.Status, p.ProviderRef, p.Error, p.ID).Scan(&p.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return err
+	}
+
+	if err := audit.record(ctx, tx, AuditActionCreate, AuditResourcePayment, int(p.ID), nil, p); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// LatestSucceeded возвращает последнюю успешную операцию вида kind по аренде.
+func (m PaymentModel) LatestSucceeded(rentalID int, kind PaymentKind) (*Payment, error) {
+	return m.succeeded(rentalID, kind, "DESC")
+}
+
+// FirstSucceeded возвращает первую успешную операцию вида kind по аренде.
+// Первая заморозка по аренде — её депозит; доплаты сверх депозита
+// замораживаются позже.
+func (m PaymentModel) FirstSucceeded(rentalID int, kind PaymentKind) (*Payment, error) {
+	return m.succeeded(rentalID, kind, "ASC")
+}
+
+func (m PaymentModel) succeeded(rentalID int, kind PaymentKind, order string) (*Payment, error) {
+	query := `
+		SELECT ` + paymentColumns + `
+		FROM payments
+		WHERE rental_id = $1 AND kind = $2 AND status = $3
+		ORDER BY id ` + order + `
+		LIMIT 1
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var p Payment
+	err := scanPayment(m.DB.QueryRowContext(ctx, query, rentalID, kind, PaymentStatusSucceeded).Scan, &p)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// CountFailed возвращает число неудачных попыток с ключом идемпотентности,
+// начинающимся с prefix.
+func (m PaymentModel) CountFailed(prefix string) (int, error) {
+	query := `
+		SELECT count(*)
+		FROM payments
+		WHERE starts_with(idempotency_key, $1) AND status = $2
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var count int
+	err := m.DB.QueryRowContext(ctx, query, prefix, PaymentStatusFailed).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// RefundableCapture — остаток списания по заморозке, который ещё можно
+// вернуть.
+type RefundableCapture struct {
+	AuthorizationID int64
+	ProviderRef     string
+	Currency        string
+	Amount          int64
+}
+
+// Refundable возвращает по каждой заморозке аренды, из которой было
+// списание, сумму, доступную для возврата: списанное минус возвращённое.
+// Возвраты с ключом идемпотентности, начинающимся с exceptPrefix, не
+// вычитаются, чтобы повтор одного запроса на возврат делил сумму по
+// заморозкам так же, как в первый раз. Заморозки идут в порядке создания.
+func (m PaymentModel) Refundable(rentalID int, exceptPrefix string) ([]*RefundableCapture, error) {
+	query := `
+		SELECT a.id, a.provider_ref, a.currency,
+			coalesce(sum(o.amount) FILTER (WHERE o.kind = $3), 0) -
+			coalesce(sum(o.amount) FILTER (WHERE o.kind = $4 AND NOT starts_with(o.idempotency_key, $5)), 0)
+		FROM payments a
+		INNER JOIN payments o ON o.authorization_id = a.id AND o.status = $2
+		WHERE a.rental_id = $1 AND a.kind = $6 AND a.status = $2
+		GROUP BY a.id
+		HAVING count(*) FILTER (WHERE o.kind = $3) > 0
+		ORDER BY a.id ASC
+	`
+	args := []any{rentalID, PaymentStatusSucceeded, PaymentKindCapture, PaymentKindRefund, exceptPrefix, PaymentKindAuthorize}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	captures := make([]*RefundableCapture, 0)
+	for rows.Next() {
+		var c RefundableCapture
+		if err := rows.Scan(&c.AuthorizationID, &c.ProviderRef, &c.Currency, &c.Amount); err != nil {
+			return nil, err
+		}
+		captures = append(captures, &c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return captures, nil
+}
+
+// ListForRental возвращает все попытки платежей по аренде в порядке создания.
+func (m PaymentModel) ListForRental(rentalID int) ([]*Payment, error) {
+	query := `
+		SELECT ` + paymentColumns + `
+		FROM payments
+		WHERE rental_id = $1
+		ORDER BY id ASC
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, rentalID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := make([]*Payment, 0)
+	for rows.Next() {
+		var p Payment
+		if err := scanPayment(rows.Scan, &p); err != nil {
+			return nil, err
+		}
+		payments = append(payments, &p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return payments, nil
+}
@@ -0,0 +1,156 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"github.com/go-redis/redis/v8"
+	"github.com/lib/pq"
+	"time"
+)
+
+const (
+	PermissionOrganizationsWrite = "organizations:write"
+	PermissionStationsWrite      = "stations:write"
+	PermissionPowerbanksWrite    = "powerbanks:write"
+	PermissionRentalsCreate      = "rentals:create"
+	PermissionUsersWrite         = "users:write"
+	PermissionPaymentsRefund     = "payments:refund"
+)
+
+type Role string
+
+const (
+	RoleAdmin    Role = "admin"
+	RoleOperator Role = "operator"
+	RoleRenter   Role = "renter"
+)
+
+func (r Role) IsValid() bool {
+	switch r {
+	case RoleAdmin, RoleOperator, RoleRenter:
+		return true
+	default:
+		return false
+	}
+}
+
+// Permissions возвращает набор кодов, которые выдаются пользователю с этой ролью.
+func (r Role) Permissions() Permissions {
+	switch r {
+	case RoleAdmin:
+		return Permissions{
+			PermissionOrganizationsWrite,
+			PermissionStationsWrite,
+			PermissionPowerbanksWrite,
+			PermissionRentalsCreate,
+			PermissionUsersWrite,
+			PermissionPaymentsRefund,
+		}
+	case RoleOperator:
+		return Permissions{
+			PermissionStationsWrite,
+			PermissionPowerbanksWrite,
+			PermissionRentalsCreate,
+		}
+	case RoleRenter:
+		return Permissions{
+			PermissionRentalsCreate,
+		}
+	default:
+		return Permissions{}
+	}
+}
+
+type Permissions []string
+
+func (p Permissions) Include(code string) bool {
+	for i := range p {
+		if code == p[i] {
+			return true
+		}
+	}
+	return false
+}
+
+type PermissionModel struct {
+	DB    *sql.DB
+	Redis *redis.Client
+}
+
+func (m PermissionModel) GetAllForUser(userID int) (Permissions, error) {
+	query := `
+		SELECT permissions.code
+		FROM permissions
+		INNER JOIN users_permissions ON users_permissions.permission_id = permissions.id
+		WHERE users_permissions.user_id = $1
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var permissions Permissions
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+func (m PermissionModel) AddForUser(userID int, codes ...string) error {
+	query := `
+		INSERT INTO users_permissions
+		SELECT $1, permissions.id FROM permissions WHERE permissions.code = ANY($2)
+		ON CONFLICT DO NOTHING
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID, pq.Array(codes))
+	return err
+}
+
+// SetForUser заменяет все права пользователя указанным набором кодов.
+func (m PermissionModel) SetForUser(userID int, codes ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := setUserPermissions(ctx, tx, userID, codes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func setUserPermissions(ctx context.Context, tx *sql.Tx, userID int, codes []string) error {
+	_, err := tx.ExecContext(ctx, `
+		DELETE FROM users_permissions
+		WHERE user_id = $1
+	`, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO users_permissions
+		SELECT $1, permissions.id FROM permissions WHERE permissions.code = ANY($2)
+	`, userID, pq.Array(codes))
+	return err
+}
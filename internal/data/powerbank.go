@@ -8,23 +8,38 @@ import (
 	"github.com/go-redis/redis/v8"
 	"github.com/lib/pq"
 	"github.com/olzzhas/qrent/pkg/validator"
+	"slices"
 	"time"
 )
 
+var (
+	ErrInvalidTransition   = errors.New("invalid powerbank status transition")
+	ErrNotAtStation        = errors.New("powerbank is not at this station")
+	ErrPowerbankNotMovable = errors.New("rented or lost powerbank cannot be moved")
+	ErrPowerbankRented     = errors.New("rented powerbank cannot be deleted")
+)
+
 type PowerbankStatus string
 
 const (
-	PowerbankStatusRented    PowerbankStatus = "rented"
-	PowerbankStatusAvailable PowerbankStatus = "available"
-	PowerbankStatusCharging  PowerbankStatus = "charging"
+	PowerbankStatusRented      PowerbankStatus = "rented"
+	PowerbankStatusAvailable   PowerbankStatus = "available"
+	PowerbankStatusCharging    PowerbankStatus = "charging"
+	PowerbankStatusLost        PowerbankStatus = "lost"
+	PowerbankStatusMaintenance PowerbankStatus = "maintenance"
 )
 
+// Powerbank — повербанк. SlotNumber — номер слота на станции
+// CurrentStationID; nil, пока повербанк в аренде.
 type Powerbank struct {
 	ID               int             `json:"id"`
 	CurrentStationID int             `json:"current_station_id"`
+	SlotNumber       *int            `json:"slot_number"`
 	Status           PowerbankStatus `json:"status"`
 	CreatedAt        time.Time       `json:"created_at"`
 	UpdatedAt        time.Time       `json:"updated_at"`
+	DeletedAt        *time.Time      `json:"deleted_at,omitempty"`
+	Version          int             `json:"version"`
 }
 
 type PowerbankModel struct {
@@ -35,19 +50,92 @@ type PowerbankModel struct {
 func ValidatePowerbank(v *validator.Validator, p *Powerbank) {
 	v.Check(p.CurrentStationID > 0, "current_station_id", "must be a positive integer")
 	if !p.Status.IsValid() {
-		v.AddError("status", "must be one of: rented, available, charging")
+		v.AddError("status", "must be one of: rented, available, charging, lost, maintenance")
+	}
+	if p.SlotNumber != nil {
+		v.Check(*p.SlotNumber > 0, "slot_number", "must be a positive integer")
+	}
+}
+
+// ValidateNewPowerbank проверяет повербанк перед созданием или импортом.
+// Новый повербанк стоит на станции: в rented и lost его переводят только
+// аренды, а созданный в этих статусах повербанк остался бы без слота и без
+// аренды, и его нельзя было бы ни изменить, ни удалить.
+func ValidateNewPowerbank(v *validator.Validator, p *Powerbank) {
+	ValidatePowerbank(v, p)
+	if p.Status == PowerbankStatusRented || p.Status == PowerbankStatusLost {
+		v.AddError("status", "must be one of: available, charging, maintenance")
 	}
 }
 
 func (ps PowerbankStatus) IsValid() bool {
 	switch ps {
-	case PowerbankStatusRented, PowerbankStatusAvailable, PowerbankStatusCharging:
+	case PowerbankStatusRented, PowerbankStatusAvailable, PowerbankStatusCharging,
+		PowerbankStatusLost, PowerbankStatusMaintenance:
 		return true
 	default:
 		return false
 	}
 }
 
+// powerbankTransitions — допустимые переходы между статусами повербанка.
+// Выдача (available -> rented) и возврат (rented -> charging) выполняются
+// арендами, потеря (rented -> lost) — проверкой просроченных аренд.
+var powerbankTransitions = map[PowerbankStatus][]PowerbankStatus{
+	PowerbankStatusAvailable:   {PowerbankStatusRented, PowerbankStatusCharging, PowerbankStatusMaintenance},
+	PowerbankStatusRented:      {PowerbankStatusCharging, PowerbankStatusLost},
+	PowerbankStatusCharging:    {PowerbankStatusAvailable, PowerbankStatusMaintenance},
+	PowerbankStatusMaintenance: {PowerbankStatusAvailable, PowerbankStatusCharging},
+	PowerbankStatusLost:        {PowerbankStatusMaintenance},
+}
+
+// powerbankEditTransitions — переходы, доступные правке повербанка через API.
+// rented и lost принадлежат арендам: ручной выход из rented оставил бы
+// открытую аренду без повербанка, а ручной вход — повербанк без аренды.
+// Найденный потерянный повербанк можно только отправить на обслуживание.
+var powerbankEditTransitions = map[PowerbankStatus][]PowerbankStatus{
+	PowerbankStatusAvailable:   {PowerbankStatusCharging, PowerbankStatusMaintenance},
+	PowerbankStatusCharging:    {PowerbankStatusAvailable, PowerbankStatusMaintenance},
+	PowerbankStatusMaintenance: {PowerbankStatusAvailable, PowerbankStatusCharging},
+	PowerbankStatusLost:        {PowerbankStatusMaintenance},
+}
+
+// CanTransitionTo сообщает, разрешён ли переход из статуса ps в next.
+// Переход в тот же статус переходом не считается и не разрешён.
+func (ps PowerbankStatus) CanTransitionTo(next PowerbankStatus) bool {
+	return slices.Contains(powerbankTransitions[ps], next)
+}
+
+// CanEditTo сообщает, можно ли перевести повербанк из статуса ps в next
+// правкой через API.
+func (ps PowerbankStatus) CanEditTo(next PowerbankStatus) bool {
+	return slices.Contains(powerbankEditTransitions[ps], next)
+}
+
+// lockPowerbank блокирует строку повербанка до конца транзакции и возвращает
+// его текущие статус и станцию. Параллельные изменения того же повербанка
+// ждут завершения транзакции и видят уже новые значения.
+func lockPowerbank(ctx context.Context, tx *sql.Tx, id int) (PowerbankStatus, int, error) {
+	var (
+		status    PowerbankStatus
+		stationID int
+	)
+	err := tx.QueryRowContext(ctx, `
+		SELECT status, current_station_id
+		FROM powerbanks
+		WHERE id = $1
+		FOR UPDATE
+	`, id).Scan(&status, &stationID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", 0, ErrRecordNotFound
+		}
+		return "", 0, err
+	}
+
+	return status, stationID, nil
+}
+
 func (m PowerbankModel) ClarifyStatus(id int) (PowerbankStatus, error) {
 	query := `
 		SELECT status
@@ -74,17 +162,131 @@ func (m PowerbankModel) ClarifyStatus(id int) (PowerbankStatus, error) {
 	return status, nil
 }
 
-func (m PowerbankModel) Insert(p *Powerbank) error {
-	query := `
-		INSERT INTO powerbanks (current_station_id, status)
-		VALUES ($1, $2)
-		RETURNING id, created_at, updated_at
-	`
+// Insert создаёт повербанк, проверенный ValidateNewPowerbank. Повербанк
+// занимает слот на станции: переданный SlotNumber или первый свободный. Создание открывает историю
+// повербанка; actorID — пользователь, который его создал.
+func (m PowerbankModel) Insert(p *Powerbank, actorID *int, audit *Audit) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, p.CurrentStationID, p.Status).
-		Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertPowerbank(ctx, tx, p, actorID); err != nil {
+		return err
+	}
+
+	if err := audit.record(ctx, tx, AuditActionCreate, AuditResourcePowerbank, p.ID, nil, p); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	invalidatePowerbanks(m.Redis)
+	return nil
+}
+
+// Import вставляет повербанки одной транзакцией: либо все, либо ни одного.
+// Слоты занимаются так же, как в Insert, поэтому повербанки из одного пакета
+// не займут один слот дважды. Ошибка вставки возвращается как *BulkError с
+// позицией повербанка в powerbanks. Записи аудита обо всех повербанках
+// пишутся одним запросом в той же транзакции.
+func (m PowerbankModel) Import(powerbanks []*Powerbank, actorID *int, audit *Audit) error {
+	ctx, cancel := context.WithTimeout(context.Background(), bulkTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	changes := make([]auditChange, 0, len(powerbanks))
+	for i, p := range powerbanks {
+		if err := insertPowerbank(ctx, tx, p, actorID); err != nil {
+			return &BulkError{Index: i, Err: err}
+		}
+		changes = append(changes, auditChange{ID: p.ID, After: p})
+	}
+
+	if err := audit.recordBatch(ctx, tx, AuditActionCreate, AuditResourcePowerbank, changes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	invalidatePowerbanks(m.Redis)
+	return nil
+}
+
+// CheckImport проверяет слоты повербанков так же, как Import, но ничего не
+// пишет в базу: слоты выбираются по текущему состоянию станций с учётом
+// повербанков, стоящих в пакете раньше. Ошибка возвращается как *BulkError.
+// Проверка не блокирует станции, поэтому параллельные изменения могут
+// занять слоты до настоящего импорта.
+func (m PowerbankModel) CheckImport(powerbanks []*Powerbank) error {
+	ctx, cancel := context.WithTimeout(context.Background(), bulkTimeout)
+	defer cancel()
+
+	type stationSlots struct {
+		capacity int
+		occupied map[int]bool
+	}
+	stations := make(map[int]*stationSlots)
+
+	for i, p := range powerbanks {
+		station, found := stations[p.CurrentStationID]
+		if !found {
+			station = &stationSlots{}
+			err := m.DB.QueryRowContext(ctx, `
+				SELECT capacity
+				FROM stations
+				WHERE id = $1 AND deleted_at IS NULL
+			`, p.CurrentStationID).Scan(&station.capacity)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return &BulkError{Index: i, Err: ErrInvalidForeignKey}
+				}
+				return err
+			}
+
+			station.occupied, err = occupiedSlots(ctx, m.DB, p.CurrentStationID, 0)
+			if err != nil {
+				return err
+			}
+			stations[p.CurrentStationID] = station
+		}
+
+		slot, err := pickSlot(station.capacity, station.occupied, p.SlotNumber)
+		if err != nil {
+			return &BulkError{Index: i, Err: err}
+		}
+		station.occupied[slot] = true
+	}
+
+	return nil
+}
+
+func insertPowerbank(ctx context.Context, tx *sql.Tx, p *Powerbank, actorID *int) error {
+	query := `
+		INSERT INTO powerbanks (current_station_id, slot_number, status)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, updated_at, version
+	`
+
+	if err := placePowerbank(ctx, tx, p); err != nil {
+		return err
+	}
+
+	err := tx.QueryRowContext(ctx, query, p.CurrentStationID, p.SlotNumber, p.Status).
+		Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt, &p.Version)
 	if err != nil {
 		var pgerr *pq.Error
 		if errors.As(err, &pgerr) && pgerr.Code == "23503" {
@@ -93,24 +295,69 @@ func (m PowerbankModel) Insert(p *Powerbank) error {
 		return err
 	}
 
+	return recordPowerbankEvent(ctx, tx, &PowerbankEvent{
+		PowerbankID: p.ID,
+		NewStatus:   p.Status,
+		ToStationID: &p.CurrentStationID,
+		ActorID:     actorID,
+	})
+}
+
+// placePowerbank выставляет p.SlotNumber: у повербанка в аренде или
+// потерянного слота нет, остальные занимают слот на своей станции.
+func placePowerbank(ctx context.Context, tx *sql.Tx, p *Powerbank) error {
+	if p.Status == PowerbankStatusRented || p.Status == PowerbankStatusLost {
+		p.SlotNumber = nil
+		return nil
+	}
+
+	slot, err := claimSlot(ctx, tx, p.CurrentStationID, p.SlotNumber, p.ID)
+	if err != nil {
+		return err
+	}
+
+	p.SlotNumber = &slot
 	return nil
 }
 
+// Get возвращает повербанк, если он не удалён.
 func (m PowerbankModel) Get(id int) (*Powerbank, error) {
+	var p Powerbank
+	if cacheGet(m.Redis, powerbankCacheKey(id), &p) {
+		return &p, nil
+	}
+
+	found, err := m.get(id, false)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheSet(m.Redis, powerbankCacheKey(id), found, powerbankCacheTTL)
+	return found, nil
+}
+
+// GetWithDeleted возвращает повербанк, в том числе мягко удалённый. Результат
+// не кэшируется.
+func (m PowerbankModel) GetWithDeleted(id int) (*Powerbank, error) {
+	return m.get(id, true)
+}
+
+func (m PowerbankModel) get(id int, includeDeleted bool) (*Powerbank, error) {
 	query := `
-		SELECT id, current_station_id, status, created_at, updated_at
+		SELECT id, current_station_id, slot_number, status, created_at, updated_at, version, deleted_at
 		FROM powerbanks
-		WHERE id = $1
+		WHERE id = $1 AND (deleted_at IS NULL OR $2)
 	`
 	var p Powerbank
+
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, id).
-		Scan(&p.ID, &p.CurrentStationID, &p.Status, &p.CreatedAt, &p.UpdatedAt)
+	err := m.DB.QueryRowContext(ctx, query, id, includeDeleted).
+		Scan(&p.ID, &p.CurrentStationID, &p.SlotNumber, &p.Status, &p.CreatedAt, &p.UpdatedAt, &p.Version, &p.DeletedAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("powerbank не найден: id %d", id)
+			return nil, ErrRecordNotFound
 		}
 		return nil, err
 	}
@@ -118,23 +365,58 @@ func (m PowerbankModel) Get(id int) (*Powerbank, error) {
 	return &p, nil
 }
 
-func (m PowerbankModel) Update(p *Powerbank) error {
+// Update сохраняет повербанк. from — статус, который видел вызывающий код:
+// под блокировкой строки он должен совпасть с текущим, а смена статуса
+// from -> p.Status должна быть доступна правке (CanEditTo), иначе
+// возвращается ErrInvalidTransition. Повербанк в аренде не правится вовсе.
+// При смене станции или слота новый слот проверяется так же, как при
+// создании; собственный слот повербанка считается свободным. Если
+// повербанка нет, возвращается ErrRecordNotFound.
+// Смена статуса или станции записывается в историю от имени actorID. Если
+// версия повербанка в базе уже не p.Version, возвращается ErrEditConflict.
+func (m PowerbankModel) Update(p *Powerbank, from PowerbankStatus, actorID *int, audit *Audit) error {
 	query := `
 		UPDATE powerbanks
 		SET current_station_id = $1,
-			status = $2,
+			slot_number = $2,
+			status = $3,
+			version = version + 1,
 			updated_at = NOW()
-		WHERE id = $3
-		RETURNING updated_at
+		WHERE id = $4 AND version = $5
+		RETURNING updated_at, version
 	`
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, p.CurrentStationID, p.Status, p.ID).
-		Scan(&p.UpdatedAt)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	current, fromStationID, err := lockPowerbank(ctx, tx, p.ID)
+	if err != nil {
+		return err
+	}
+	if current != from {
+		return fmt.Errorf("%w: status has changed from %s to %s", ErrInvalidTransition, from, current)
+	}
+	if current == PowerbankStatusRented {
+		return fmt.Errorf("%w: rented powerbank changes only through its rental", ErrInvalidTransition)
+	}
+	if p.Status != current && !current.CanEditTo(p.Status) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, current, p.Status)
+	}
+
+	if err := placePowerbank(ctx, tx, p); err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx, query, p.CurrentStationID, p.SlotNumber, p.Status, p.ID, p.Version).
+		Scan(&p.UpdatedAt, &p.Version)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return fmt.Errorf("powerbank with id %d not found", p.ID)
+			return ErrEditConflict
 		}
 		var pgerr *pq.Error
 		if errors.As(err, &pgerr) && pgerr.Code == "23503" {
@@ -143,18 +425,168 @@ func (m PowerbankModel) Update(p *Powerbank) error {
 		return err
 	}
 
+	if p.Status != current || p.CurrentStationID != fromStationID {
+		err = recordPowerbankEvent(ctx, tx, &PowerbankEvent{
+			PowerbankID:   p.ID,
+			OldStatus:     &current,
+			NewStatus:     p.Status,
+			FromStationID: &fromStationID,
+			ToStationID:   &p.CurrentStationID,
+			ActorID:       actorID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := audit.record(ctx, tx, AuditActionUpdate, AuditResourcePowerbank, p.ID, audit.before(), p); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	invalidatePowerbanks(m.Redis, p.ID)
 	return nil
 }
 
-func (m PowerbankModel) Delete(id int) error {
+// Transfer переносит повербанки ids со станции fromStationID на toStationID
+// одной транзакцией: либо все, либо ни одного. Строки повербанков блокируются
+// по возрастанию id, чтобы встречные переносы не взаимоблокировались.
+// Удалённый повербанк возвращает ErrRecordNotFound, повербанк с другой
+// станции — ErrNotAtStation, в аренде или потерянный — ErrPowerbankNotMovable;
+// эти ошибки приходят как *BulkError с позицией повербанка в ids. На станции
+// назначения повербанки занимают первые свободные слоты, и если слотов не
+// хватает, возвращается ErrStationFull. Каждый перенос пишется в историю от
+// имени actorID, а записи аудита обо всех повербанках — одним запросом.
+func (m PowerbankModel) Transfer(fromStationID, toStationID int, ids []int, actorID *int, audit *Audit) ([]*Powerbank, error) {
 	query := `
-		DELETE FROM powerbanks
-		WHERE id = $1
+		UPDATE powerbanks
+		SET current_station_id = $1,
+			slot_number = $2,
+			version = version + 1,
+			updated_at = NOW()
+		WHERE id = $3
+		RETURNING updated_at, version
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), bulkTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	locked, err := lockPowerbanks(ctx, tx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	moved := make([]*Powerbank, len(ids))
+	changes := make([]auditChange, 0, len(ids))
+	for i, id := range ids {
+		p, ok := locked[id]
+		switch {
+		case !ok:
+			return nil, &BulkError{Index: i, Err: ErrRecordNotFound}
+		case p.CurrentStationID != fromStationID:
+			return nil, &BulkError{Index: i, Err: ErrNotAtStation}
+		case p.Status == PowerbankStatusRented || p.Status == PowerbankStatusLost:
+			return nil, &BulkError{Index: i, Err: ErrPowerbankNotMovable}
+		}
+		before := *p
+
+		slot, err := claimSlot(ctx, tx, toStationID, nil, p.ID)
+		if err != nil {
+			return nil, err
+		}
+		p.CurrentStationID, p.SlotNumber = toStationID, &slot
+
+		err = tx.QueryRowContext(ctx, query, p.CurrentStationID, p.SlotNumber, p.ID).
+			Scan(&p.UpdatedAt, &p.Version)
+		if err != nil {
+			return nil, err
+		}
+
+		status := p.Status
+		err = recordPowerbankEvent(ctx, tx, &PowerbankEvent{
+			PowerbankID:   p.ID,
+			OldStatus:     &status,
+			NewStatus:     p.Status,
+			FromStationID: &fromStationID,
+			ToStationID:   &toStationID,
+			ActorID:       actorID,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		moved[i] = p
+		changes = append(changes, auditChange{ID: p.ID, Before: &before, After: p})
+	}
+
+	if err := audit.recordBatch(ctx, tx, AuditActionUpdate, AuditResourcePowerbank, changes); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	invalidatePowerbanks(m.Redis, ids...)
+	return moved, nil
+}
+
+// lockPowerbanks блокирует неудалённые повербанки ids до конца транзакции и
+// возвращает их по id.
+func lockPowerbanks(ctx context.Context, tx *sql.Tx, ids []int) (map[int]*Powerbank, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, current_station_id, slot_number, status, created_at, updated_at, version
+		FROM powerbanks
+		WHERE id = ANY($1) AND deleted_at IS NULL
+		ORDER BY id
+		FOR UPDATE
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	locked := make(map[int]*Powerbank, len(ids))
+	for rows.Next() {
+		var p Powerbank
+		if err := rows.Scan(&p.ID, &p.CurrentStationID, &p.SlotNumber, &p.Status, &p.CreatedAt, &p.UpdatedAt, &p.Version); err != nil {
+			return nil, err
+		}
+		locked[p.ID] = &p
+	}
+
+	return locked, rows.Err()
+}
+
+// Delete мягко удаляет повербанк и освобождает его слот. Повербанк в аренде
+// не удаляется: арендатор должен суметь его вернуть, поэтому для него
+// возвращается ErrPowerbankRented, а для отсутствующего — ErrRecordNotFound.
+func (m PowerbankModel) Delete(id int, audit *Audit) error {
+	query := `
+		UPDATE powerbanks
+		SET deleted_at = NOW(),
+			slot_number = NULL,
+			version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL AND status <> $2
 	`
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	result, err := m.DB.ExecContext(ctx, query, id)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, id, PowerbankStatusRented)
 	if err != nil {
 		return err
 	}
@@ -164,38 +596,246 @@ func (m PowerbankModel) Delete(id int) error {
 		return err
 	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("powerbank с id %d не найден", id)
+		var status PowerbankStatus
+		err := tx.QueryRowContext(ctx, `
+			SELECT status
+			FROM powerbanks
+			WHERE id = $1 AND deleted_at IS NULL
+		`, id).Scan(&status)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrRecordNotFound
+			}
+			return err
+		}
+		return ErrPowerbankRented
 	}
 
+	if err := audit.record(ctx, tx, AuditActionDelete, AuditResourcePowerbank, id, audit.before(), nil); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	invalidatePowerbanks(m.Redis, id)
 	return nil
 }
 
-func (m PowerbankModel) List() ([]*Powerbank, error) {
-	query := `
-		SELECT id, current_station_id, status, created_at, updated_at
-		FROM powerbanks
-		ORDER BY id
-	`
+// Restore возвращает мягко удалённый повербанк. Повербанк на удалённой
+// станции восстановить нельзя: ErrParentDeleted. Повербанк без слота, который
+// должен стоять на станции, занимает первый свободный слот. Возвращает
+// восстановленный повербанк.
+func (m PowerbankModel) Restore(id int, audit *Audit) (*Powerbank, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	rows, err := m.DB.QueryContext(ctx, query)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		p              = Powerbank{ID: id}
+		stationDeleted bool
+	)
+	err = tx.QueryRowContext(ctx, `
+		SELECT p.current_station_id, p.slot_number, p.status, p.deleted_at, s.deleted_at IS NOT NULL
+		FROM powerbanks p
+		INNER JOIN stations s ON s.id = p.current_station_id
+		WHERE p.id = $1
+		FOR UPDATE OF p
+	`, id).Scan(&p.CurrentStationID, &p.SlotNumber, &p.Status, &p.DeletedAt, &stationDeleted)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	switch {
+	case p.DeletedAt == nil:
+		return nil, ErrNotDeleted
+	case stationDeleted:
+		return nil, ErrParentDeleted
+	}
+
+	if p.SlotNumber == nil {
+		if err := placePowerbank(ctx, tx, &p); err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		UPDATE powerbanks
+		SET deleted_at = NULL,
+			slot_number = $1,
+			version = version + 1,
+			updated_at = NOW()
+		WHERE id = $2
+		RETURNING created_at, updated_at, version
+	`, p.SlotNumber, id).Scan(&p.CreatedAt, &p.UpdatedAt, &p.Version)
 	if err != nil {
 		return nil, err
 	}
+
+	p.DeletedAt = nil
+	if err := audit.record(ctx, tx, AuditActionRestore, AuditResourcePowerbank, id, nil, &p); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	invalidatePowerbanks(m.Redis, id)
+	return &p, nil
+}
+
+// List возвращает страницу повербанков. Непустой statuses оставляет только
+// повербанки с этими статусами, ненулевой stationID — только с этой станции;
+// includeDeleted добавляет мягко удалённые.
+func (m PowerbankModel) List(statuses []string, stationID int, includeDeleted bool, filters Filters) ([]*Powerbank, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, current_station_id, slot_number, status, created_at, updated_at, version, deleted_at
+		FROM powerbanks
+		WHERE (status = ANY($1) OR cardinality($1) = 0)
+		AND (current_station_id = $2 OR $2 = 0)
+		AND (deleted_at IS NULL OR $3)
+		ORDER BY %s %s, id ASC
+		LIMIT $4 OFFSET $5
+	`, filters.sortColumn(), filters.sortDirection())
+
+	var cached struct {
+		Powerbanks []*Powerbank
+		Metadata   Metadata
+	}
+	cacheKey := cacheListKey(m.Redis, "powerbanks", statuses, stationID, includeDeleted, filters.Sort, filters.Page, filters.PageSize)
+	if cacheGet(m.Redis, cacheKey, &cached) {
+		return cached.Powerbanks, cached.Metadata, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []any{pq.Array(statuses), stationID, includeDeleted, filters.limit(), filters.offset()}
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
 	defer rows.Close()
 
+	totalRecords := 0
 	powerbanks := make([]*Powerbank, 0)
 	for rows.Next() {
 		var p Powerbank
-		if err := rows.Scan(&p.ID, &p.CurrentStationID, &p.Status, &p.CreatedAt, &p.UpdatedAt); err != nil {
-			return nil, err
+		if err := rows.Scan(&totalRecords, &p.ID, &p.CurrentStationID, &p.SlotNumber, &p.Status, &p.CreatedAt, &p.UpdatedAt, &p.Version, &p.DeletedAt); err != nil {
+			return nil, Metadata{}, err
 		}
 		powerbanks = append(powerbanks, &p)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	cached.Powerbanks, cached.Metadata = powerbanks, metadata
+	cacheSet(m.Redis, cacheKey, cached, powerbankCacheTTL)
+	return powerbanks, metadata, nil
+}
+
+// ListForOrganization возвращает страницу повербанков, числящихся за
+// станциями организации orgID. Непустой statuses оставляет только повербанки
+// с этими статусами; includeDeleted добавляет мягко удалённые.
+func (m PowerbankModel) ListForOrganization(orgID int, statuses []string, includeDeleted bool, filters Filters) ([]*Powerbank, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), p.id, p.current_station_id, p.slot_number, p.status, p.created_at, p.updated_at, p.version, p.deleted_at
+		FROM powerbanks p
+		JOIN stations s ON s.id = p.current_station_id
+		WHERE s.org_id = $1
+		AND (p.status = ANY($2) OR cardinality($2) = 0)
+		AND (p.deleted_at IS NULL OR $3)
+		ORDER BY p.%s %s, p.id ASC
+		LIMIT $4 OFFSET $5
+	`, filters.sortColumn(), filters.sortDirection())
+
+	var cached struct {
+		Powerbanks []*Powerbank
+		Metadata   Metadata
+	}
+	cacheKey := cacheListKey(m.Redis, "powerbanks", "org", orgID, statuses, includeDeleted, filters.Sort, filters.Page, filters.PageSize)
+	if cacheGet(m.Redis, cacheKey, &cached) {
+		return cached.Powerbanks, cached.Metadata, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []any{orgID, pq.Array(statuses), includeDeleted, filters.limit(), filters.offset()}
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	powerbanks := make([]*Powerbank, 0)
+	for rows.Next() {
+		var p Powerbank
+		if err := rows.Scan(&totalRecords, &p.ID, &p.CurrentStationID, &p.SlotNumber, &p.Status, &p.CreatedAt, &p.UpdatedAt, &p.Version, &p.DeletedAt); err != nil {
+			return nil, Metadata{}, err
+		}
+		powerbanks = append(powerbanks, &p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	cached.Powerbanks, cached.Metadata = powerbanks, metadata
+	cacheSet(m.Redis, cacheKey, cached, powerbankCacheTTL)
+	return powerbanks, metadata, nil
+}
+
+// Export передаёт fn все неудалённые повербанки по возрастанию id, не
+// собирая их в память. Ненулевой orgID оставляет повербанки со станций одной
+// организации, ненулевой stationID — с одной станции. Ошибка fn прекращает
+// выборку и возвращается как есть.
+func (m PowerbankModel) Export(orgID, stationID int, fn func(*Powerbank) error) error {
+	query := `
+		SELECT p.id, p.current_station_id, p.slot_number, p.status, p.created_at, p.updated_at, p.version
+		FROM powerbanks p
+		JOIN stations s ON s.id = p.current_station_id
+		WHERE (s.org_id = $1 OR $1 = 0)
+		AND (p.current_station_id = $2 OR $2 = 0)
+		AND p.deleted_at IS NULL
+		ORDER BY p.id ASC
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), bulkTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, orgID, stationID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p Powerbank
+		if err := rows.Scan(&p.ID, &p.CurrentStationID, &p.SlotNumber, &p.Status, &p.CreatedAt, &p.UpdatedAt, &p.Version); err != nil {
+			return err
+		}
+		if err := fn(&p); err != nil {
+			return err
+		}
 	}
 
-	return powerbanks, nil
+	return rows.Err()
 }
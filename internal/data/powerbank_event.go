@@ -0,0 +1,84 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// PowerbankEvent — одна запись истории повербанка: смена статуса и/или
+// станции. OldStatus и FromStationID пусты у события создания; ActorID пуст
+// у изменений, сделанных фоновыми задачами.
+type PowerbankEvent struct {
+	ID            int64            `json:"id"`
+	PowerbankID   int              `json:"powerbank_id"`
+	OldStatus     *PowerbankStatus `json:"old_status"`
+	NewStatus     PowerbankStatus  `json:"new_status"`
+	FromStationID *int             `json:"from_station_id"`
+	ToStationID   *int             `json:"to_station_id"`
+	ActorID       *int             `json:"actor_id"`
+	RentalID      *int             `json:"rental_id"`
+	CreatedAt     time.Time        `json:"created_at"`
+}
+
+// recordPowerbankEvent пишет событие в транзакции tx, чтобы оно появилось
+// только вместе с самим изменением.
+func recordPowerbankEvent(ctx context.Context, tx *sql.Tx, e *PowerbankEvent) error {
+	query := `
+		INSERT INTO powerbank_events (powerbank_id, old_status, new_status, from_station_id,
+			to_station_id, actor_id, rental_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`
+	args := []any{e.PowerbankID, e.OldStatus, e.NewStatus, e.FromStationID, e.ToStationID, e.ActorID, e.RentalID}
+
+	return tx.QueryRowContext(ctx, query, args...).Scan(&e.ID, &e.CreatedAt)
+}
+
+// History возвращает страницу истории повербанка.
+func (m PowerbankModel) History(id int, filters Filters) ([]*PowerbankEvent, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, powerbank_id, old_status, new_status, from_station_id,
+			to_station_id, actor_id, rental_id, created_at
+		FROM powerbank_events
+		WHERE powerbank_id = $1
+		ORDER BY %s %s
+		LIMIT $2 OFFSET $3
+	`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, id, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	events := make([]*PowerbankEvent, 0)
+	for rows.Next() {
+		var e PowerbankEvent
+		if err := rows.Scan(
+			&totalRecords,
+			&e.ID,
+			&e.PowerbankID,
+			&e.OldStatus,
+			&e.NewStatus,
+			&e.FromStationID,
+			&e.ToStationID,
+			&e.ActorID,
+			&e.RentalID,
+			&e.CreatedAt,
+		); err != nil {
+			return nil, Metadata{}, err
+		}
+		events = append(events, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return events, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
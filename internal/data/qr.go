@@ -0,0 +1,186 @@
+package data
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidQRCode = errors.New("invalid or revoked QR code")
+
+// QRKind — тип объекта, на который указывает QR-код.
+type QRKind string
+
+const (
+	QRKindStation   QRKind = "s"
+	QRKindPowerbank QRKind = "p"
+)
+
+const qrPrefix = "qrent:v1"
+
+// QRCode — содержимое подписанного QR-кода. Revision совпадает с qr_revision
+// объекта на момент печати; отзыв увеличивает qr_revision, и старые наклейки
+// перестают проходить проверку.
+type QRCode struct {
+	Kind     QRKind
+	ID       int
+	Revision int
+}
+
+func (k QRKind) table() (string, error) {
+	switch k {
+	case QRKindStation:
+		return "stations", nil
+	case QRKindPowerbank:
+		return "powerbanks", nil
+	default:
+		return "", fmt.Errorf("unknown QR kind %q", k)
+	}
+}
+
+func qrSignature(secret []byte, kind QRKind, id, revision int) string {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%s:%s:%d:%d", qrPrefix, kind, id, revision)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// SignQRCode возвращает полезную нагрузку QR-кода вида
+// qrent:v1:<kind>:<id>:<revision>:<signature>. Для одного и того же объекта и
+// ревизии результат всегда одинаков, поэтому наклейку можно перепечатать.
+func SignQRCode(secret []byte, code QRCode) string {
+	return fmt.Sprintf("%s:%s:%d:%d:%s", qrPrefix, code.Kind, code.ID, code.Revision,
+		qrSignature(secret, code.Kind, code.ID, code.Revision))
+}
+
+// ParseQRCode разбирает отсканированную нагрузку и проверяет подпись. Любое
+// расхождение формата или подписи возвращает ErrInvalidQRCode; актуальность
+// ревизии проверяет вызывающий код.
+func ParseQRCode(secret []byte, payload string) (QRCode, error) {
+	parts := strings.Split(payload, ":")
+	if len(parts) != 6 || parts[0]+":"+parts[1] != qrPrefix {
+		return QRCode{}, ErrInvalidQRCode
+	}
+
+	code := QRCode{Kind: QRKind(parts[2])}
+	if _, err := code.Kind.table(); err != nil {
+		return QRCode{}, ErrInvalidQRCode
+	}
+
+	var err error
+	if code.ID, err = strconv.Atoi(parts[3]); err != nil || code.ID < 1 {
+		return QRCode{}, ErrInvalidQRCode
+	}
+	if code.Revision, err = strconv.Atoi(parts[4]); err != nil || code.Revision < 1 {
+		return QRCode{}, ErrInvalidQRCode
+	}
+
+	expected := qrSignature(secret, code.Kind, code.ID, code.Revision)
+	if !hmac.Equal([]byte(parts[5]), []byte(expected)) {
+		return QRCode{}, ErrInvalidQRCode
+	}
+
+	return code, nil
+}
+
+type QRCodeModel struct {
+	DB    *sql.DB
+	Redis *redis.Client
+}
+
+// Revision возвращает текущую ревизию QR-кода объекта. Код удалённого
+// объекта считается несуществующим.
+func (m QRCodeModel) Revision(kind QRKind, id int) (int, error) {
+	table, err := kind.table()
+	if err != nil {
+		return 0, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT qr_revision
+		FROM %s
+		WHERE id = $1 AND deleted_at IS NULL
+	`, table)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var revision int
+	if err := m.DB.QueryRowContext(ctx, query, id).Scan(&revision); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrRecordNotFound
+		}
+		return 0, err
+	}
+
+	return revision, nil
+}
+
+// Revoke отзывает все напечатанные QR-коды объекта и возвращает новую ревизию.
+// Отзыв меняет объект, поэтому увеличивает и его версию: ETag, выданный до
+// отзыва, перестаёт проходить If-Match. У удалённого объекта коды не
+// отзываются — возвращается ErrRecordNotFound. Отзыв пишется в журнал аудита
+// как правка qr_revision объекта.
+func (m QRCodeModel) Revoke(kind QRKind, id int, audit *Audit) (int, error) {
+	table, err := kind.table()
+	if err != nil {
+		return 0, err
+	}
+
+	resource := AuditResourceStation
+	if kind == QRKindPowerbank {
+		resource = AuditResourcePowerbank
+	}
+
+	query := fmt.Sprintf(`
+		UPDATE %s
+		SET qr_revision = qr_revision + 1,
+			version = version + 1,
+			updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING qr_revision
+	`, table)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var revision int
+	if err := tx.QueryRowContext(ctx, query, id).Scan(&revision); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrRecordNotFound
+		}
+		return 0, err
+	}
+
+	err = audit.record(ctx, tx, AuditActionUpdate, resource, id,
+		map[string]int{"qr_revision": revision - 1}, map[string]int{"qr_revision": revision})
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	switch kind {
+	case QRKindStation:
+		invalidateStations(m.Redis, id)
+	case QRKindPowerbank:
+		invalidatePowerbanks(m.Redis, id)
+	}
+
+	return revision, nil
+}
@@ -0,0 +1,187 @@
+package data
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+)
+
+// earthRadius — радиус Земли в метрах, тот же, что у earth_distance в
+// Postgres, чтобы расстояния совпадали с поиском ближайших станций.
+const earthRadius = 6378168
+
+// StationStock — повербанки в слотах станции. Docked считает все повербанки в
+// слотах, Movable — только те, что можно перенести (available и charging):
+// повербанки на обслуживании занимают слот, но не переносятся.
+type StationStock struct {
+	StationID int
+	Latitude  *float64
+	Longitude *float64
+	Capacity  int
+	Docked    int
+	Movable   int
+}
+
+// RebalanceTransfer — предлагаемый перенос Count повербанков. Distance —
+// расстояние между станциями в целых метрах, если у обеих есть координаты.
+type RebalanceTransfer struct {
+	FromStationID int      `json:"from_station_id"`
+	ToStationID   int      `json:"to_station_id"`
+	Count         int      `json:"count"`
+	Distance      *float64 `json:"distance,omitempty"`
+}
+
+// RebalanceStation — станция в плане: Target — целевое число повербанков,
+// Planned — число повербанков после всех переносов плана.
+type RebalanceStation struct {
+	StationID int `json:"station_id"`
+	Capacity  int `json:"capacity"`
+	Docked    int `json:"docked"`
+	Target    int `json:"target"`
+	Planned   int `json:"planned"`
+}
+
+// RebalancePlan — план перебалансировки станций организации.
+type RebalancePlan struct {
+	TargetFill float64             `json:"target_fill"`
+	Transfers  []RebalanceTransfer `json:"transfers"`
+	Stations   []RebalanceStation  `json:"stations"`
+}
+
+// Stock возвращает заполненность неудалённых станций организации orgID.
+func (m StationModel) Stock(orgID int) ([]StationStock, error) {
+	query := `
+        SELECT s.id, s.latitude, s.longitude, s.capacity,
+            count(p.id) FILTER (WHERE p.slot_number IS NOT NULL),
+            count(p.id) FILTER (WHERE p.slot_number IS NOT NULL AND p.status IN ('available', 'charging'))
+        FROM stations s
+        LEFT JOIN powerbanks p ON p.current_station_id = s.id AND p.deleted_at IS NULL
+        WHERE s.org_id = $1 AND s.deleted_at IS NULL
+        GROUP BY s.id
+        ORDER BY s.id ASC
+    `
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stocks []StationStock
+	for rows.Next() {
+		var s StationStock
+		if err := rows.Scan(&s.StationID, &s.Latitude, &s.Longitude, &s.Capacity, &s.Docked, &s.Movable); err != nil {
+			return nil, err
+		}
+		stocks = append(stocks, s)
+	}
+
+	return stocks, rows.Err()
+}
+
+// PlanRebalance жадно подбирает переносы, которые приближают каждую станцию к
+// заполненности targetFill (доля ёмкости от 0 до 1). Избыток станции — сколько
+// переносимых повербанков у неё сверх цели, нехватка — сколько не хватает до
+// цели. Пары «избыток — нехватка» перебираются от ближайших к дальним, и
+// каждая получает столько повербанков, сколько позволяют обе стороны; пары со
+// станциями без координат идут последними. Результат детерминирован для
+// одинаковых stocks.
+func PlanRebalance(stocks []StationStock, targetFill float64) *RebalancePlan {
+	plan := &RebalancePlan{
+		TargetFill: targetFill,
+		Transfers:  []RebalanceTransfer{},
+		Stations:   make([]RebalanceStation, len(stocks)),
+	}
+
+	excess := make([]int, len(stocks))
+	deficit := make([]int, len(stocks))
+	for i, s := range stocks {
+		target := int(math.Round(float64(s.Capacity) * targetFill))
+		plan.Stations[i] = RebalanceStation{
+			StationID: s.StationID,
+			Capacity:  s.Capacity,
+			Docked:    s.Docked,
+			Target:    target,
+			Planned:   s.Docked,
+		}
+
+		switch {
+		case s.Docked > target:
+			excess[i] = min(s.Docked-target, s.Movable)
+		case s.Docked < target:
+			deficit[i] = target - s.Docked
+		}
+	}
+
+	type pair struct {
+		from, to int
+		distance *float64
+	}
+	var pairs []pair
+	for from := range stocks {
+		if excess[from] == 0 {
+			continue
+		}
+		for to := range stocks {
+			if deficit[to] == 0 {
+				continue
+			}
+			pairs = append(pairs, pair{from: from, to: to, distance: stockDistance(stocks[from], stocks[to])})
+		}
+	}
+
+	sort.SliceStable(pairs, func(i, j int) bool {
+		a, b := pairs[i], pairs[j]
+		if (a.distance == nil) != (b.distance == nil) {
+			return a.distance != nil
+		}
+		if a.distance != nil && *a.distance != *b.distance {
+			return *a.distance < *b.distance
+		}
+		if a.from != b.from {
+			return a.from < b.from
+		}
+		return a.to < b.to
+	})
+
+	for _, p := range pairs {
+		count := min(excess[p.from], deficit[p.to])
+		if count == 0 {
+			continue
+		}
+
+		excess[p.from] -= count
+		deficit[p.to] -= count
+		plan.Stations[p.from].Planned -= count
+		plan.Stations[p.to].Planned += count
+
+		plan.Transfers = append(plan.Transfers, RebalanceTransfer{
+			FromStationID: stocks[p.from].StationID,
+			ToStationID:   stocks[p.to].StationID,
+			Count:         count,
+			Distance:      p.distance,
+		})
+	}
+
+	return plan
+}
+
+// stockDistance возвращает расстояние между станциями в целых метрах по формуле
+// гаверсинуса или nil, если у одной из них нет координат.
+func stockDistance(a, b StationStock) *float64 {
+	if a.Latitude == nil || a.Longitude == nil || b.Latitude == nil || b.Longitude == nil {
+		return nil
+	}
+
+	lat1, lat2 := *a.Latitude*math.Pi/180, *b.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (*b.Longitude - *a.Longitude) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	distance := math.Round(2 * earthRadius * math.Asin(math.Sqrt(h)))
+	return &distance
+}
@@ -0,0 +1,345 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/lib/pq"
+	"github.com/olzzhas/qrent/pkg/validator"
+	"time"
+)
+
+var (
+	ErrPowerbankNotAvailable = errors.New("powerbank is not available for rent at this station")
+	ErrRentalNotActive       = errors.New("rental is not active")
+	ErrRentalNotOverdue      = errors.New("rental is not overdue")
+)
+
+type RentalStatus string
+
+const (
+	RentalStatusActive   RentalStatus = "active"
+	RentalStatusOverdue  RentalStatus = "overdue"
+	RentalStatusFinished RentalStatus = "finished"
+	RentalStatusLost     RentalStatus = "lost"
+)
+
+// IsOpen сообщает, находится ли повербанк всё ещё у арендатора: просроченную
+// аренду можно завершить обычным возвратом.
+func (s RentalStatus) IsOpen() bool {
+	return s == RentalStatusActive || s == RentalStatusOverdue
+}
+
+type Rental struct {
+	ID             int          `json:"id"`
+	UserID         *int         `json:"user_id"`
+	PowerbankID    int          `json:"powerbank_id"`
+	StartStationID int          `json:"start_station_id"`
+	StartSlot      *int         `json:"start_slot_number"`
+	EndStationID   *int         `json:"end_station_id"`
+	EndSlot        *int         `json:"end_slot_number"`
+	Status         RentalStatus `json:"status"`
+	TariffID       *int         `json:"tariff_id"`
+	Price          *int64       `json:"price"`
+	Currency       *string      `json:"currency"`
+	StartedAt      time.Time    `json:"started_at"`
+	EndedAt        *time.Time   `json:"ended_at"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+}
+
+type RentalModel struct {
+	DB    *sql.DB
+	Redis *redis.Client
+}
+
+func ValidateRentalStart(v *validator.Validator, r *Rental) {
+	v.Check(r.PowerbankID > 0, "powerbank_id", "must be a positive integer")
+	v.Check(r.StartStationID > 0, "station_id", "must be a positive integer")
+}
+
+func ValidateRentalReturn(v *validator.Validator, stationID int, slot *int) {
+	v.Check(stationID > 0, "station_id", "must be a positive integer")
+	if slot != nil {
+		v.Check(*slot > 0, "slot_number", "must be a positive integer")
+	}
+}
+
+// Start выдаёт повербанк со станции: в одной транзакции блокирует строку
+// повербанка, проверяет, что он доступен на указанной станции, переводит его
+// в статус rented, освобождая слот, создаёт запись аренды и событие в
+// истории повербанка. Действующий тариф станции копируется в аренду: по нему
+// она и будет оплачена. Депозит deposit, если он есть, привязывается к аренде в
+// той же транзакции, чтобы аренда не осталась без своей заморозки.
+func (m RentalModel) Start(r *Rental, deposit *Payment) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var (
+		status    PowerbankStatus
+		stationID int
+	)
+	err = tx.QueryRowContext(ctx, `
+		SELECT status, current_station_id, slot_number
+		FROM powerbanks
+		WHERE id = $1 AND deleted_at IS NULL
+		FOR UPDATE
+	`, r.PowerbankID).Scan(&status, &stationID, &r.StartSlot)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return err
+	}
+
+	if !status.CanTransitionTo(PowerbankStatusRented) || stationID != r.StartStationID {
+		return ErrPowerbankNotAvailable
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE powerbanks
+		SET status = $1,
+			slot_number = NULL,
+			version = version + 1,
+			updated_at = NOW()
+		WHERE id = $2
+	`, PowerbankStatusRented, r.PowerbankID)
+	if err != nil {
+		return err
+	}
+
+	tariff, err := tariffForStation(ctx, tx, r.StartStationID)
+	switch {
+	case err == nil:
+		r.TariffID = &tariff.ID
+	case !errors.Is(err, ErrRecordNotFound):
+		return err
+	}
+
+	r.Status = RentalStatusActive
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO rentals (user_id, powerbank_id, start_station_id, start_slot_number, status,
+			tariff_id, tariff_snapshot)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, started_at, created_at, updated_at
+	`, r.UserID, r.PowerbankID, r.StartStationID, r.StartSlot, r.Status, r.TariffID, tariffSnapshot{tariff}).
+		Scan(&r.ID, &r.StartedAt, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		var pgerr *pq.Error
+		if errors.As(err, &pgerr) && pgerr.Code == "23505" {
+			return ErrPowerbankNotAvailable
+		}
+		return err
+	}
+
+	err = recordPowerbankEvent(ctx, tx, &PowerbankEvent{
+		PowerbankID:   r.PowerbankID,
+		OldStatus:     &status,
+		NewStatus:     PowerbankStatusRented,
+		FromStationID: &stationID,
+		ToStationID:   &stationID,
+		ActorID:       r.UserID,
+		RentalID:      &r.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if deposit != nil {
+		result, err := tx.ExecContext(ctx, `
+			UPDATE payments
+			SET rental_id = $1,
+				updated_at = NOW()
+			WHERE id = $2
+		`, r.ID, deposit.ID)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return fmt.Errorf("deposit payment %d does not exist", deposit.ID)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if deposit != nil {
+		deposit.RentalID = &r.ID
+	}
+
+	invalidatePowerbanks(m.Redis, r.PowerbankID)
+	return nil
+}
+
+// Finish завершает аренду возвратом повербанка в слот slot станции stationID
+// (nil — первый свободный). Повербанк переходит на новую станцию в статусе
+// charging; возврат на заполненную станцию отклоняется с ErrStationFull.
+// Стоимость считается по тарифу, скопированному в аренду при выдаче: правка
+// тарифа во время аренды цену не меняет. К просроченной аренде добавляется
+// плата за просрочку. Если при выдаче тарифа не было, цена остаётся пустой.
+func (m RentalModel) Finish(r *Rental, stationID int, slot *int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var (
+		status   RentalStatus
+		snapshot tariffSnapshot
+	)
+	err = tx.QueryRowContext(ctx, `
+		SELECT status, tariff_snapshot
+		FROM rentals
+		WHERE id = $1
+		FOR UPDATE
+	`, r.ID).Scan(&status, &snapshot)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return err
+	}
+
+	if !status.IsOpen() {
+		return ErrRentalNotActive
+	}
+
+	pbStatus, fromStationID, err := lockPowerbank(ctx, tx, r.PowerbankID)
+	if err != nil {
+		return err
+	}
+	if !pbStatus.CanTransitionTo(PowerbankStatusCharging) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, pbStatus, PowerbankStatusCharging)
+	}
+
+	endSlot, err := claimSlot(ctx, tx, stationID, slot, r.PowerbankID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE powerbanks
+		SET current_station_id = $1,
+			slot_number = $2,
+			status = $3,
+			version = version + 1,
+			updated_at = NOW()
+		WHERE id = $4
+	`, stationID, endSlot, PowerbankStatusCharging, r.PowerbankID)
+	if err != nil {
+		var pgerr *pq.Error
+		if errors.As(err, &pgerr) && pgerr.Code == "23503" {
+			return ErrInvalidForeignKey
+		}
+		return err
+	}
+
+	err = recordPowerbankEvent(ctx, tx, &PowerbankEvent{
+		PowerbankID:   r.PowerbankID,
+		OldStatus:     &pbStatus,
+		NewStatus:     PowerbankStatusCharging,
+		FromStationID: &fromStationID,
+		ToStationID:   &stationID,
+		ActorID:       r.UserID,
+		RentalID:      &r.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	endedAt := time.Now()
+	if tariff := snapshot.tariff; tariff != nil {
+		price := tariff.Price(endedAt.Sub(r.StartedAt))
+		if status == RentalStatusOverdue {
+			price += tariff.LateFee
+		}
+		r.TariffID, r.Price, r.Currency = &tariff.ID, &price, &tariff.Currency
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		UPDATE rentals
+		SET end_station_id = $1,
+			end_slot_number = $2,
+			status = $3,
+			ended_at = $4,
+			tariff_id = $5,
+			price = $6,
+			currency = $7,
+			updated_at = NOW()
+		WHERE id = $8
+		RETURNING end_station_id, end_slot_number, status, ended_at, updated_at
+	`, stationID, endSlot, RentalStatusFinished, endedAt, r.TariffID, r.Price, r.Currency, r.ID).
+		Scan(&r.EndStationID, &r.EndSlot, &r.Status, &r.EndedAt, &r.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	invalidatePowerbanks(m.Redis, r.PowerbankID)
+	return nil
+}
+
+// IsOwnedBy сообщает, была ли аренда начата пользователем user.
+func (r *Rental) IsOwnedBy(user *User) bool {
+	return r.UserID != nil && *r.UserID == user.ID
+}
+
+func (m RentalModel) Get(id int) (*Rental, error) {
+	query := `
+		SELECT id, user_id, powerbank_id, start_station_id, start_slot_number,
+			end_station_id, end_slot_number, status, tariff_id, price, currency,
+			started_at, ended_at, created_at, updated_at
+		FROM rentals
+		WHERE id = $1
+	`
+	var r Rental
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&r.ID,
+		&r.UserID,
+		&r.PowerbankID,
+		&r.StartStationID,
+		&r.StartSlot,
+		&r.EndStationID,
+		&r.EndSlot,
+		&r.Status,
+		&r.TariffID,
+		&r.Price,
+		&r.Currency,
+		&r.StartedAt,
+		&r.EndedAt,
+		&r.CreatedAt,
+		&r.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &r, nil
+}
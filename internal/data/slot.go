@@ -0,0 +1,147 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrStationFull           = errors.New("station has no free slots")
+	ErrSlotOccupied          = errors.New("slot is already occupied")
+	ErrInvalidSlot           = errors.New("slot number exceeds station capacity")
+	ErrCapacityBelowOccupied = errors.New("capacity is less than the highest occupied slot")
+)
+
+// StationSlot — один слот станции. PowerbankID и Status равны nil, если слот
+// свободен.
+type StationSlot struct {
+	SlotNumber  int              `json:"slot_number"`
+	PowerbankID *int             `json:"powerbank_id"`
+	Status      *PowerbankStatus `json:"status"`
+}
+
+// Slots возвращает все слоты станции от 1 до capacity с повербанками в них.
+func (m StationModel) Slots(id int) ([]*StationSlot, error) {
+	query := `
+        SELECT gs.n, p.id, p.status
+        FROM stations s
+        CROSS JOIN LATERAL generate_series(1, s.capacity) AS gs(n)
+        LEFT JOIN powerbanks p ON p.current_station_id = s.id AND p.slot_number = gs.n
+        WHERE s.id = $1
+        ORDER BY gs.n
+    `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	slots := make([]*StationSlot, 0)
+	for rows.Next() {
+		var slot StationSlot
+		if err := rows.Scan(&slot.SlotNumber, &slot.PowerbankID, &slot.Status); err != nil {
+			return nil, err
+		}
+		slots = append(slots, &slot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return slots, nil
+}
+
+// claimSlot выбирает слот на станции stationID для повербанка powerbankID
+// (0 — для ещё не созданного). Строка станции блокируется до конца транзакции,
+// поэтому параллельные возвраты на одну станцию не займут один и тот же слот.
+// Если slot равен nil, берётся свободный слот с наименьшим номером. Удалённая
+// станция считается несуществующей.
+func claimSlot(ctx context.Context, tx *sql.Tx, stationID int, slot *int, powerbankID int) (int, error) {
+	var capacity int
+	err := tx.QueryRowContext(ctx, `
+		SELECT capacity
+		FROM stations
+		WHERE id = $1 AND deleted_at IS NULL
+		FOR UPDATE
+	`, stationID).Scan(&capacity)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrInvalidForeignKey
+		}
+		return 0, err
+	}
+
+	occupied, err := occupiedSlots(ctx, tx, stationID, powerbankID)
+	if err != nil {
+		return 0, err
+	}
+
+	return pickSlot(capacity, occupied, slot)
+}
+
+// querier — общее у *sql.DB и *sql.Tx: запрос можно выполнить как в
+// транзакции, так и без неё.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// occupiedSlots возвращает занятые слоты станции stationID, кроме слота
+// повербанка powerbankID.
+func occupiedSlots(ctx context.Context, q querier, stationID, powerbankID int) (map[int]bool, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT slot_number
+		FROM powerbanks
+		WHERE current_station_id = $1 AND slot_number IS NOT NULL AND id <> $2
+	`, stationID, powerbankID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	occupied := make(map[int]bool)
+	for rows.Next() {
+		var n int
+		if err := rows.Scan(&n); err != nil {
+			return nil, err
+		}
+		occupied[n] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return occupied, nil
+}
+
+// pickSlot проверяет слот slot станции вместимостью capacity или, если slot
+// равен nil, выбирает свободный слот с наименьшим номером.
+func pickSlot(capacity int, occupied map[int]bool, slot *int) (int, error) {
+	if len(occupied) >= capacity {
+		return 0, ErrStationFull
+	}
+
+	if slot != nil {
+		switch {
+		case *slot < 1 || *slot > capacity:
+			return 0, ErrInvalidSlot
+		case occupied[*slot]:
+			return 0, ErrSlotOccupied
+		}
+		return *slot, nil
+	}
+
+	for n := 1; n <= capacity; n++ {
+		if !occupied[n] {
+			return n, nil
+		}
+	}
+
+	return 0, ErrStationFull
+}
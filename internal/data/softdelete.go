@@ -0,0 +1,29 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+)
+
+// returningIDs выполняет UPDATE ... RETURNING id в транзакции tx и возвращает
+// идентификаторы затронутых строк, чтобы после коммита сбросить их кэш.
+// Мягкое удаление и восстановление помечают вложенные строки тем же
+// deleted_at, что и родителя: NOW() постоянен в пределах транзакции.
+func returningIDs(ctx context.Context, tx *sql.Tx, query string, args ...any) ([]int, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
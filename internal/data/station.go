@@ -11,11 +11,29 @@ import (
 	"time"
 )
 
+// Station — точка выдачи повербанков. Latitude и Longitude могут быть nil
+// только у станций, созданных до появления геолокации.
 type Station struct {
-	ID        int       `json:"id"`
-	OrgID     int       `json:"org_id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID           int                  `json:"id"`
+	OrgID        int                  `json:"org_id"`
+	Latitude     *float64             `json:"latitude"`
+	Longitude    *float64             `json:"longitude"`
+	Address      string               `json:"address"`
+	Capacity     int                  `json:"capacity"`
+	Availability *StationAvailability `json:"availability,omitempty"`
+	Distance     *float64             `json:"distance,omitempty"`
+	CreatedAt    time.Time            `json:"created_at"`
+	UpdatedAt    time.Time            `json:"updated_at"`
+	DeletedAt    *time.Time           `json:"deleted_at,omitempty"`
+	Version      int                  `json:"version"`
+}
+
+// StationAvailability — число повербанков станции по статусам. Rented считает
+// повербанки, взятые с этой станции и ещё не возвращённые.
+type StationAvailability struct {
+	Available int `json:"available"`
+	Charging  int `json:"charging"`
+	Rented    int `json:"rented"`
 }
 
 type StationModel struct {
@@ -25,19 +43,101 @@ type StationModel struct {
 
 func ValidateStation(v *validator.Validator, s *Station) {
 	v.Check(s.OrgID > 0, "org_id", "must be a positive integer")
+
+	v.Check(s.Latitude != nil, "latitude", "must be provided")
+	if s.Latitude != nil {
+		ValidateLatitude(v, "latitude", *s.Latitude)
+	}
+
+	v.Check(s.Longitude != nil, "longitude", "must be provided")
+	if s.Longitude != nil {
+		ValidateLongitude(v, "longitude", *s.Longitude)
+	}
+
+	v.Check(s.Address != "", "address", "must be provided")
+	v.Check(len(s.Address) <= 500, "address", "must not be more than 500 bytes long")
+
+	v.Check(s.Capacity > 0, "capacity", "must be greater than zero")
+	v.Check(s.Capacity <= 100, "capacity", "must be a maximum of 100")
 }
 
-func (m StationModel) Insert(station *Station) error {
-	query := `
-        INSERT INTO stations (org_id)
-        VALUES ($1)
-        RETURNING id, created_at, updated_at
-    `
+func ValidateLatitude(v *validator.Validator, key string, lat float64) {
+	v.Check(lat >= -90 && lat <= 90, key, "must be between -90 and 90")
+}
+
+func ValidateLongitude(v *validator.Validator, key string, lon float64) {
+	v.Check(lon >= -180 && lon <= 180, key, "must be between -180 and 180")
+}
+
+func (m StationModel) Insert(station *Station, audit *Audit) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, station.OrgID).
-		Scan(&station.ID, &station.CreatedAt, &station.UpdatedAt)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertStation(ctx, tx, station); err != nil {
+		return err
+	}
+
+	if err := audit.record(ctx, tx, AuditActionCreate, AuditResourceStation, station.ID, nil, station); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	invalidateStations(m.Redis)
+	return nil
+}
+
+// Import вставляет станции одной транзакцией: либо все, либо ни одной.
+// Ошибка вставки возвращается как *BulkError с позицией станции в stations.
+// Записи аудита обо всех станциях пишутся одним запросом в той же транзакции.
+func (m StationModel) Import(stations []*Station, audit *Audit) error {
+	ctx, cancel := context.WithTimeout(context.Background(), bulkTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	changes := make([]auditChange, 0, len(stations))
+	for i, station := range stations {
+		if err := insertStation(ctx, tx, station); err != nil {
+			return &BulkError{Index: i, Err: err}
+		}
+		changes = append(changes, auditChange{ID: station.ID, After: station})
+	}
+
+	if err := audit.recordBatch(ctx, tx, AuditActionCreate, AuditResourceStation, changes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	invalidateStations(m.Redis)
+	return nil
+}
+
+func insertStation(ctx context.Context, q queryRower, station *Station) error {
+	query := `
+        INSERT INTO stations (org_id, latitude, longitude, address, capacity)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, created_at, updated_at, version
+    `
+	args := []any{station.OrgID, station.Latitude, station.Longitude, station.Address, station.Capacity}
+
+	err := q.QueryRowContext(ctx, query, args...).
+		Scan(&station.ID, &station.CreatedAt, &station.UpdatedAt, &station.Version)
 	if err != nil {
 		var pgerr *pq.Error
 		if errors.As(err, &pgerr) && pgerr.Code == "23503" {
@@ -49,21 +149,55 @@ func (m StationModel) Insert(station *Station) error {
 	return nil
 }
 
+// Get возвращает станцию, если она не удалена.
 func (m StationModel) Get(id int) (*Station, error) {
+	var station Station
+	if cacheGet(m.Redis, stationCacheKey(id), &station) {
+		return &station, nil
+	}
+
+	found, err := m.get(id, false)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheSet(m.Redis, stationCacheKey(id), found, stationCacheTTL)
+	return found, nil
+}
+
+// GetWithDeleted возвращает станцию, в том числе мягко удалённую. Результат
+// не кэшируется.
+func (m StationModel) GetWithDeleted(id int) (*Station, error) {
+	return m.get(id, true)
+}
+
+func (m StationModel) get(id int, includeDeleted bool) (*Station, error) {
 	query := `
-        SELECT id, org_id, created_at, updated_at
+        SELECT id, org_id, latitude, longitude, address, capacity, created_at, updated_at, version, deleted_at
         FROM stations
-        WHERE id = $1
+        WHERE id = $1 AND (deleted_at IS NULL OR $2)
     `
 	var station Station
+
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, id).
-		Scan(&station.ID, &station.OrgID, &station.CreatedAt, &station.UpdatedAt)
+	err := m.DB.QueryRowContext(ctx, query, id, includeDeleted).
+		Scan(
+			&station.ID,
+			&station.OrgID,
+			&station.Latitude,
+			&station.Longitude,
+			&station.Address,
+			&station.Capacity,
+			&station.CreatedAt,
+			&station.UpdatedAt,
+			&station.Version,
+			&station.DeletedAt,
+		)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("station not found: id %d", id)
+			return nil, ErrRecordNotFound
 		}
 		return nil, err
 	}
@@ -71,21 +205,61 @@ func (m StationModel) Get(id int) (*Station, error) {
 	return &station, nil
 }
 
-func (m StationModel) Update(station *Station) error {
+// Update сохраняет станцию. Ёмкость нельзя уменьшить ниже номера самого
+// старшего занятого слота: строка станции блокируется, чтобы параллельный
+// возврат не занял слот между проверкой и обновлением. Удалённая станция
+// возвращает ErrRecordNotFound; если версия станции в базе уже не
+// station.Version — ErrEditConflict.
+func (m StationModel) Update(station *Station, audit *Audit) error {
 	query := `
         UPDATE stations
         SET org_id = $1,
+            latitude = $2,
+            longitude = $3,
+            address = $4,
+            capacity = $5,
+            version = version + 1,
             updated_at = NOW()
-        WHERE id = $2
-        RETURNING updated_at
+        WHERE id = $6 AND version = $7
+        RETURNING updated_at, version
     `
+	args := []any{station.OrgID, station.Latitude, station.Longitude, station.Address, station.Capacity, station.ID, station.Version}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, station.OrgID, station.ID).Scan(&station.UpdatedAt)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var highestSlot int
+	err = tx.QueryRowContext(ctx, `
+        SELECT (
+            SELECT coalesce(max(slot_number), 0)
+            FROM powerbanks
+            WHERE current_station_id = s.id
+        )
+        FROM stations s
+        WHERE s.id = $1 AND s.deleted_at IS NULL
+        FOR UPDATE
+    `, station.ID).Scan(&highestSlot)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return fmt.Errorf("no station found with id %d", station.ID)
+			return ErrRecordNotFound
+		}
+		return err
+	}
+
+	if station.Capacity < highestSlot {
+		return ErrCapacityBelowOccupied
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&station.UpdatedAt, &station.Version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEditConflict
 		}
 
 		var pgerr *pq.Error
@@ -96,18 +270,39 @@ func (m StationModel) Update(station *Station) error {
 		return err
 	}
 
+	if err := audit.record(ctx, tx, AuditActionUpdate, AuditResourceStation, station.ID, audit.before(), station); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	invalidateStations(m.Redis, station.ID)
+	// Смена организации меняет и списки повербанков организаций.
+	invalidatePowerbanks(m.Redis)
 	return nil
 }
 
-func (m StationModel) Delete(id int) error {
-	query := `
-        DELETE FROM stations
-        WHERE id = $1
-    `
+// Delete мягко удаляет станцию вместе с повербанками на ней, кроме выданных
+// в аренду. Все строки получают одну отметку deleted_at, по ней Restore
+// вернёт их.
+func (m StationModel) Delete(id int, audit *Audit) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	result, err := m.DB.ExecContext(ctx, query, id)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+        UPDATE stations
+        SET deleted_at = NOW(),
+            version = version + 1
+        WHERE id = $1 AND deleted_at IS NULL
+    `, id)
 	if err != nil {
 		return err
 	}
@@ -117,43 +312,313 @@ func (m StationModel) Delete(id int) error {
 		return err
 	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("no station found with id %d", id)
+		return ErrRecordNotFound
+	}
+
+	powerbankIDs, err := returningIDs(ctx, tx, `
+        UPDATE powerbanks
+        SET deleted_at = NOW(),
+            version = version + 1
+        WHERE current_station_id = $1 AND deleted_at IS NULL AND status <> $2
+        RETURNING id
+    `, id, PowerbankStatusRented)
+	if err != nil {
+		return err
+	}
+
+	if err := audit.record(ctx, tx, AuditActionDelete, AuditResourceStation, id, audit.before(), nil); err != nil {
+		return err
 	}
 
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	invalidateStations(m.Redis, id)
+	invalidatePowerbanks(m.Redis, powerbankIDs...)
 	return nil
 }
 
-func (m StationModel) List() ([]*Station, error) {
+// Restore возвращает мягко удалённую станцию и повербанки, удалённые вместе
+// с ней. Станцию удалённой организации восстановить нельзя: ErrParentDeleted.
+// Возвращает восстановленную станцию.
+func (m StationModel) Restore(id int, audit *Audit) (*Station, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		deletedAt  *time.Time
+		orgDeleted bool
+	)
+	err = tx.QueryRowContext(ctx, `
+        SELECT s.deleted_at, o.deleted_at IS NOT NULL
+        FROM stations s
+        INNER JOIN organizations o ON o.id = s.org_id
+        WHERE s.id = $1
+        FOR UPDATE OF s
+    `, id).Scan(&deletedAt, &orgDeleted)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	switch {
+	case deletedAt == nil:
+		return nil, ErrNotDeleted
+	case orgDeleted:
+		return nil, ErrParentDeleted
+	}
+
+	station := Station{ID: id}
+	err = tx.QueryRowContext(ctx, `
+        UPDATE stations
+        SET deleted_at = NULL,
+            version = version + 1,
+            updated_at = NOW()
+        WHERE id = $1
+        RETURNING org_id, latitude, longitude, address, capacity, created_at, updated_at, version
+    `, id).Scan(
+		&station.OrgID,
+		&station.Latitude,
+		&station.Longitude,
+		&station.Address,
+		&station.Capacity,
+		&station.CreatedAt,
+		&station.UpdatedAt,
+		&station.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	powerbankIDs, err := returningIDs(ctx, tx, `
+        UPDATE powerbanks
+        SET deleted_at = NULL,
+            version = version + 1
+        WHERE current_station_id = $1 AND deleted_at = $2
+        RETURNING id
+    `, id, *deletedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := audit.record(ctx, tx, AuditActionRestore, AuditResourceStation, id, nil, &station); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	invalidateStations(m.Redis, id)
+	invalidatePowerbanks(m.Redis, powerbankIDs...)
+	return &station, nil
+}
+
+// List возвращает страницу станций вместе с их доступностью. Ненулевой orgID
+// ограничивает выборку станциями одной организации; includeDeleted добавляет
+// мягко удалённые станции.
+func (m StationModel) List(orgID int, includeDeleted bool, filters Filters) ([]*Station, Metadata, error) {
+	query := fmt.Sprintf(`
+        SELECT count(*) OVER(), s.id, s.org_id, s.latitude, s.longitude, s.address,
+            s.capacity, s.created_at, s.updated_at, s.version, s.deleted_at,
+            count(p.id) FILTER (WHERE p.status = 'available'),
+            count(p.id) FILTER (WHERE p.status = 'charging'),
+            count(p.id) FILTER (WHERE p.status = 'rented')
+        FROM stations s
+        LEFT JOIN powerbanks p ON p.current_station_id = s.id AND p.deleted_at IS NULL
+        WHERE (s.org_id = $1 OR $1 = 0)
+        AND (s.deleted_at IS NULL OR $2)
+        GROUP BY s.id
+        ORDER BY s.%s %s, s.id ASC
+        LIMIT $3 OFFSET $4
+    `, filters.sortColumn(), filters.sortDirection())
+
+	var cached struct {
+		Stations []*Station
+		Metadata Metadata
+	}
+	cacheKey := cacheListKey(m.Redis, "stations", orgID, includeDeleted, filters.Sort, filters.Page, filters.PageSize)
+	if cacheGet(m.Redis, cacheKey, &cached) {
+		return cached.Stations, cached.Metadata, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, orgID, includeDeleted, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	stations := make([]*Station, 0)
+	for rows.Next() {
+		station := Station{Availability: &StationAvailability{}}
+		if err := rows.Scan(
+			&totalRecords,
+			&station.ID,
+			&station.OrgID,
+			&station.Latitude,
+			&station.Longitude,
+			&station.Address,
+			&station.Capacity,
+			&station.CreatedAt,
+			&station.UpdatedAt,
+			&station.Version,
+			&station.DeletedAt,
+			&station.Availability.Available,
+			&station.Availability.Charging,
+			&station.Availability.Rented,
+		); err != nil {
+			return nil, Metadata{}, err
+		}
+		stations = append(stations, &station)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	cached.Stations, cached.Metadata = stations, metadata
+	cacheSet(m.Redis, cacheKey, cached, stationCacheTTL)
+	return stations, metadata, nil
+}
+
+// Export передаёт fn все неудалённые станции по возрастанию id, не собирая
+// их в память. Ненулевой orgID ограничивает выборку одной организацией.
+// Ошибка fn прекращает выборку и возвращается как есть.
+func (m StationModel) Export(orgID int, fn func(*Station) error) error {
 	query := `
-        SELECT id, org_id, created_at, updated_at
+        SELECT id, org_id, latitude, longitude, address, capacity, created_at, updated_at, version
         FROM stations
-        ORDER BY id
+        WHERE (org_id = $1 OR $1 = 0)
+        AND deleted_at IS NULL
+        ORDER BY id ASC
+    `
+
+	ctx, cancel := context.WithTimeout(context.Background(), bulkTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, orgID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var station Station
+		if err := rows.Scan(
+			&station.ID,
+			&station.OrgID,
+			&station.Latitude,
+			&station.Longitude,
+			&station.Address,
+			&station.Capacity,
+			&station.CreatedAt,
+			&station.UpdatedAt,
+			&station.Version,
+		); err != nil {
+			return err
+		}
+		if err := fn(&station); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// Nearby возвращает станции в радиусе radius метров от точки (lat, lon),
+// отсортированные по расстоянию, вместе с их доступностью. earth_box отбирает
+// кандидатов по индексу idx_stations_location, earth_distance отсекает углы
+// квадрата. Станции без координат в выдачу не попадают.
+func (m StationModel) Nearby(lat, lon, radius float64, filters Filters) ([]*Station, Metadata, error) {
+	query := `
+        SELECT count(*) OVER(), s.id, s.org_id, s.latitude, s.longitude, s.address,
+            s.capacity, s.created_at, s.updated_at, s.version,
+            count(p.id) FILTER (WHERE p.status = 'available'),
+            count(p.id) FILTER (WHERE p.status = 'charging'),
+            count(p.id) FILTER (WHERE p.status = 'rented'),
+            earth_distance(ll_to_earth(s.latitude, s.longitude), ll_to_earth($1, $2)) AS distance
+        FROM stations s
+        LEFT JOIN powerbanks p ON p.current_station_id = s.id AND p.deleted_at IS NULL
+        WHERE earth_box(ll_to_earth($1, $2), $3) @> ll_to_earth(s.latitude, s.longitude)
+        AND earth_distance(ll_to_earth(s.latitude, s.longitude), ll_to_earth($1, $2)) <= $3
+        AND s.deleted_at IS NULL
+        GROUP BY s.id
+        ORDER BY distance ASC, s.id ASC
+        LIMIT $4 OFFSET $5
     `
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	rows, err := m.DB.QueryContext(ctx, query)
+	rows, err := m.DB.QueryContext(ctx, query, lat, lon, radius, filters.limit(), filters.offset())
 	if err != nil {
-		return nil, err
+		return nil, Metadata{}, err
 	}
 	defer rows.Close()
 
+	totalRecords := 0
 	stations := make([]*Station, 0)
 	for rows.Next() {
-		var station Station
+		station := Station{Availability: &StationAvailability{}}
 		if err := rows.Scan(
+			&totalRecords,
 			&station.ID,
 			&station.OrgID,
+			&station.Latitude,
+			&station.Longitude,
+			&station.Address,
+			&station.Capacity,
 			&station.CreatedAt,
 			&station.UpdatedAt,
+			&station.Version,
+			&station.Availability.Available,
+			&station.Availability.Charging,
+			&station.Availability.Rented,
+			&station.Distance,
 		); err != nil {
-			return nil, err
+			return nil, Metadata{}, err
 		}
 		stations = append(stations, &station)
 	}
 	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return stations, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// Availability считает повербанки станции по статусам. Результат не кэшируется:
+// приложение арендатора показывает его на карте и ждёт актуальных цифр.
+func (m StationModel) Availability(id int) (*StationAvailability, error) {
+	query := `
+        SELECT count(*) FILTER (WHERE status = 'available'),
+            count(*) FILTER (WHERE status = 'charging'),
+            count(*) FILTER (WHERE status = 'rented')
+        FROM powerbanks
+        WHERE current_station_id = $1 AND deleted_at IS NULL
+    `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var availability StationAvailability
+	err := m.DB.QueryRowContext(ctx, query, id).
+		Scan(&availability.Available, &availability.Charging, &availability.Rented)
+	if err != nil {
 		return nil, err
 	}
 
-	return stations, nil
+	return &availability, nil
 }
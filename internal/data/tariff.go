@@ -0,0 +1,287 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/lib/pq"
+	"github.com/olzzhas/qrent/pkg/validator"
+	"math"
+	"regexp"
+	"time"
+)
+
+const minutesPerDay = 24 * 60
+
+var currencyRX = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// Tariff — коммерческие условия аренды. Тариф задаётся для организации целиком
+// (OrgID) или для отдельной станции (StationID); тариф станции важнее.
+// Все суммы — в минимальных единицах валюты Currency.
+type Tariff struct {
+	ID            int       `json:"id"`
+	OrgID         *int      `json:"org_id"`
+	StationID     *int      `json:"station_id"`
+	Currency      string    `json:"currency"`
+	UnlockFee     int64     `json:"unlock_fee"`
+	PeriodPrice   int64     `json:"period_price"`
+	PeriodMinutes int       `json:"period_minutes"`
+	FreeMinutes   int       `json:"free_minutes"`
+	DailyCap      *int64    `json:"daily_cap"`
+	LostPenalty   int64     `json:"lost_penalty"`
+	LateFee       int64     `json:"late_fee"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+type TariffModel struct {
+	DB    *sql.DB
+	Redis *redis.Client
+}
+
+func ValidateTariff(v *validator.Validator, t *Tariff) {
+	v.Check((t.OrgID == nil) != (t.StationID == nil), "tariff", "must belong to either an organization or a station")
+	v.Check(currencyRX.MatchString(t.Currency), "currency", "must be a 3-letter ISO 4217 code")
+	v.Check(t.UnlockFee >= 0, "unlock_fee", "must not be negative")
+	v.Check(t.PeriodPrice >= 0, "period_price", "must not be negative")
+	v.Check(t.PeriodMinutes > 0, "period_minutes", "must be greater than zero")
+	v.Check(t.PeriodMinutes <= minutesPerDay, "period_minutes", "must be a maximum of 1440")
+	v.Check(t.FreeMinutes >= 0, "free_minutes", "must not be negative")
+	v.Check(t.FreeMinutes <= minutesPerDay, "free_minutes", "must be a maximum of 1440")
+	if t.DailyCap != nil {
+		v.Check(*t.DailyCap >= 0, "daily_cap", "must not be negative")
+	}
+	v.Check(t.LostPenalty >= 0, "lost_penalty", "must not be negative")
+	v.Check(t.LateFee >= 0, "late_fee", "must not be negative")
+}
+
+// Price считает стоимость аренды длительностью d. Начатая минута и начатый
+// период оплачиваются полностью. Аренда не дольше бесплатных минут ничего не
+// стоит, иначе к цене периодов добавляется плата за разблокировку. Дневной
+// лимит ограничивает цену периодов за каждые начатые сутки.
+func (t *Tariff) Price(d time.Duration) int64 {
+	minutes := int64(math.Ceil(d.Minutes()))
+	if minutes <= int64(t.FreeMinutes) {
+		return 0
+	}
+
+	billable := minutes - int64(t.FreeMinutes)
+	periods := (billable + int64(t.PeriodMinutes) - 1) / int64(t.PeriodMinutes)
+	usage := periods * t.PeriodPrice
+
+	if t.DailyCap != nil {
+		days := (minutes + minutesPerDay - 1) / minutesPerDay
+		usage = min(usage, days*(*t.DailyCap))
+	}
+
+	return t.UnlockFee + usage
+}
+
+const tariffColumns = `id, org_id, station_id, currency, unlock_fee, period_price,
+		period_minutes, free_minutes, daily_cap, lost_penalty, late_fee, created_at, updated_at`
+
+// queryRower — общее у *sql.DB и *sql.Tx, чтобы тариф можно было прочитать
+// внутри транзакции аренды.
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func scanTariff(row *sql.Row) (*Tariff, error) {
+	var t Tariff
+	err := row.Scan(
+		&t.ID,
+		&t.OrgID,
+		&t.StationID,
+		&t.Currency,
+		&t.UnlockFee,
+		&t.PeriodPrice,
+		&t.PeriodMinutes,
+		&t.FreeMinutes,
+		&t.DailyCap,
+		&t.LostPenalty,
+		&t.LateFee,
+		&t.CreatedAt,
+		&t.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// tariffSnapshot — копия тарифа, которую аренда получает при выдаче
+// (rentals.tariff_snapshot). Аренда оплачивается по ней, а не по тарифу на
+// момент возврата, поэтому правка тарифа не меняет цену уже начатых аренд.
+// Пустой снимок означает, что при выдаче тарифа не было.
+type tariffSnapshot struct {
+	tariff *Tariff
+}
+
+func (s tariffSnapshot) Value() (driver.Value, error) {
+	if s.tariff == nil {
+		return nil, nil
+	}
+	return json.Marshal(s.tariff)
+}
+
+func (s *tariffSnapshot) Scan(src any) error {
+	s.tariff = nil
+	switch src := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(src, &s.tariff)
+	case string:
+		return json.Unmarshal([]byte(src), &s.tariff)
+	default:
+		return fmt.Errorf("tariff snapshot: unsupported type %T", src)
+	}
+}
+
+// tariffForStation возвращает действующий тариф станции: собственный тариф
+// станции, а если его нет — тариф её организации.
+func tariffForStation(ctx context.Context, q queryRower, stationID int) (*Tariff, error) {
+	query := `
+		SELECT ` + tariffColumns + `
+		FROM tariffs
+		WHERE station_id = $1
+		OR org_id = (SELECT org_id FROM stations WHERE id = $1)
+		ORDER BY station_id IS NULL
+		LIMIT 1
+	`
+	return scanTariff(q.QueryRowContext(ctx, query, stationID))
+}
+
+// GetForStation возвращает действующий тариф станции.
+func (m TariffModel) GetForStation(stationID int) (*Tariff, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return tariffForStation(ctx, m.DB, stationID)
+}
+
+// GetForOrganization возвращает тариф организации по умолчанию.
+func (m TariffModel) GetForOrganization(orgID int) (*Tariff, error) {
+	query := `
+		SELECT ` + tariffColumns + `
+		FROM tariffs
+		WHERE org_id = $1
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return scanTariff(m.DB.QueryRowContext(ctx, query, orgID))
+}
+
+// Upsert создаёт тариф владельца или заменяет существующий. В журнал аудита
+// это пишется как создание или правка — смотря, был ли тариф раньше.
+func (m TariffModel) Upsert(t *Tariff, audit *Audit) error {
+	owner, ownerID := "org_id", t.OrgID
+	if t.StationID != nil {
+		owner, ownerID = "station_id", t.StationID
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO tariffs (org_id, station_id, currency, unlock_fee, period_price,
+			period_minutes, free_minutes, daily_cap, lost_penalty, late_fee)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (%[1]s) WHERE %[1]s IS NOT NULL DO UPDATE
+		SET currency = EXCLUDED.currency,
+			unlock_fee = EXCLUDED.unlock_fee,
+			period_price = EXCLUDED.period_price,
+			period_minutes = EXCLUDED.period_minutes,
+			free_minutes = EXCLUDED.free_minutes,
+			daily_cap = EXCLUDED.daily_cap,
+			lost_penalty = EXCLUDED.lost_penalty,
+			late_fee = EXCLUDED.late_fee,
+			updated_at = NOW()
+		RETURNING id, created_at, updated_at
+	`, owner)
+
+	args := []any{
+		t.OrgID,
+		t.StationID,
+		t.Currency,
+		t.UnlockFee,
+		t.PeriodPrice,
+		t.PeriodMinutes,
+		t.FreeMinutes,
+		t.DailyCap,
+		t.LostPenalty,
+		t.LateFee,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	action := AuditActionUpdate
+	before, err := scanTariff(tx.QueryRowContext(ctx, fmt.Sprintf(`
+		SELECT `+tariffColumns+`
+		FROM tariffs
+		WHERE %s = $1
+		FOR UPDATE
+	`, owner), ownerID))
+	if err != nil {
+		if !errors.Is(err, ErrRecordNotFound) {
+			return err
+		}
+		action = AuditActionCreate
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&t.ID, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		var pgerr *pq.Error
+		if errors.As(err, &pgerr) && pgerr.Code == "23503" {
+			return ErrInvalidForeignKey
+		}
+		return err
+	}
+
+	if err := audit.record(ctx, tx, action, AuditResourceTariff, t.ID, before, t); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteForStation удаляет собственный тариф станции; после этого действует
+// тариф организации.
+func (m TariffModel) DeleteForStation(stationID int, audit *Audit) error {
+	query := `
+		DELETE FROM tariffs
+		WHERE station_id = $1
+		RETURNING ` + tariffColumns
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	deleted, err := scanTariff(tx.QueryRowContext(ctx, query, stationID))
+	if err != nil {
+		return err
+	}
+
+	if err := audit.record(ctx, tx, AuditActionDelete, AuditResourceTariff, deleted.ID, deleted, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
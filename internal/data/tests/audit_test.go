@@ -0,0 +1,237 @@
+package data_test
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/olzzhas/qrent/internal/data"
+)
+
+func TestAuditDiff(t *testing.T) {
+	now := time.Now()
+	before := &data.Organization{ID: 1, Name: "Old", Location: "Almaty", CreatedAt: now, UpdatedAt: now}
+	after := *before
+	after.Name = "New"
+
+	tests := []struct {
+		name                  string
+		before, after         any
+		wantBefore, wantAfter string
+	}{
+		{"update keeps changed fields", before, &after, `{"name":"Old"}`, `{"name":"New"}`},
+		{"create has no before", nil, &data.Organization{ID: 2, Name: "A", CreatedAt: now, UpdatedAt: now}, "", "full"},
+		{"typed nil pointer counts as absent", (*data.Organization)(nil), before, "", "full"},
+		{"delete has no after", before, nil, "full", ""},
+	}
+
+	for _, tt := range tests {
+		b, a, err := data.AuditDiff(tt.before, tt.after)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", tt.name, err)
+		}
+		checkAuditJSON(t, tt.name+" before", b, tt.wantBefore)
+		checkAuditJSON(t, tt.name+" after", a, tt.wantAfter)
+	}
+}
+
+func checkAuditJSON(t *testing.T, name string, got json.RawMessage, want string) {
+	t.Helper()
+
+	switch want {
+	case "":
+		if got != nil {
+			t.Errorf("%s: expected null, got %s", name, got)
+		}
+	case "full":
+		var fields map[string]any
+		if err := json.Unmarshal(got, &fields); err != nil || fields["id"] == nil || fields["name"] == nil {
+			t.Errorf("%s: expected the whole resource, got %s", name, got)
+		}
+	default:
+		if string(got) != want {
+			t.Errorf("%s: expected %s, got %s", name, want, got)
+		}
+	}
+}
+
+// auditEntries сверяет пакет записей аудита, переданный в INSERT одним
+// JSON-массивом, с ожидаемыми "действие ресурс id".
+type auditEntries []string
+
+func (want auditEntries) Match(v driver.Value) bool {
+	js, ok := v.(string)
+	if !ok {
+		return false
+	}
+
+	var entries []data.AuditEntry
+	if err := json.Unmarshal([]byte(js), &entries); err != nil || len(entries) != len(want) {
+		return false
+	}
+	for i, e := range entries {
+		if fmt.Sprintf("%s %s %d", e.Action, e.ResourceType, e.ResourceID) != want[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// expectAudit ожидает запись в журнал аудита одним запросом.
+func expectAudit(mock sqlmock.Sqlmock, want ...string) *sqlmock.ExpectedExec {
+	return mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO audit_log`)).WithArgs(auditEntries(want))
+}
+
+func TestOrganizationModel_Update_WritesAudit(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.OrganizationModel{DB: db}
+	actorID := 3
+	updatedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	before := data.Organization{ID: 1, Name: "Old", Location: "Almaty", UpdatedAt: updatedAt.Add(-time.Hour), Version: 1}
+	org := before
+	org.Name = "New"
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE organizations`)).
+		WithArgs("New", "Almaty", 1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at", "version"}).AddRow(updatedAt, 2))
+	mock.ExpectExec(regexp.QuoteMeta(`
+		INSERT INTO audit_log (actor_id, action, resource_type, resource_id, before, after, ip, request_id)
+		SELECT actor_id, action, resource_type, resource_id, NULLIF(before, 'null'), NULLIF(after, 'null'), ip, request_id
+		FROM jsonb_to_recordset($1::jsonb)
+	`)).
+		WithArgs(auditEntry{
+			Action:       data.AuditActionUpdate,
+			ResourceType: data.AuditResourceOrganization,
+			ResourceID:   1,
+			ActorID:      &actorID,
+			Before:       `{"name":"Old","updated_at":"2026-01-02T02:04:05Z","version":1}`,
+			After:        `{"name":"New","updated_at":"2026-01-02T03:04:05Z","version":2}`,
+			IP:           "10.0.0.1",
+			RequestID:    "req-1",
+		}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	audit := &data.Audit{ActorID: &actorID, IP: "10.0.0.1", RequestID: "req-1", Before: &before}
+	if err := model.Update(&org, audit); err != nil {
+		t.Fatalf("unexpected error in Update: %s", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// Сбой записи аудита откатывает само изменение.
+func TestOrganizationModel_Update_AuditFailureRollsBack(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.OrganizationModel{DB: db}
+	org := &data.Organization{ID: 1, Name: "New", Location: "Almaty", Version: 1}
+	auditErr := errors.New("audit_log is unavailable")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE organizations`)).
+		WithArgs("New", "Almaty", 1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at", "version"}).AddRow(time.Now(), 2))
+	expectAudit(mock, "update organization 1").WillReturnError(auditErr)
+	mock.ExpectRollback()
+
+	if err := model.Update(org, &data.Audit{}); !errors.Is(err, auditErr) {
+		t.Errorf("expected error %q, got %v", auditErr, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// auditEntry сверяет единственную запись аудита целиком.
+type auditEntry struct {
+	Action        data.AuditAction
+	ResourceType  string
+	ResourceID    int
+	ActorID       *int
+	Before, After string
+	IP, RequestID string
+}
+
+func (want auditEntry) Match(v driver.Value) bool {
+	js, ok := v.(string)
+	if !ok {
+		return false
+	}
+
+	var entries []data.AuditEntry
+	if err := json.Unmarshal([]byte(js), &entries); err != nil || len(entries) != 1 {
+		return false
+	}
+	e := entries[0]
+
+	return e.Action == want.Action &&
+		e.ResourceType == want.ResourceType &&
+		e.ResourceID == want.ResourceID &&
+		e.ActorID != nil && want.ActorID != nil && *e.ActorID == *want.ActorID &&
+		string(e.Before) == want.Before &&
+		string(e.After) == want.After &&
+		e.IP == want.IP &&
+		e.RequestID == want.RequestID
+}
+
+func TestAuditModel_List(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.AuditModel{DB: db}
+	now := time.Now()
+	from := now.Add(-time.Hour)
+	filters := data.Filters{Page: 1, PageSize: 20, Sort: "-id", SortSafelist: []string{"id", "-id"}}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT count(*) OVER(), id, actor_id, action, resource_type, resource_id, before, after,
+			ip, request_id, created_at
+		FROM audit_log
+		WHERE (resource_type = $1 OR $1 = '')
+		AND (resource_id = $2 OR $2 = 0)
+		AND (actor_id = $3 OR $3 = 0)
+		AND (created_at >= $4 OR $4 IS NULL)
+		AND (created_at < $5 OR $5 IS NULL)
+		ORDER BY id DESC
+		LIMIT $6 OFFSET $7
+	`)).
+		WithArgs(data.AuditResourcePowerbank, 0, 3, &from, nil, 20, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "id", "actor_id", "action", "resource_type", "resource_id",
+			"before", "after", "ip", "request_id", "created_at"}).
+			AddRow(1, 7, 3, data.AuditActionCreate, data.AuditResourcePowerbank, 5, nil, []byte(`{"id":5}`), "10.0.0.1", "req-1", now))
+
+	entries, metadata, err := model.List(data.AuditFilter{ResourceType: data.AuditResourcePowerbank, ActorID: 3, From: &from}, filters)
+	if err != nil {
+		t.Fatalf("unexpected error in List: %s", err)
+	}
+	if len(entries) != 1 || metadata.TotalRecords != 1 {
+		t.Fatalf("expected 1 entry, got %d (total %d)", len(entries), metadata.TotalRecords)
+	}
+	if entries[0].Before != nil || string(entries[0].After) != `{"id":5}` {
+		t.Errorf("unexpected diff: before=%s after=%s", entries[0].Before, entries[0].After)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
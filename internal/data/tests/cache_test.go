@@ -0,0 +1,516 @@
+package data_test
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/olzzhas/qrent/internal/data"
+	"github.com/olzzhas/qrent/internal/redistest"
+)
+
+// cachedRead — закэшированное чтение: expect ожидает запрос в базу при промахе.
+type cachedRead struct {
+	name   string
+	expect func(mock sqlmock.Sqlmock)
+	read   func(m data.Models) error
+}
+
+// cachedReads перечисляет списки ресурсов и отдельные записи организации 1,
+// станции 10 и повербанка 5, свежесть которых проверяют тесты записи.
+func cachedReads() []cachedRead {
+	filters := data.Filters{Page: 1, PageSize: 20, Sort: "id", SortSafelist: []string{"id"}}
+	now := time.Now()
+
+	return []cachedRead{
+		{
+			name: "organizations list",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`FROM organizations`)).
+					WithArgs("", false, 20, 0).
+					WillReturnRows(sqlmock.NewRows([]string{"count", "id", "name", "location", "created_at", "updated_at", "version", "deleted_at"}))
+			},
+			read: func(m data.Models) error {
+				_, _, err := m.Organization.List("", false, filters)
+				return err
+			},
+		},
+		{
+			name: "stations list",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`FROM stations s`)).
+					WithArgs(0, false, 20, 0).
+					WillReturnRows(sqlmock.NewRows([]string{"count", "id", "org_id", "latitude", "longitude", "address", "capacity", "created_at", "updated_at", "version", "deleted_at", "available", "charging", "rented"}))
+			},
+			read: func(m data.Models) error {
+				_, _, err := m.Station.List(0, false, filters)
+				return err
+			},
+		},
+		{
+			name: "powerbanks list",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`FROM powerbanks`)).
+					WithArgs(sqlmock.AnyArg(), 0, false, 20, 0).
+					WillReturnRows(sqlmock.NewRows([]string{"count", "id", "current_station_id", "slot_number", "status", "created_at", "updated_at", "version", "deleted_at"}))
+			},
+			read: func(m data.Models) error {
+				_, _, err := m.Powerbank.List(nil, 0, false, filters)
+				return err
+			},
+		},
+		{
+			name: "organization 1",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`FROM organizations`)).
+					WithArgs(1, false).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "location", "created_at", "updated_at", "version", "deleted_at"}).
+						AddRow(1, "Org", "Almaty", now, now, 1, nil))
+			},
+			read: func(m data.Models) error {
+				_, err := m.Organization.Get(1)
+				return err
+			},
+		},
+		{
+			name: "station 10",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`FROM stations`)).
+					WithArgs(10, false).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "org_id", "latitude", "longitude", "address", "capacity", "created_at", "updated_at", "version", "deleted_at"}).
+						AddRow(10, 1, nil, nil, "Абая 10, Алматы", 4, now, now, 1, nil))
+			},
+			read: func(m data.Models) error {
+				_, err := m.Station.Get(10)
+				return err
+			},
+		},
+		{
+			name: "powerbank 5",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`FROM powerbanks`)).
+					WithArgs(5, false).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "current_station_id", "slot_number", "status", "created_at", "updated_at", "version", "deleted_at"}).
+						AddRow(5, 10, 1, data.PowerbankStatusAvailable, now, now, 1, nil))
+			},
+			read: func(m data.Models) error {
+				_, err := m.Powerbank.Get(5)
+				return err
+			},
+		},
+	}
+}
+
+// testCacheInvalidation прогревает кэш, выполняет запись и проверяет, что из
+// базы перечитываются ровно чтения stale, а остальные отдаются из кэша.
+func testCacheInvalidation(t *testing.T, expectWrite func(mock sqlmock.Sqlmock), write func(m data.Models) error, stale ...string) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	models := data.NewModels(db, redistest.NewClient(t))
+	reads := cachedReads()
+
+	for _, r := range reads {
+		r.expect(mock)
+		if err := r.read(models); err != nil {
+			t.Fatalf("unexpected error priming %s: %s", r.name, err)
+		}
+	}
+	// Повторные чтения не должны ходить в базу.
+	for _, r := range reads {
+		if err := r.read(models); err != nil {
+			t.Fatalf("%s is not served from cache: %s", r.name, err)
+		}
+	}
+
+	expectWrite(mock)
+	if err := write(models); err != nil {
+		t.Fatalf("unexpected error in write: %s", err)
+	}
+
+	isStale := make(map[string]bool, len(stale))
+	for _, name := range stale {
+		isStale[name] = true
+	}
+	for _, r := range reads {
+		if isStale[r.name] {
+			r.expect(mock)
+		}
+		if err := r.read(models); err != nil {
+			t.Errorf("%s was invalidated unexpectedly: %s", r.name, err)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("stale cache served after write: %s", err)
+	}
+}
+
+func TestCache_OrganizationWrites(t *testing.T) {
+	now := time.Now()
+
+	t.Run("insert", func(t *testing.T) {
+		testCacheInvalidation(t,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO organizations`)).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version"}).AddRow(2, now, now, 1))
+				mock.ExpectCommit()
+			},
+			func(m data.Models) error {
+				return m.Organization.Insert(&data.Organization{Name: "Org", Location: "Almaty"}, nil)
+			},
+			"organizations list")
+	})
+
+	t.Run("update", func(t *testing.T) {
+		testCacheInvalidation(t,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta(`UPDATE organizations`)).
+					WillReturnRows(sqlmock.NewRows([]string{"updated_at", "version"}).AddRow(now, 2))
+				mock.ExpectCommit()
+			},
+			func(m data.Models) error {
+				return m.Organization.Update(&data.Organization{ID: 1, Name: "Org", Location: "Astana", Version: 1}, nil)
+			},
+			"organizations list", "organization 1")
+	})
+
+	// Удаление и восстановление каскадом затрагивают станции и повербанки.
+	t.Run("delete", func(t *testing.T) {
+		testCacheInvalidation(t,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE organizations`)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(regexp.QuoteMeta(`UPDATE stations`)).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+				mock.ExpectQuery(regexp.QuoteMeta(`UPDATE powerbanks`)).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+				mock.ExpectCommit()
+			},
+			func(m data.Models) error {
+				return m.Organization.Delete(1, nil)
+			},
+			"organizations list", "stations list", "powerbanks list", "organization 1", "station 10", "powerbank 5")
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		testCacheInvalidation(t,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT deleted_at`)).
+					WillReturnRows(sqlmock.NewRows([]string{"deleted_at"}).AddRow(now))
+				mock.ExpectQuery(regexp.QuoteMeta(`UPDATE organizations`)).
+					WillReturnRows(sqlmock.NewRows([]string{"name", "location", "created_at", "updated_at", "version"}).
+						AddRow("Org", "Almaty", now, now, 3))
+				mock.ExpectQuery(regexp.QuoteMeta(`UPDATE stations`)).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+				mock.ExpectQuery(regexp.QuoteMeta(`UPDATE powerbanks`)).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+				mock.ExpectCommit()
+			},
+			func(m data.Models) error {
+				_, err := m.Organization.Restore(1, nil)
+				return err
+			},
+			"organizations list", "stations list", "powerbanks list", "organization 1", "station 10", "powerbank 5")
+	})
+}
+
+func TestCache_StationWrites(t *testing.T) {
+	now := time.Now()
+
+	t.Run("insert", func(t *testing.T) {
+		testCacheInvalidation(t,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO stations`)).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version"}).AddRow(11, now, now, 1))
+				mock.ExpectCommit()
+			},
+			func(m data.Models) error {
+				return m.Station.Insert(&data.Station{OrgID: 1, Address: "Абая 12, Алматы", Capacity: 4}, nil)
+			},
+			"stations list")
+	})
+
+	t.Run("import", func(t *testing.T) {
+		testCacheInvalidation(t,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO stations`)).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version"}).AddRow(11, now, now, 1))
+				mock.ExpectCommit()
+			},
+			func(m data.Models) error {
+				return m.Station.Import([]*data.Station{{OrgID: 1, Address: "Абая 12, Алматы", Capacity: 4}}, nil)
+			},
+			"stations list")
+	})
+
+	// Станция может перейти в другую организацию, а списки повербанков
+	// организаций строятся через станции.
+	t.Run("update", func(t *testing.T) {
+		testCacheInvalidation(t,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectHighestSlot(mock, 10, 1)
+				mock.ExpectQuery(regexp.QuoteMeta(`UPDATE stations`)).
+					WillReturnRows(sqlmock.NewRows([]string{"updated_at", "version"}).AddRow(now, 2))
+				mock.ExpectCommit()
+			},
+			func(m data.Models) error {
+				return m.Station.Update(&data.Station{ID: 10, OrgID: 2, Capacity: 4, Version: 1}, nil)
+			},
+			"stations list", "powerbanks list", "station 10")
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		testCacheInvalidation(t,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE stations`)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(regexp.QuoteMeta(`UPDATE powerbanks`)).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+				mock.ExpectCommit()
+			},
+			func(m data.Models) error {
+				return m.Station.Delete(10, nil)
+			},
+			"stations list", "powerbanks list", "station 10", "powerbank 5")
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		testCacheInvalidation(t,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectRestoreStationLock(mock, 10, now, false)
+				mock.ExpectQuery(regexp.QuoteMeta(`UPDATE stations`)).
+					WillReturnRows(sqlmock.NewRows([]string{"org_id", "latitude", "longitude", "address", "capacity",
+						"created_at", "updated_at", "version"}).
+						AddRow(1, nil, nil, "Абая 10, Алматы", 4, now, now, 3))
+				mock.ExpectQuery(regexp.QuoteMeta(`UPDATE powerbanks`)).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+				mock.ExpectCommit()
+			},
+			func(m data.Models) error {
+				_, err := m.Station.Restore(10, nil)
+				return err
+			},
+			"stations list", "powerbanks list", "station 10", "powerbank 5")
+	})
+}
+
+// Любая запись в повербанки сбрасывает и список станций: в нём есть счётчики
+// доступности.
+func TestCache_PowerbankWrites(t *testing.T) {
+	now := time.Now()
+
+	t.Run("insert", func(t *testing.T) {
+		testCacheInvalidation(t,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectClaimSlot(mock, 10, 0, 4, 1)
+				expectInsertPowerbank(mock, 10, 2, data.PowerbankStatusAvailable).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version"}).AddRow(6, now, now, 1))
+				expectPowerbankEvent(mock)
+				mock.ExpectCommit()
+			},
+			func(m data.Models) error {
+				return m.Powerbank.Insert(&data.Powerbank{CurrentStationID: 10, Status: data.PowerbankStatusAvailable}, nil, nil)
+			},
+			"stations list", "powerbanks list")
+	})
+
+	t.Run("import", func(t *testing.T) {
+		testCacheInvalidation(t,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectClaimSlot(mock, 10, 0, 4, 1)
+				expectInsertPowerbank(mock, 10, 2, data.PowerbankStatusAvailable).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version"}).AddRow(6, now, now, 1))
+				expectPowerbankEvent(mock)
+				mock.ExpectCommit()
+			},
+			func(m data.Models) error {
+				return m.Powerbank.Import([]*data.Powerbank{{CurrentStationID: 10, Status: data.PowerbankStatusAvailable}}, nil, nil)
+			},
+			"stations list", "powerbanks list")
+	})
+
+	t.Run("update", func(t *testing.T) {
+		testCacheInvalidation(t,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectLockPowerbank(mock, 5, data.PowerbankStatusLost, 10)
+				mock.ExpectQuery(regexp.QuoteMeta(`UPDATE powerbanks`)).
+					WillReturnRows(sqlmock.NewRows([]string{"updated_at", "version"}).AddRow(now, 2))
+				mock.ExpectCommit()
+			},
+			func(m data.Models) error {
+				p := &data.Powerbank{ID: 5, CurrentStationID: 10, Status: data.PowerbankStatusLost, Version: 1}
+				return m.Powerbank.Update(p, data.PowerbankStatusLost, nil, nil)
+			},
+			"stations list", "powerbanks list", "powerbank 5")
+	})
+
+	t.Run("transfer", func(t *testing.T) {
+		testCacheInvalidation(t,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectLockPowerbanks(mock, []int{5}, lockedPowerbankRows().
+					AddRow(5, 10, 1, data.PowerbankStatusAvailable, now, now, 1))
+				expectClaimSlot(mock, 20, 5, 4)
+				mock.ExpectQuery(transferPowerbankQuery).
+					WillReturnRows(sqlmock.NewRows([]string{"updated_at", "version"}).AddRow(now, 2))
+				expectPowerbankEvent(mock)
+				mock.ExpectCommit()
+			},
+			func(m data.Models) error {
+				_, err := m.Powerbank.Transfer(10, 20, []int{5}, nil, nil)
+				return err
+			},
+			"stations list", "powerbanks list", "powerbank 5")
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		testCacheInvalidation(t,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE powerbanks`)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			func(m data.Models) error {
+				return m.Powerbank.Delete(5, nil)
+			},
+			"stations list", "powerbanks list", "powerbank 5")
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		testCacheInvalidation(t,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectRestorePowerbankLock(mock, 5, 10, nil, now, false)
+				expectClaimSlot(mock, 10, 5, 4)
+				mock.ExpectQuery(regexp.QuoteMeta(`UPDATE powerbanks`)).
+					WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at", "version"}).AddRow(now, now, 3))
+				mock.ExpectCommit()
+			},
+			func(m data.Models) error {
+				_, err := m.Powerbank.Restore(5, nil)
+				return err
+			},
+			"stations list", "powerbanks list", "powerbank 5")
+	})
+}
+
+// Аренды меняют статус и станцию повербанка, а значит и его кэш.
+func TestCache_RentalWrites(t *testing.T) {
+	now := time.Now()
+
+	t.Run("start", func(t *testing.T) {
+		testCacheInvalidation(t,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT status, current_station_id, slot_number`)).
+					WillReturnRows(sqlmock.NewRows([]string{"status", "current_station_id", "slot_number"}).
+						AddRow(data.PowerbankStatusAvailable, 10, 1))
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE powerbanks`)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				expectTariffForStation(mock, 10).WillReturnRows(tariffRows())
+				mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO rentals`)).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "started_at", "created_at", "updated_at"}).
+						AddRow(1, now, now, now))
+				expectPowerbankEvent(mock)
+				mock.ExpectCommit()
+			},
+			func(m data.Models) error {
+				return m.Rental.Start(&data.Rental{PowerbankID: 5, StartStationID: 10}, nil)
+			},
+			"stations list", "powerbanks list", "powerbank 5")
+	})
+
+	t.Run("finish", func(t *testing.T) {
+		testCacheInvalidation(t,
+			func(mock sqlmock.Sqlmock) {
+				expectLockedRentalWithTariff(mock, 1, data.RentalStatusActive, &data.Tariff{ID: 7, Currency: "KZT",
+					UnlockFee: 100, PeriodPrice: 200, PeriodMinutes: 60, FreeMinutes: 10})
+				expectRentedPowerbank(mock, 5, 10)
+				expectClaimSlot(mock, 20, 5, 4)
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE powerbanks`)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				expectPowerbankEvent(mock)
+				mock.ExpectQuery(regexp.QuoteMeta(`UPDATE rentals`)).
+					WillReturnRows(sqlmock.NewRows([]string{"end_station_id", "end_slot_number", "status", "ended_at", "updated_at"}).
+						AddRow(20, 1, data.RentalStatusFinished, now, now))
+				mock.ExpectCommit()
+			},
+			func(m data.Models) error {
+				rental := &data.Rental{ID: 1, PowerbankID: 5, StartStationID: 10, Status: data.RentalStatusActive,
+					StartedAt: now.Add(-time.Hour)}
+				return m.Rental.Finish(rental, 20, nil)
+			},
+			"stations list", "powerbanks list", "powerbank 5")
+	})
+
+	t.Run("mark lost", func(t *testing.T) {
+		testCacheInvalidation(t,
+			func(mock sqlmock.Sqlmock) {
+				expectLockedRentalWithTariff(mock, 1, data.RentalStatusOverdue, &data.Tariff{ID: 7, Currency: "KZT", LostPenalty: 15000})
+				expectRentedPowerbank(mock, 5, 10)
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE powerbanks`)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				expectPowerbankEvent(mock)
+				mock.ExpectQuery(regexp.QuoteMeta(`UPDATE rentals`)).
+					WillReturnRows(sqlmock.NewRows([]string{"status", "ended_at", "updated_at"}).
+						AddRow(data.RentalStatusLost, now, now))
+				mock.ExpectCommit()
+			},
+			func(m data.Models) error {
+				return m.Rental.MarkLost(&data.Rental{ID: 1, PowerbankID: 5, StartStationID: 10, Status: data.RentalStatusOverdue})
+			},
+			"stations list", "powerbanks list", "powerbank 5")
+	})
+}
+
+func TestCache_QRCodeRevoke(t *testing.T) {
+	t.Run("station", func(t *testing.T) {
+		testCacheInvalidation(t,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta(`UPDATE stations`)).
+					WillReturnRows(sqlmock.NewRows([]string{"qr_revision"}).AddRow(2))
+				mock.ExpectCommit()
+			},
+			func(m data.Models) error {
+				_, err := m.QRCode.Revoke(data.QRKindStation, 10, nil)
+				return err
+			},
+			"stations list", "station 10")
+	})
+
+	t.Run("powerbank", func(t *testing.T) {
+		testCacheInvalidation(t,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta(`UPDATE powerbanks`)).
+					WillReturnRows(sqlmock.NewRows([]string{"qr_revision"}).AddRow(2))
+				mock.ExpectCommit()
+			},
+			func(m data.Models) error {
+				_, err := m.QRCode.Revoke(data.QRKindPowerbank, 5, nil)
+				return err
+			},
+			"stations list", "powerbanks list", "powerbank 5")
+	})
+}
@@ -3,13 +3,16 @@ package data_test
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"regexp"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
 	"github.com/olzzhas/qrent/internal/data"
+	"github.com/olzzhas/qrent/pkg/validator"
 )
 
 func TestOrganizationModel_Insert_Success(t *testing.T) {
@@ -29,16 +32,18 @@ func TestOrganizationModel_Insert_Success(t *testing.T) {
 
 	now := time.Now()
 	// Ожидаем запрос INSERT с заданными аргументами и возвращаем результат
+	mock.ExpectBegin()
 	mock.ExpectQuery(regexp.QuoteMeta(`
         INSERT INTO organizations (name, location)
         VALUES ($1, $2)
-        RETURNING id, created_at, updated_at
+        RETURNING id, created_at, updated_at, version
     `)).
 		WithArgs(org.Name, org.Location).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).
-			AddRow(1, now, now))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version"}).
+			AddRow(1, now, now, 1))
+	mock.ExpectCommit()
 
-	err = model.Insert(org)
+	err = model.Insert(org, nil)
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
 	}
@@ -63,13 +68,13 @@ func TestOrganizationModel_Get_Success(t *testing.T) {
 	now := time.Now()
 
 	mock.ExpectQuery(regexp.QuoteMeta(`
-        SELECT id, name, location, created_at, updated_at
+        SELECT id, name, location, created_at, updated_at, version, deleted_at
         FROM organizations
-        WHERE id = $1
+        WHERE id = $1 AND (deleted_at IS NULL OR $2)
     `)).
-		WithArgs(1).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "location", "created_at", "updated_at"}).
-			AddRow(1, "Test Org", "Test Location", now, now))
+		WithArgs(1, false).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "location", "created_at", "updated_at", "version", "deleted_at"}).
+			AddRow(1, "Test Org", "Test Location", now, now, 1, nil))
 
 	org, err := model.Get(1)
 	if err != nil {
@@ -95,11 +100,11 @@ func TestOrganizationModel_Get_NotFound(t *testing.T) {
 	model := data.OrganizationModel{DB: db}
 
 	mock.ExpectQuery(regexp.QuoteMeta(`
-        SELECT id, name, location, created_at, updated_at
+        SELECT id, name, location, created_at, updated_at, version, deleted_at
         FROM organizations
-        WHERE id = $1
+        WHERE id = $1 AND (deleted_at IS NULL OR $2)
     `)).
-		WithArgs(999).
+		WithArgs(999, false).
 		WillReturnError(sql.ErrNoRows)
 
 	_, err = model.Get(999)
@@ -128,22 +133,25 @@ func TestOrganizationModel_Update_Success(t *testing.T) {
 
 	// Новое время обновления
 	newTime := time.Now()
+	mock.ExpectBegin()
 	mock.ExpectQuery(regexp.QuoteMeta(`
         UPDATE organizations
         SET name = $1,
             location = $2,
+            version = version + 1,
             updated_at = NOW()
-        WHERE id = $3
-        RETURNING updated_at
+        WHERE id = $3 AND version = $4 AND deleted_at IS NULL
+        RETURNING updated_at, version
     `)).
-		WithArgs("New Name", "New Location", org.ID).
-		WillReturnRows(sqlmock.NewRows([]string{"updated_at"}).AddRow(newTime))
+		WithArgs("New Name", "New Location", org.ID, org.Version).
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at", "version"}).AddRow(newTime, 2))
+	mock.ExpectCommit()
 
 	// Обновляем данные
 	org.Name = "New Name"
 	org.Location = "New Location"
 
-	err = model.Update(org)
+	err = model.Update(org, nil)
 	if err != nil {
 		t.Errorf("unexpected error in Update: %s", err)
 	}
@@ -151,13 +159,16 @@ func TestOrganizationModel_Update_Success(t *testing.T) {
 	if !org.UpdatedAt.Equal(newTime) {
 		t.Errorf("expected updated_at %v, got %v", newTime, org.UpdatedAt)
 	}
+	if org.Version != 2 {
+		t.Errorf("expected version 2, got %d", org.Version)
+	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("unfulfilled expectations: %s", err)
 	}
 }
 
-func TestOrganizationModel_Update_NotFound(t *testing.T) {
+func TestOrganizationModel_Update_EditConflict(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("unexpected error when opening a stub database connection: %s", err)
@@ -166,29 +177,30 @@ func TestOrganizationModel_Update_NotFound(t *testing.T) {
 
 	model := data.OrganizationModel{DB: db}
 	org := &data.Organization{
-		ID:       999,
+		ID:       1,
 		Name:     "Name",
 		Location: "Location",
+		Version:  3,
 	}
 
+	mock.ExpectBegin()
 	mock.ExpectQuery(regexp.QuoteMeta(`
         UPDATE organizations
         SET name = $1,
             location = $2,
+            version = version + 1,
             updated_at = NOW()
-        WHERE id = $3
-        RETURNING updated_at
+        WHERE id = $3 AND version = $4 AND deleted_at IS NULL
+        RETURNING updated_at, version
     `)).
-		WithArgs(org.Name, org.Location, org.ID).
+		WithArgs(org.Name, org.Location, org.ID, org.Version).
 		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
 
-	err = model.Update(org)
-	if err == nil {
-		t.Error("expected error for non-existent organization in Update, got nil")
-	}
-	expected := fmt.Sprintf("no organization found with id %d", org.ID)
-	if err.Error() != expected {
-		t.Errorf("expected error %q, got %q", expected, err.Error())
+	// Строка с версией 3 не нашлась: её уже изменил другой запрос.
+	err = model.Update(org, nil)
+	if !errors.Is(err, data.ErrEditConflict) {
+		t.Errorf("expected error %q, got %v", data.ErrEditConflict, err)
 	}
 }
 
@@ -202,14 +214,36 @@ func TestOrganizationModel_Delete_Success(t *testing.T) {
 	model := data.OrganizationModel{DB: db}
 	orgID := 1
 
+	mock.ExpectBegin()
 	mock.ExpectExec(regexp.QuoteMeta(`
-        DELETE FROM organizations
-        WHERE id = $1
+        UPDATE organizations
+        SET deleted_at = NOW(),
+            version = version + 1
+        WHERE id = $1 AND deleted_at IS NULL
     `)).
 		WithArgs(orgID).
 		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`
+        UPDATE stations
+        SET deleted_at = NOW(),
+            version = version + 1
+        WHERE org_id = $1 AND deleted_at IS NULL
+        RETURNING id
+    `)).
+		WithArgs(orgID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10).AddRow(11))
+	mock.ExpectQuery(regexp.QuoteMeta(`
+        UPDATE powerbanks
+        SET deleted_at = NOW(),
+            version = version + 1
+        WHERE current_station_id = ANY($1) AND deleted_at IS NULL AND status <> $2
+        RETURNING id
+    `)).
+		WithArgs(pq.Array([]int{10, 11}), data.PowerbankStatusRented).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(100))
+	mock.ExpectCommit()
 
-	err = model.Delete(orgID)
+	err = model.Delete(orgID, nil)
 	if err != nil {
 		t.Errorf("unexpected error in Delete: %s", err)
 	}
@@ -229,20 +263,106 @@ func TestOrganizationModel_Delete_NotFound(t *testing.T) {
 	model := data.OrganizationModel{DB: db}
 	orgID := 999
 
+	mock.ExpectBegin()
 	mock.ExpectExec(regexp.QuoteMeta(`
-        DELETE FROM organizations
-        WHERE id = $1
+        UPDATE organizations
+        SET deleted_at = NOW(),
+            version = version + 1
+        WHERE id = $1 AND deleted_at IS NULL
     `)).
 		WithArgs(orgID).
 		WillReturnResult(sqlmock.NewResult(0, 0)) // 0 строк затронуто
+	mock.ExpectRollback()
 
-	err = model.Delete(orgID)
-	if err == nil {
-		t.Error("expected error for non-existent organization in Delete, got nil")
+	err = model.Delete(orgID, nil)
+	if !errors.Is(err, data.ErrRecordNotFound) {
+		t.Errorf("expected error %q, got %v", data.ErrRecordNotFound, err)
 	}
-	expected := fmt.Sprintf("no organization found with id %d", orgID)
-	if err.Error() != expected {
-		t.Errorf("expected error %q, got %q", expected, err.Error())
+}
+
+func TestOrganizationModel_Restore_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	model := data.OrganizationModel{DB: db}
+	deletedAt := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`
+        SELECT deleted_at
+        FROM organizations
+        WHERE id = $1
+        FOR UPDATE
+    `)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"deleted_at"}).AddRow(deletedAt))
+	mock.ExpectQuery(regexp.QuoteMeta(`
+        UPDATE organizations
+        SET deleted_at = NULL,
+            version = version + 1,
+            updated_at = NOW()
+        WHERE id = $1
+        RETURNING name, location, created_at, updated_at, version
+    `)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "location", "created_at", "updated_at", "version"}).
+			AddRow("Org", "Almaty", deletedAt, deletedAt, 3))
+	// Станция 11 удалена раньше организации и не попадает в выборку.
+	mock.ExpectQuery(regexp.QuoteMeta(`
+        UPDATE stations
+        SET deleted_at = NULL,
+            version = version + 1
+        WHERE org_id = $1 AND deleted_at = $2
+        RETURNING id
+    `)).
+		WithArgs(1, deletedAt).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+	mock.ExpectQuery(regexp.QuoteMeta(`
+        UPDATE powerbanks
+        SET deleted_at = NULL,
+            version = version + 1
+        WHERE current_station_id = ANY($1) AND deleted_at = $2
+        RETURNING id
+    `)).
+		WithArgs(pq.Array([]int{10}), deletedAt).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(100))
+	mock.ExpectCommit()
+
+	org, err := model.Restore(1, nil)
+	if err != nil {
+		t.Fatalf("unexpected error in Restore: %s", err)
+	}
+	if org.Name != "Org" || org.Version != 3 || org.DeletedAt != nil {
+		t.Errorf("unexpected restored organization: %+v", org)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %s", err)
+	}
+}
+
+func TestOrganizationModel_Restore_NotDeleted(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	model := data.OrganizationModel{DB: db}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT deleted_at`)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"deleted_at"}).AddRow(nil))
+	mock.ExpectRollback()
+
+	if _, err := model.Restore(1, nil); !errors.Is(err, data.ErrNotDeleted) {
+		t.Errorf("expected error %q, got %v", data.ErrNotDeleted, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %s", err)
 	}
 }
 
@@ -256,23 +376,97 @@ func TestOrganizationModel_List_Success(t *testing.T) {
 	model := data.OrganizationModel{DB: db}
 	now := time.Now()
 
+	filters := data.Filters{
+		Page:         1,
+		PageSize:     20,
+		Sort:         "name",
+		SortSafelist: []string{"id", "name", "-id", "-name"},
+	}
+
 	mock.ExpectQuery(regexp.QuoteMeta(`
-        SELECT id, name, location, created_at, updated_at
+        SELECT count(*) OVER(), id, name, location, created_at, updated_at, version, deleted_at
         FROM organizations
-        ORDER BY name
+        WHERE (to_tsvector('simple', name) @@ plainto_tsquery('simple', $1) OR $1 = '')
+        AND (deleted_at IS NULL OR $2)
+        ORDER BY name ASC, id ASC
+        LIMIT $3 OFFSET $4
     `)).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "location", "created_at", "updated_at"}).
-			AddRow(1, "Org1", "Location1", now, now).
-			AddRow(2, "Org2", "Location2", now, now))
+		WithArgs("", false, 20, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "id", "name", "location", "created_at", "updated_at", "version", "deleted_at"}).
+			AddRow(2, 1, "Org1", "Location1", now, now, 1, nil).
+			AddRow(2, 2, "Org2", "Location2", now, now, 1, nil))
 
-	orgs, err := model.List()
+	orgs, metadata, err := model.List("", false, filters)
 	if err != nil {
 		t.Errorf("unexpected error in List: %s", err)
 	}
 	if len(orgs) != 2 {
 		t.Errorf("expected 2 organizations, got %d", len(orgs))
 	}
+	if metadata.TotalRecords != 2 || metadata.LastPage != 1 {
+		t.Errorf("unexpected metadata: %+v", metadata)
+	}
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("unfulfilled expectations: %s", err)
 	}
 }
+
+func TestOrganizationModel_List_SearchAndPagination(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	model := data.OrganizationModel{DB: db}
+	now := time.Now()
+
+	filters := data.Filters{
+		Page:         2,
+		PageSize:     10,
+		Sort:         "-created_at",
+		SortSafelist: []string{"created_at", "-created_at"},
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`ORDER BY created_at DESC, id ASC`)).
+		WithArgs("charge", true, 10, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "id", "name", "location", "created_at", "updated_at", "version", "deleted_at"}).
+			AddRow(25, 11, "Charge Hub", "Almaty", now, now, 1, now))
+
+	orgs, metadata, err := model.List("charge", true, filters)
+	if err != nil {
+		t.Fatalf("unexpected error in List: %s", err)
+	}
+	if len(orgs) != 1 || orgs[0].DeletedAt == nil {
+		t.Errorf("expected 1 deleted organization, got %+v", orgs)
+	}
+
+	expected := data.Metadata{CurrentPage: 2, PageSize: 10, FirstPage: 1, LastPage: 3, TotalRecords: 25}
+	if metadata != expected {
+		t.Errorf("expected metadata %+v, got %+v", expected, metadata)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %s", err)
+	}
+}
+
+func TestValidateFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters data.Filters
+		valid   bool
+	}{
+		{"valid", data.Filters{Page: 1, PageSize: 20, Sort: "id", SortSafelist: []string{"id"}}, true},
+		{"zero page", data.Filters{Page: 0, PageSize: 20, Sort: "id", SortSafelist: []string{"id"}}, false},
+		{"page size too big", data.Filters{Page: 1, PageSize: 101, Sort: "id", SortSafelist: []string{"id"}}, false},
+		{"unknown sort", data.Filters{Page: 1, PageSize: 20, Sort: "password", SortSafelist: []string{"id"}}, false},
+	}
+
+	for _, tt := range tests {
+		v := validator.New()
+		data.ValidateFilters(v, tt.filters)
+		if v.Valid() != tt.valid {
+			t.Errorf("%s: expected valid=%v, got errors %v", tt.name, tt.valid, v.Errors)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package data_test
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/olzzhas/qrent/internal/data"
+)
+
+func TestRentalModel_MarkOverdue(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.RentalModel{DB: db}
+
+	mock.ExpectExec(regexp.QuoteMeta(`
+		UPDATE rentals
+		SET status = $1,
+			updated_at = NOW()
+		WHERE status = $2 AND started_at < NOW() - make_interval(secs => $3)
+	`)).
+		WithArgs(data.RentalStatusOverdue, data.RentalStatusActive, float64(86400)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	n, err := model.MarkOverdue(24 * time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error in MarkOverdue: %s", err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 rentals marked overdue, got %d", n)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func expectLockedRental(mock sqlmock.Sqlmock, id int, status data.RentalStatus) {
+	expectLockedRentalWithTariff(mock, id, status, nil)
+}
+
+// expectLockedRentalWithTariff ожидает блокировку аренды, которой при выдаче
+// достался тариф tariff (nil — без тарифа).
+func expectLockedRentalWithTariff(mock sqlmock.Sqlmock, id int, status data.RentalStatus, tariff *data.Tariff) {
+	var snapshot any
+	if tariff != nil {
+		raw, err := json.Marshal(tariff)
+		if err != nil {
+			panic(err)
+		}
+		snapshot = raw
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT status, tariff_snapshot
+		FROM rentals
+		WHERE id = $1
+		FOR UPDATE
+	`)).
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "tariff_snapshot"}).AddRow(status, snapshot))
+}
+
+func TestRentalModel_MarkLost_AppliesPenalty(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.RentalModel{DB: db}
+	rental := &data.Rental{ID: 1, PowerbankID: 5, StartStationID: 10, Status: data.RentalStatusOverdue}
+	now := time.Now()
+
+	expectLockedRentalWithTariff(mock, 1, data.RentalStatusOverdue, &data.Tariff{ID: 7, Currency: "KZT", LostPenalty: 15000})
+	expectRentedPowerbank(mock, 5, 10)
+	mock.ExpectExec(regexp.QuoteMeta(`
+		UPDATE powerbanks
+		SET status = $1,
+			slot_number = NULL,
+			version = version + 1,
+			updated_at = NOW()
+		WHERE id = $2
+	`)).
+		WithArgs(data.PowerbankStatusLost, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectPowerbankEvent(mock).
+		WithArgs(5, data.PowerbankStatusRented, data.PowerbankStatusLost, 10, 10, nil, 1)
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		UPDATE rentals
+		SET status = $1,
+			ended_at = NOW(),
+			tariff_id = $2,
+			price = $3,
+			currency = $4,
+			updated_at = NOW()
+		WHERE id = $5
+		RETURNING status, ended_at, updated_at
+	`)).
+		WithArgs(data.RentalStatusLost, 7, int64(15000), "KZT", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "ended_at", "updated_at"}).
+			AddRow(data.RentalStatusLost, now, now))
+	mock.ExpectCommit()
+
+	if err := model.MarkLost(rental); err != nil {
+		t.Fatalf("unexpected error in MarkLost: %s", err)
+	}
+	if rental.Status != data.RentalStatusLost || rental.Price == nil || *rental.Price != 15000 {
+		t.Errorf("unexpected rental data: %+v", rental)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRentalModel_MarkLost_ReturnedMeanwhile(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.RentalModel{DB: db}
+
+	expectLockedRental(mock, 1, data.RentalStatusFinished)
+	mock.ExpectRollback()
+
+	err = model.MarkLost(&data.Rental{ID: 1, PowerbankID: 5})
+	if !errors.Is(err, data.ErrRentalNotOverdue) {
+		t.Errorf("expected error %q, got %v", data.ErrRentalNotOverdue, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRentalModel_Finish_Overdue(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.RentalModel{DB: db}
+	now := time.Now()
+	rental := &data.Rental{ID: 1, PowerbankID: 5, StartStationID: 10, StartedAt: now.Add(-30 * time.Hour)}
+
+	expectLockedRental(mock, 1, data.RentalStatusOverdue)
+	expectRentedPowerbank(mock, 5, 10)
+	expectClaimSlot(mock, 20, 5, 4)
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE powerbanks`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectPowerbankEvent(mock)
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE rentals`)).
+		WillReturnRows(sqlmock.NewRows([]string{"end_station_id", "end_slot_number", "status", "ended_at", "updated_at"}).
+			AddRow(20, 1, data.RentalStatusFinished, now, now))
+	mock.ExpectCommit()
+
+	if err := model.Finish(rental, 20, nil); err != nil {
+		t.Fatalf("expected an overdue rental to be returnable, got %s", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRentalModel_Unsettled(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.RentalModel{DB: db}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT id
+		FROM rentals
+		WHERE settled_at IS NULL AND status IN ($1, $2)
+		AND ended_at < NOW() - make_interval(secs => $3)
+		ORDER BY ended_at ASC
+		LIMIT 100
+	`)).
+		WithArgs(data.RentalStatusFinished, data.RentalStatusLost, float64(300)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4).AddRow(9))
+
+	ids, err := model.Unsettled(5 * time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error in Unsettled: %s", err)
+	}
+	if len(ids) != 2 || ids[0] != 4 || ids[1] != 9 {
+		t.Errorf("expected unsettled rentals [4 9], got %v", ids)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRentalModel_MarkSettled(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.RentalModel{DB: db}
+
+	mock.ExpectExec(regexp.QuoteMeta(`
+		UPDATE rentals
+		SET settled_at = NOW()
+		WHERE id = $1 AND settled_at IS NULL
+	`)).
+		WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := model.MarkSettled(4); err != nil {
+		t.Fatalf("unexpected error in MarkSettled: %s", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,201 @@
+package data_test
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/olzzhas/qrent/internal/data"
+)
+
+const insertPaymentQuery = `
+		INSERT INTO payments (rental_id, authorization_id, kind, status, amount, currency, provider, idempotency_key)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (idempotency_key) DO NOTHING
+		RETURNING id, status, created_at, updated_at
+	`
+
+func TestPaymentModel_Begin_New(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.PaymentModel{DB: db}
+	rentalID := 1
+	authID := int64(9)
+	p := &data.Payment{RentalID: &rentalID, AuthorizationID: &authID, Kind: data.PaymentKindCapture, Amount: 500,
+		Currency: "KZT", Provider: "fake", IdempotencyKey: "rental:1:capture:0"}
+	now := time.Now()
+
+	mock.ExpectQuery(regexp.QuoteMeta(insertPaymentQuery)).
+		WithArgs(&rentalID, &authID, data.PaymentKindCapture, data.PaymentStatusPending, int64(500), "KZT", "fake", "rental:1:capture:0").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "created_at", "updated_at"}).
+			AddRow(10, data.PaymentStatusPending, now, now))
+
+	created, err := model.Begin(p)
+	if err != nil {
+		t.Fatalf("unexpected error in Begin: %s", err)
+	}
+	if !created || p.ID != 10 || p.Status != data.PaymentStatusPending {
+		t.Errorf("unexpected payment: created=%v %+v", created, p)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPaymentModel_Begin_Retry(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.PaymentModel{DB: db}
+	rentalID := 1
+	p := &data.Payment{RentalID: &rentalID, Kind: data.PaymentKindCapture, Amount: 500, Currency: "KZT",
+		Provider: "fake", IdempotencyKey: "rental:1:capture:0"}
+	now := time.Now()
+	ref := "fake_cap_2"
+
+	mock.ExpectQuery(regexp.QuoteMeta(insertPaymentQuery)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "created_at", "updated_at"}))
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		FROM payments
+		WHERE idempotency_key = $1
+	`)).
+		WithArgs("rental:1:capture:0").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "rental_id", "authorization_id", "kind", "status", "amount", "currency",
+			"provider", "provider_ref", "idempotency_key", "error", "created_at", "updated_at"}).
+			AddRow(10, 1, 9, data.PaymentKindCapture, data.PaymentStatusSucceeded, 500, "KZT", "fake",
+				ref, "rental:1:capture:0", nil, now, now))
+
+	created, err := model.Begin(p)
+	if err != nil {
+		t.Fatalf("unexpected error in Begin: %s", err)
+	}
+	if created || p.Status != data.PaymentStatusSucceeded || p.ProviderRef == nil || *p.ProviderRef != ref {
+		t.Errorf("expected the stored attempt, got created=%v %+v", created, p)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPaymentModel_LatestSucceeded_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.PaymentModel{DB: db}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		FROM payments
+		WHERE rental_id = $1 AND kind = $2 AND status = $3
+	`)).
+		WithArgs(1, data.PaymentKindAuthorize, data.PaymentStatusSucceeded).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	_, err = model.LatestSucceeded(1, data.PaymentKindAuthorize)
+	if !errors.Is(err, data.ErrRecordNotFound) {
+		t.Errorf("expected error %q, got %v", data.ErrRecordNotFound, err)
+	}
+}
+
+func TestPaymentModel_FirstSucceeded_OrdersByIDAscending(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.PaymentModel{DB: db}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		WHERE rental_id = $1 AND kind = $2 AND status = $3
+		ORDER BY id ASC
+	`)).
+		WithArgs(1, data.PaymentKindAuthorize, data.PaymentStatusSucceeded).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	_, err = model.FirstSucceeded(1, data.PaymentKindAuthorize)
+	if !errors.Is(err, data.ErrRecordNotFound) {
+		t.Errorf("expected error %q, got %v", data.ErrRecordNotFound, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPaymentModel_CountFailed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.PaymentModel{DB: db}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		FROM payments
+		WHERE starts_with(idempotency_key, $1) AND status = $2
+	`)).
+		WithArgs("rental:1:capture:", data.PaymentStatusFailed).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	count, err := model.CountFailed("rental:1:capture:")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 failed attempts, got %d", count)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// Остатки считаются по каждой заморозке, а возвраты самого запроса не
+// вычитаются.
+func TestPaymentModel_Refundable(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.PaymentModel{DB: db}
+	prefix := "rental:1:refund:3:key:"
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		FROM payments a
+		INNER JOIN payments o ON o.authorization_id = a.id AND o.status = $2
+		WHERE a.rental_id = $1 AND a.kind = $6 AND a.status = $2
+	`)).
+		WithArgs(1, data.PaymentStatusSucceeded, data.PaymentKindCapture, data.PaymentKindRefund, prefix, data.PaymentKindAuthorize).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "provider_ref", "currency", "refundable"}).
+			AddRow(10, "fake_auth_1", "KZT", 5000).
+			AddRow(12, "fake_auth_3", "KZT", 2000))
+
+	captures, err := model.Refundable(1, prefix)
+	if err != nil {
+		t.Fatalf("unexpected error in Refundable: %s", err)
+	}
+	if len(captures) != 2 {
+		t.Fatalf("expected 2 captures, got %d", len(captures))
+	}
+	if captures[0].AuthorizationID != 10 || captures[1].Amount != 2000 || captures[1].ProviderRef != "fake_auth_3" {
+		t.Errorf("unexpected refundable captures: %+v %+v", captures[0], captures[1])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,88 @@
+package data_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/olzzhas/qrent/internal/data"
+)
+
+func TestRole_Permissions(t *testing.T) {
+	tests := []struct {
+		role     data.Role
+		code     string
+		expected bool
+	}{
+		{data.RoleAdmin, data.PermissionOrganizationsWrite, true},
+		{data.RoleAdmin, data.PermissionUsersWrite, true},
+		{data.RoleAdmin, data.PermissionPaymentsRefund, true},
+		{data.RoleOperator, data.PermissionPaymentsRefund, false},
+		{data.RoleOperator, data.PermissionStationsWrite, true},
+		{data.RoleOperator, data.PermissionOrganizationsWrite, false},
+		{data.RoleRenter, data.PermissionRentalsCreate, true},
+		{data.RoleRenter, data.PermissionPowerbanksWrite, false},
+		{"unknown", data.PermissionRentalsCreate, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.role.Permissions().Include(tt.code); got != tt.expected {
+			t.Errorf("Role(%q).Permissions().Include(%q) = %v, want %v", tt.role, tt.code, got, tt.expected)
+		}
+	}
+}
+
+func TestUser_CanManageOrganization(t *testing.T) {
+	orgID := 10
+
+	tests := []struct {
+		name     string
+		user     *data.User
+		orgID    int
+		expected bool
+	}{
+		{"admin any organization", &data.User{Role: data.RoleAdmin}, 42, true},
+		{"operator own organization", &data.User{Role: data.RoleOperator, OrgID: &orgID}, 10, true},
+		{"operator foreign organization", &data.User{Role: data.RoleOperator, OrgID: &orgID}, 11, false},
+		{"operator without organization", &data.User{Role: data.RoleOperator}, 10, false},
+		{"renter", &data.User{Role: data.RoleRenter, OrgID: &orgID}, 10, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.user.CanManageOrganization(tt.orgID); got != tt.expected {
+			t.Errorf("%s: CanManageOrganization(%d) = %v, want %v", tt.name, tt.orgID, got, tt.expected)
+		}
+	}
+}
+
+func TestPermissionModel_GetAllForUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.PermissionModel{DB: db}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT permissions.code
+		FROM permissions
+		INNER JOIN users_permissions ON users_permissions.permission_id = permissions.id
+		WHERE users_permissions.user_id = $1
+	`)).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"code"}).
+			AddRow(data.PermissionStationsWrite).
+			AddRow(data.PermissionPowerbanksWrite))
+
+	permissions, err := model.GetAllForUser(3)
+	if err != nil {
+		t.Fatalf("unexpected error in GetAllForUser: %s", err)
+	}
+	if !permissions.Include(data.PermissionStationsWrite) || permissions.Include(data.PermissionUsersWrite) {
+		t.Errorf("unexpected permissions: %v", permissions)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
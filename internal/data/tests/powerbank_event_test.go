@@ -0,0 +1,67 @@
+package data_test
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/olzzhas/qrent/internal/data"
+)
+
+func expectPowerbankEvent(mock sqlmock.Sqlmock) *sqlmock.ExpectedQuery {
+	return mock.ExpectQuery(regexp.QuoteMeta(`
+		INSERT INTO powerbank_events (powerbank_id, old_status, new_status, from_station_id,
+			to_station_id, actor_id, rental_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+}
+
+func expectRentedPowerbank(mock sqlmock.Sqlmock, id, stationID int) {
+	expectLockPowerbank(mock, id, data.PowerbankStatusRented, stationID)
+}
+
+func TestPowerbankModel_History(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.PowerbankModel{DB: db}
+	now := time.Now()
+	filters := data.Filters{Page: 1, PageSize: 20, Sort: "-id", SortSafelist: []string{"id", "-id"}}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT count(*) OVER(), id, powerbank_id, old_status, new_status, from_station_id,
+			to_station_id, actor_id, rental_id, created_at
+		FROM powerbank_events
+		WHERE powerbank_id = $1
+		ORDER BY id DESC
+		LIMIT $2 OFFSET $3
+	`)).
+		WithArgs(5, 20, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "id", "powerbank_id", "old_status", "new_status",
+			"from_station_id", "to_station_id", "actor_id", "rental_id", "created_at"}).
+			AddRow(2, 2, 5, data.PowerbankStatusAvailable, data.PowerbankStatusRented, 10, 10, 3, 7, now).
+			AddRow(2, 1, 5, nil, data.PowerbankStatusAvailable, nil, 10, 1, nil, now))
+
+	events, metadata, err := model.History(5, filters)
+	if err != nil {
+		t.Fatalf("unexpected error in History: %s", err)
+	}
+	if len(events) != 2 || metadata.TotalRecords != 2 {
+		t.Fatalf("expected 2 events, got %d (total %d)", len(events), metadata.TotalRecords)
+	}
+	if events[0].RentalID == nil || *events[0].RentalID != 7 {
+		t.Errorf("expected rental 7 on the rent event, got %v", events[0].RentalID)
+	}
+	if events[1].OldStatus != nil {
+		t.Errorf("expected empty old_status on the creation event, got %v", *events[1].OldStatus)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
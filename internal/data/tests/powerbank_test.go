@@ -3,7 +3,6 @@ package data_test
 import (
 	"database/sql"
 	"errors"
-	"fmt"
 	"regexp"
 	"testing"
 	"time"
@@ -11,6 +10,7 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/lib/pq"
 	"github.com/olzzhas/qrent/internal/data"
+	"github.com/olzzhas/qrent/pkg/validator"
 )
 
 func TestPowerbankStatus_IsValid(t *testing.T) {
@@ -21,6 +21,8 @@ func TestPowerbankStatus_IsValid(t *testing.T) {
 		{data.PowerbankStatusRented, true},
 		{data.PowerbankStatusAvailable, true},
 		{data.PowerbankStatusCharging, true},
+		{data.PowerbankStatusLost, true},
+		{data.PowerbankStatusMaintenance, true},
 		{"unknown", false},
 	}
 
@@ -47,21 +49,30 @@ func TestPowerbankModel_Insert_Success(t *testing.T) {
 
 	now := time.Now()
 
+	mock.ExpectBegin()
+	expectClaimSlot(mock, 10, 0, 4, 1)
 	mock.ExpectQuery(regexp.QuoteMeta(`
-		INSERT INTO powerbanks (current_station_id, status)
-		VALUES ($1, $2)
-		RETURNING id, created_at, updated_at
+		INSERT INTO powerbanks (current_station_id, slot_number, status)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, updated_at, version
 	`)).
-		WithArgs(p.CurrentStationID, p.Status).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).
-			AddRow(5, now, now))
+		WithArgs(p.CurrentStationID, 2, p.Status).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version"}).
+			AddRow(5, now, now, 1))
+	expectPowerbankEvent(mock).
+		WithArgs(5, nil, data.PowerbankStatusAvailable, nil, 10, 3, nil)
+	mock.ExpectCommit()
 
-	if err = model.Insert(p); err != nil {
+	actorID := 3
+	if err = model.Insert(p, &actorID, nil); err != nil {
 		t.Errorf("unexpected error in Insert: %s", err)
 	}
 	if p.ID != 5 {
 		t.Errorf("expected powerbank ID=5, got %d", p.ID)
 	}
+	if p.SlotNumber == nil || *p.SlotNumber != 2 {
+		t.Errorf("expected first free slot 2, got %v", p.SlotNumber)
+	}
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
@@ -77,15 +88,17 @@ func TestPowerbankModel_Insert_InvalidForeignKey(t *testing.T) {
 	model := data.PowerbankModel{DB: db}
 	p := &data.Powerbank{CurrentStationID: 99999, Status: data.PowerbankStatusRented}
 
+	mock.ExpectBegin()
 	mock.ExpectQuery(regexp.QuoteMeta(`
-		INSERT INTO powerbanks (current_station_id, status)
-		VALUES ($1, $2)
-		RETURNING id, created_at, updated_at
+		INSERT INTO powerbanks (current_station_id, slot_number, status)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, updated_at, version
 	`)).
-		WithArgs(p.CurrentStationID, p.Status).
+		WithArgs(p.CurrentStationID, nil, p.Status).
 		WillReturnError(&pq.Error{Code: "23503"})
+	mock.ExpectRollback()
 
-	err = model.Insert(p)
+	err = model.Insert(p, nil, nil)
 	if err == nil {
 		t.Errorf("expected error due to invalid foreign key, got nil")
 	}
@@ -106,13 +119,13 @@ func TestPowerbankModel_Get_Success(t *testing.T) {
 	now := time.Now()
 
 	mock.ExpectQuery(regexp.QuoteMeta(`
-		SELECT id, current_station_id, status, created_at, updated_at
+		SELECT id, current_station_id, slot_number, status, created_at, updated_at, version, deleted_at
 		FROM powerbanks
-		WHERE id = $1
+		WHERE id = $1 AND (deleted_at IS NULL OR $2)
 	`)).
-		WithArgs(5).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "current_station_id", "status", "created_at", "updated_at"}).
-			AddRow(5, 10, data.PowerbankStatusCharging, now, now))
+		WithArgs(5, false).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "current_station_id", "slot_number", "status", "created_at", "updated_at", "version", "deleted_at"}).
+			AddRow(5, 10, 1, data.PowerbankStatusCharging, now, now, 1, nil))
 
 	p, err := model.Get(5)
 	if err != nil {
@@ -136,20 +149,16 @@ func TestPowerbankModel_Get_NotFound(t *testing.T) {
 	model := data.PowerbankModel{DB: db}
 
 	mock.ExpectQuery(regexp.QuoteMeta(`
-		SELECT id, current_station_id, status, created_at, updated_at
+		SELECT id, current_station_id, slot_number, status, created_at, updated_at, version, deleted_at
 		FROM powerbanks
-		WHERE id = $1
+		WHERE id = $1 AND (deleted_at IS NULL OR $2)
 	`)).
-		WithArgs(999).
+		WithArgs(999, false).
 		WillReturnError(sql.ErrNoRows)
 
 	_, err = model.Get(999)
-	if err == nil {
-		t.Error("expected error for non-existent powerbank in Get, got nil")
-	}
-	expected := fmt.Sprintf("powerbank не найден: id %d", 999)
-	if err.Error() != expected {
-		t.Errorf("expected error %q, got %q", expected, err.Error())
+	if !errors.Is(err, data.ErrRecordNotFound) {
+		t.Errorf("expected error %q, got %v", data.ErrRecordNotFound, err)
 	}
 }
 
@@ -164,22 +173,27 @@ func TestPowerbankModel_Update_Success(t *testing.T) {
 	p := &data.Powerbank{
 		ID:               5,
 		CurrentStationID: 20,
-		Status:           data.PowerbankStatusRented,
+		Status:           data.PowerbankStatusLost,
 	}
 
 	newTime := time.Now()
+	mock.ExpectBegin()
+	expectLockPowerbank(mock, p.ID, data.PowerbankStatusLost, 20)
 	mock.ExpectQuery(regexp.QuoteMeta(`
 		UPDATE powerbanks
 		SET current_station_id = $1,
-			status = $2,
+			slot_number = $2,
+			status = $3,
+			version = version + 1,
 			updated_at = NOW()
-		WHERE id = $3
-		RETURNING updated_at
+		WHERE id = $4 AND version = $5
+		RETURNING updated_at, version
 	`)).
-		WithArgs(p.CurrentStationID, p.Status, p.ID).
-		WillReturnRows(sqlmock.NewRows([]string{"updated_at"}).AddRow(newTime))
+		WithArgs(p.CurrentStationID, nil, p.Status, p.ID, p.Version).
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at", "version"}).AddRow(newTime, 2))
+	mock.ExpectCommit()
 
-	err = model.Update(p)
+	err = model.Update(p, data.PowerbankStatusLost, nil, nil)
 	if err != nil {
 		t.Errorf("unexpected error in Update: %s", err)
 	}
@@ -191,6 +205,38 @@ func TestPowerbankModel_Update_Success(t *testing.T) {
 	}
 }
 
+func TestPowerbankModel_Update_RecordsEvent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.PowerbankModel{DB: db}
+	p := &data.Powerbank{
+		ID:               5,
+		CurrentStationID: 20,
+		Status:           data.PowerbankStatusLost,
+	}
+	actorID := 3
+
+	mock.ExpectBegin()
+	expectLockPowerbank(mock, p.ID, data.PowerbankStatusLost, 10)
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE powerbanks`)).
+		WithArgs(p.CurrentStationID, nil, p.Status, p.ID, p.Version).
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at", "version"}).AddRow(time.Now(), 2))
+	expectPowerbankEvent(mock).
+		WithArgs(5, data.PowerbankStatusLost, data.PowerbankStatusLost, 10, 20, actorID, nil)
+	mock.ExpectCommit()
+
+	if err := model.Update(p, data.PowerbankStatusLost, &actorID, nil); err != nil {
+		t.Errorf("unexpected error in Update: %s", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
 func TestPowerbankModel_Update_NotFound(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -205,24 +251,51 @@ func TestPowerbankModel_Update_NotFound(t *testing.T) {
 		Status:           data.PowerbankStatusAvailable,
 	}
 
+	mock.ExpectBegin()
 	mock.ExpectQuery(regexp.QuoteMeta(`
-		UPDATE powerbanks
-		SET current_station_id = $1,
-			status = $2,
-			updated_at = NOW()
-		WHERE id = $3
-		RETURNING updated_at
+		SELECT status, current_station_id
+		FROM powerbanks
+		WHERE id = $1
+		FOR UPDATE
 	`)).
-		WithArgs(p.CurrentStationID, p.Status, p.ID).
+		WithArgs(p.ID).
 		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
 
-	err = model.Update(p)
-	if err == nil {
-		t.Error("expected error for non-existent powerbank in Update, got nil")
+	err = model.Update(p, data.PowerbankStatusAvailable, nil, nil)
+	if !errors.Is(err, data.ErrRecordNotFound) {
+		t.Errorf("expected error %q, got %v", data.ErrRecordNotFound, err)
 	}
-	expected := fmt.Sprintf("powerbank with id %d not found", p.ID)
-	if err.Error() != expected {
-		t.Errorf("expected error %q, got %q", expected, err.Error())
+}
+
+func TestPowerbankModel_Update_EditConflict(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.PowerbankModel{DB: db}
+	p := &data.Powerbank{
+		ID:               5,
+		CurrentStationID: 20,
+		Status:           data.PowerbankStatusLost,
+		Version:          3,
+	}
+
+	mock.ExpectBegin()
+	expectLockPowerbank(mock, p.ID, data.PowerbankStatusLost, 20)
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE powerbanks`)).
+		WithArgs(p.CurrentStationID, nil, p.Status, p.ID, 3).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	err = model.Update(p, data.PowerbankStatusLost, nil, nil)
+	if !errors.Is(err, data.ErrEditConflict) {
+		t.Errorf("expected error %q, got %v", data.ErrEditConflict, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
 }
 
@@ -237,21 +310,25 @@ func TestPowerbankModel_Update_InvalidForeignKey(t *testing.T) {
 	p := &data.Powerbank{
 		ID:               5,
 		CurrentStationID: 99999, // некорректный FK
-		Status:           data.PowerbankStatusRented,
+		Status:           data.PowerbankStatusLost,
 	}
 
+	mock.ExpectBegin()
+	expectLockPowerbank(mock, p.ID, data.PowerbankStatusLost, 20)
 	mock.ExpectQuery(regexp.QuoteMeta(`
 		UPDATE powerbanks
 		SET current_station_id = $1,
-			status = $2,
+			slot_number = $2,
+			status = $3,
+			version = version + 1,
 			updated_at = NOW()
-		WHERE id = $3
-		RETURNING updated_at
+		WHERE id = $4 AND version = $5
+		RETURNING updated_at, version
 	`)).
-		WithArgs(p.CurrentStationID, p.Status, p.ID).
+		WithArgs(p.CurrentStationID, nil, p.Status, p.ID, p.Version).
 		WillReturnError(&pq.Error{Code: "23503"})
 
-	err = model.Update(p)
+	err = model.Update(p, data.PowerbankStatusLost, nil, nil)
 	if err == nil {
 		t.Error("expected error due to invalid foreign key in Update, got nil")
 	}
@@ -261,6 +338,169 @@ func TestPowerbankModel_Update_InvalidForeignKey(t *testing.T) {
 	}
 }
 
+func expectLockPowerbank(mock sqlmock.Sqlmock, id int, status data.PowerbankStatus, stationID int) {
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT status, current_station_id
+		FROM powerbanks
+		WHERE id = $1
+		FOR UPDATE
+	`)).
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "current_station_id"}).AddRow(status, stationID))
+}
+
+func TestValidateNewPowerbank(t *testing.T) {
+	tests := []struct {
+		name  string
+		p     data.Powerbank
+		valid bool
+	}{
+		{"available", data.Powerbank{CurrentStationID: 10, Status: data.PowerbankStatusAvailable}, true},
+		{"charging", data.Powerbank{CurrentStationID: 10, Status: data.PowerbankStatusCharging}, true},
+		{"maintenance", data.Powerbank{CurrentStationID: 10, Status: data.PowerbankStatusMaintenance}, true},
+		{"rented", data.Powerbank{CurrentStationID: 10, Status: data.PowerbankStatusRented}, false},
+		{"lost", data.Powerbank{CurrentStationID: 10, Status: data.PowerbankStatusLost}, false},
+		{"unknown status", data.Powerbank{CurrentStationID: 10, Status: "broken"}, false},
+	}
+
+	for _, tt := range tests {
+		v := validator.New()
+		data.ValidateNewPowerbank(v, &tt.p)
+		if v.Valid() != tt.valid {
+			t.Errorf("%s: expected valid=%v, got errors %v", tt.name, tt.valid, v.Errors)
+		}
+	}
+}
+
+func TestPowerbankStatus_CanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from, to data.PowerbankStatus
+		expected bool
+	}{
+		{data.PowerbankStatusAvailable, data.PowerbankStatusRented, true},
+		{data.PowerbankStatusRented, data.PowerbankStatusCharging, true},
+		{data.PowerbankStatusRented, data.PowerbankStatusAvailable, false},
+		{data.PowerbankStatusCharging, data.PowerbankStatusAvailable, true},
+		{data.PowerbankStatusRented, data.PowerbankStatusRented, false},
+		{data.PowerbankStatusCharging, data.PowerbankStatusRented, false},
+		{data.PowerbankStatusLost, data.PowerbankStatusAvailable, false},
+		{data.PowerbankStatusMaintenance, data.PowerbankStatusRented, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.from.CanTransitionTo(tt.to); got != tt.expected {
+			t.Errorf("%q.CanTransitionTo(%q) = %v, want %v", tt.from, tt.to, got, tt.expected)
+		}
+	}
+}
+
+func TestPowerbankStatus_CanEditTo(t *testing.T) {
+	tests := []struct {
+		from, to data.PowerbankStatus
+		expected bool
+	}{
+		{data.PowerbankStatusAvailable, data.PowerbankStatusMaintenance, true},
+		{data.PowerbankStatusCharging, data.PowerbankStatusAvailable, true},
+		{data.PowerbankStatusLost, data.PowerbankStatusMaintenance, true},
+		{data.PowerbankStatusAvailable, data.PowerbankStatusRented, false},
+		{data.PowerbankStatusRented, data.PowerbankStatusAvailable, false},
+		{data.PowerbankStatusRented, data.PowerbankStatusCharging, false},
+		{data.PowerbankStatusCharging, data.PowerbankStatusLost, false},
+		{data.PowerbankStatusLost, data.PowerbankStatusAvailable, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.from.CanEditTo(tt.to); got != tt.expected {
+			t.Errorf("%q.CanEditTo(%q) = %v, want %v", tt.from, tt.to, got, tt.expected)
+		}
+	}
+}
+
+func TestPowerbankModel_Update_RentedRejected(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.PowerbankModel{DB: db}
+	p := &data.Powerbank{
+		ID:               5,
+		CurrentStationID: 20,
+		Status:           data.PowerbankStatusAvailable,
+	}
+
+	// Аренда ещё открыта: ручной перевод в available оставил бы её без
+	// повербанка.
+	mock.ExpectBegin()
+	expectLockPowerbank(mock, p.ID, data.PowerbankStatusRented, 20)
+	mock.ExpectRollback()
+
+	err = model.Update(p, data.PowerbankStatusRented, nil, nil)
+	if !errors.Is(err, data.ErrInvalidTransition) {
+		t.Errorf("expected error %q, got %v", data.ErrInvalidTransition, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPowerbankModel_Update_InvalidTransition(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.PowerbankModel{DB: db}
+	p := &data.Powerbank{
+		ID:               5,
+		CurrentStationID: 20,
+		Status:           data.PowerbankStatusRented,
+	}
+
+	mock.ExpectBegin()
+	expectLockPowerbank(mock, p.ID, data.PowerbankStatusCharging, 20)
+	mock.ExpectRollback()
+
+	err = model.Update(p, data.PowerbankStatusCharging, nil, nil)
+	if !errors.Is(err, data.ErrInvalidTransition) {
+		t.Errorf("expected error %q, got %v", data.ErrInvalidTransition, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPowerbankModel_Update_ConcurrentRent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.PowerbankModel{DB: db}
+	p := &data.Powerbank{
+		ID:               5,
+		CurrentStationID: 20,
+		Status:           data.PowerbankStatusRented,
+	}
+
+	// Оба запроса прочитали available, но первый уже выдал повербанк: под
+	// блокировкой второй видит rented и получает отказ.
+	mock.ExpectBegin()
+	expectLockPowerbank(mock, p.ID, data.PowerbankStatusRented, 20)
+	mock.ExpectRollback()
+
+	err = model.Update(p, data.PowerbankStatusAvailable, nil, nil)
+	if !errors.Is(err, data.ErrInvalidTransition) {
+		t.Errorf("expected error %q, got %v", data.ErrInvalidTransition, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
 func TestPowerbankModel_Delete_Success(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -271,14 +511,19 @@ func TestPowerbankModel_Delete_Success(t *testing.T) {
 	model := data.PowerbankModel{DB: db}
 	powerbankID := 5
 
+	mock.ExpectBegin()
 	mock.ExpectExec(regexp.QuoteMeta(`
-		DELETE FROM powerbanks
-		WHERE id = $1
+		UPDATE powerbanks
+		SET deleted_at = NOW(),
+			slot_number = NULL,
+			version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL AND status <> $2
 	`)).
-		WithArgs(powerbankID).
+		WithArgs(powerbankID, data.PowerbankStatusRented).
 		WillReturnResult(sqlmock.NewResult(0, 1)) // 1 строка затронута
+	mock.ExpectCommit()
 
-	err = model.Delete(powerbankID)
+	err = model.Delete(powerbankID, nil)
 	if err != nil {
 		t.Errorf("unexpected error in Delete: %s", err)
 	}
@@ -297,20 +542,148 @@ func TestPowerbankModel_Delete_NotFound(t *testing.T) {
 	model := data.PowerbankModel{DB: db}
 	powerbankID := 999
 
+	mock.ExpectBegin()
 	mock.ExpectExec(regexp.QuoteMeta(`
-		DELETE FROM powerbanks
-		WHERE id = $1
+		UPDATE powerbanks
+		SET deleted_at = NOW(),
+			slot_number = NULL,
+			version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL AND status <> $2
 	`)).
-		WithArgs(powerbankID).
+		WithArgs(powerbankID, data.PowerbankStatusRented).
 		WillReturnResult(sqlmock.NewResult(0, 0)) // 0 строк затронуто
+	expectPowerbankDeleteStatus(mock, powerbankID).WillReturnRows(sqlmock.NewRows([]string{"status"}))
+	mock.ExpectRollback()
 
-	err = model.Delete(powerbankID)
-	if err == nil {
-		t.Error("expected error for non-existent powerbank in Delete, got nil")
+	err = model.Delete(powerbankID, nil)
+	if !errors.Is(err, data.ErrRecordNotFound) {
+		t.Errorf("expected error %q, got %v", data.ErrRecordNotFound, err)
 	}
-	expected := fmt.Sprintf("powerbank с id %d не найден", powerbankID)
-	if err.Error() != expected {
-		t.Errorf("expected error %q, got %q", expected, err.Error())
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// Повербанк в аренде не удаляется, и это отличается от отсутствия повербанка.
+func TestPowerbankModel_Delete_Rented(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.PowerbankModel{DB: db}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE powerbanks`)).
+		WithArgs(5, data.PowerbankStatusRented).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	expectPowerbankDeleteStatus(mock, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(data.PowerbankStatusRented))
+	mock.ExpectRollback()
+
+	err = model.Delete(5, nil)
+	if !errors.Is(err, data.ErrPowerbankRented) {
+		t.Errorf("expected error %q, got %v", data.ErrPowerbankRented, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// expectPowerbankDeleteStatus ожидает проверку, почему Delete не затронул
+// ни одной строки.
+func expectPowerbankDeleteStatus(mock sqlmock.Sqlmock, id int) *sqlmock.ExpectedQuery {
+	return mock.ExpectQuery(regexp.QuoteMeta(`
+			SELECT status
+			FROM powerbanks
+			WHERE id = $1 AND deleted_at IS NULL
+		`)).
+		WithArgs(id)
+}
+
+func expectRestorePowerbankLock(mock sqlmock.Sqlmock, id, stationID int, slot any, deletedAt any, stationDeleted bool) {
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT p.current_station_id, p.slot_number, p.status, p.deleted_at, s.deleted_at IS NOT NULL
+		FROM powerbanks p
+		INNER JOIN stations s ON s.id = p.current_station_id
+		WHERE p.id = $1
+		FOR UPDATE OF p
+	`)).
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"current_station_id", "slot_number", "status", "deleted_at", "station_deleted"}).
+			AddRow(stationID, slot, data.PowerbankStatusAvailable, deletedAt, stationDeleted))
+}
+
+func TestPowerbankModel_Restore_ClaimsFreeSlot(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.PowerbankModel{DB: db}
+
+	now := time.Now()
+
+	mock.ExpectBegin()
+	expectRestorePowerbankLock(mock, 5, 10, nil, now, false)
+	expectClaimSlot(mock, 10, 5, 4, 1)
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		UPDATE powerbanks
+		SET deleted_at = NULL,
+			slot_number = $1,
+			version = version + 1,
+			updated_at = NOW()
+		WHERE id = $2
+		RETURNING created_at, updated_at, version
+	`)).
+		WithArgs(2, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at", "version"}).AddRow(now, now, 4))
+	expectAudit(mock, "restore powerbank 5").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	p, err := model.Restore(5, &data.Audit{})
+	if err != nil {
+		t.Fatalf("unexpected error in Restore: %s", err)
+	}
+	if p.DeletedAt != nil || *p.SlotNumber != 2 || p.Version != 4 {
+		t.Errorf("unexpected restored powerbank: %+v", p)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPowerbankModel_Restore_Rejected(t *testing.T) {
+	tests := []struct {
+		name           string
+		deletedAt      any
+		stationDeleted bool
+		expected       error
+	}{
+		{"not deleted", nil, false, data.ErrNotDeleted},
+		{"station deleted", time.Now(), true, data.ErrParentDeleted},
+	}
+
+	for _, tt := range tests {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("unexpected error when creating sqlmock: %s", err)
+		}
+
+		mock.ExpectBegin()
+		expectRestorePowerbankLock(mock, 5, 10, 1, tt.deletedAt, tt.stationDeleted)
+		mock.ExpectRollback()
+
+		_, err = data.PowerbankModel{DB: db}.Restore(5, nil)
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected error %q, got %v", tt.name, tt.expected, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("%s: there were unfulfilled expectations: %s", tt.name, err)
+		}
+		db.Close()
 	}
 }
 
@@ -324,16 +697,24 @@ func TestPowerbankModel_List_Success(t *testing.T) {
 	model := data.PowerbankModel{DB: db}
 	now := time.Now()
 
+	filters := data.Filters{Page: 1, PageSize: 20, Sort: "-status", SortSafelist: []string{"status", "-status"}}
+	statuses := []string{string(data.PowerbankStatusAvailable), string(data.PowerbankStatusRented)}
+
 	mock.ExpectQuery(regexp.QuoteMeta(`
-		SELECT id, current_station_id, status, created_at, updated_at
+		SELECT count(*) OVER(), id, current_station_id, slot_number, status, created_at, updated_at, version, deleted_at
 		FROM powerbanks
-		ORDER BY id
+		WHERE (status = ANY($1) OR cardinality($1) = 0)
+		AND (current_station_id = $2 OR $2 = 0)
+		AND (deleted_at IS NULL OR $3)
+		ORDER BY status DESC, id ASC
+		LIMIT $4 OFFSET $5
 	`)).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "current_station_id", "status", "created_at", "updated_at"}).
-			AddRow(1, 10, data.PowerbankStatusAvailable, now, now).
-			AddRow(2, 20, data.PowerbankStatusRented, now, now))
+		WithArgs(pq.Array(statuses), 0, false, 20, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "id", "current_station_id", "slot_number", "status", "created_at", "updated_at", "version", "deleted_at"}).
+			AddRow(2, 1, 10, 1, data.PowerbankStatusAvailable, now, now, 1, nil).
+			AddRow(2, 2, 20, nil, data.PowerbankStatusRented, now, now, 1, nil))
 
-	powerbanks, err := model.List()
+	powerbanks, _, err := model.List(statuses, 0, false, filters)
 	if err != nil {
 		t.Errorf("unexpected error in List: %s", err)
 	}
@@ -345,6 +726,48 @@ func TestPowerbankModel_List_Success(t *testing.T) {
 	}
 }
 
+func TestPowerbankModel_ListForOrganization_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.PowerbankModel{DB: db}
+	now := time.Now()
+
+	filters := data.Filters{Page: 1, PageSize: 20, Sort: "id", SortSafelist: []string{"id", "-id"}}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT count(*) OVER(), p.id, p.current_station_id, p.slot_number, p.status, p.created_at, p.updated_at, p.version, p.deleted_at
+		FROM powerbanks p
+		JOIN stations s ON s.id = p.current_station_id
+		WHERE s.org_id = $1
+		AND (p.status = ANY($2) OR cardinality($2) = 0)
+		AND (p.deleted_at IS NULL OR $3)
+		ORDER BY p.id ASC, p.id ASC
+		LIMIT $4 OFFSET $5
+	`)).
+		WithArgs(7, pq.Array([]string{}), false, 20, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "id", "current_station_id", "slot_number", "status", "created_at", "updated_at", "version", "deleted_at"}).
+			AddRow(2, 1, 10, 1, data.PowerbankStatusAvailable, now, now, 1, nil).
+			AddRow(2, 2, 11, 3, data.PowerbankStatusCharging, now, now, 1, nil))
+
+	powerbanks, metadata, err := model.ListForOrganization(7, []string{}, false, filters)
+	if err != nil {
+		t.Errorf("unexpected error in ListForOrganization: %s", err)
+	}
+	if len(powerbanks) != 2 {
+		t.Errorf("expected 2 powerbanks, got %d", len(powerbanks))
+	}
+	if metadata.TotalRecords != 2 {
+		t.Errorf("expected 2 total records, got %d", metadata.TotalRecords)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
 func TestPowerbankModel_ClarifyStatus_Success(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -396,3 +819,412 @@ func TestPowerbankModel_ClarifyStatus_NotFound(t *testing.T) {
 		t.Errorf("expected error %q, got %q", expected, err.Error())
 	}
 }
+
+func expectInsertPowerbank(mock sqlmock.Sqlmock, stationID int, slot any, status data.PowerbankStatus) *sqlmock.ExpectedQuery {
+	return mock.ExpectQuery(regexp.QuoteMeta(`
+		INSERT INTO powerbanks (current_station_id, slot_number, status)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, updated_at, version
+	`)).
+		WithArgs(stationID, slot, status)
+}
+
+func TestPowerbankModel_Import_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.PowerbankModel{DB: db}
+	powerbanks := []*data.Powerbank{
+		{CurrentStationID: 10, Status: data.PowerbankStatusAvailable},
+		{CurrentStationID: 10, Status: data.PowerbankStatusCharging},
+	}
+	now := time.Now()
+
+	// Второй повербанк видит слот, занятый первым в той же транзакции.
+	mock.ExpectBegin()
+	expectClaimSlot(mock, 10, 0, 4)
+	expectInsertPowerbank(mock, 10, 1, data.PowerbankStatusAvailable).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version"}).AddRow(5, now, now, 1))
+	expectPowerbankEvent(mock).
+		WithArgs(5, nil, data.PowerbankStatusAvailable, nil, 10, 3, nil)
+	expectClaimSlot(mock, 10, 0, 4, 1)
+	expectInsertPowerbank(mock, 10, 2, data.PowerbankStatusCharging).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version"}).AddRow(6, now, now, 1))
+	expectPowerbankEvent(mock).
+		WithArgs(6, nil, data.PowerbankStatusCharging, nil, 10, 3, nil)
+	expectAudit(mock, "create powerbank 5", "create powerbank 6").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	actorID := 3
+	if err := model.Import(powerbanks, &actorID, &data.Audit{ActorID: &actorID}); err != nil {
+		t.Fatalf("unexpected error in Import: %s", err)
+	}
+	if *powerbanks[0].SlotNumber != 1 || *powerbanks[1].SlotNumber != 2 {
+		t.Errorf("expected slots 1 and 2, got %d and %d", *powerbanks[0].SlotNumber, *powerbanks[1].SlotNumber)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPowerbankModel_Import_StationFull(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.PowerbankModel{DB: db}
+	powerbanks := []*data.Powerbank{
+		{CurrentStationID: 10, Status: data.PowerbankStatusCharging},
+		{CurrentStationID: 10, Status: data.PowerbankStatusAvailable},
+	}
+	now := time.Now()
+
+	// Первый повербанк занимает единственный слот станции.
+	mock.ExpectBegin()
+	expectClaimSlot(mock, 10, 0, 1)
+	expectInsertPowerbank(mock, 10, 1, data.PowerbankStatusCharging).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version"}).AddRow(5, now, now, 1))
+	expectPowerbankEvent(mock).
+		WithArgs(5, nil, data.PowerbankStatusCharging, nil, 10, nil, nil)
+	expectClaimSlot(mock, 10, 0, 1, 1)
+	mock.ExpectRollback()
+
+	err = model.Import(powerbanks, nil, nil)
+
+	var bulkErr *data.BulkError
+	if !errors.As(err, &bulkErr) {
+		t.Fatalf("expected *data.BulkError, got %v", err)
+	}
+	if bulkErr.Index != 1 {
+		t.Errorf("expected failed index 1, got %d", bulkErr.Index)
+	}
+	if !errors.Is(err, data.ErrStationFull) {
+		t.Errorf("expected ErrStationFull, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// expectCheckImportStation ожидает чтение вместимости и занятых слотов
+// станции без блокировки.
+func expectCheckImportStation(mock sqlmock.Sqlmock, stationID, capacity int, occupied ...int) {
+	mock.ExpectQuery(regexp.QuoteMeta(`
+				SELECT capacity
+				FROM stations
+				WHERE id = $1 AND deleted_at IS NULL
+			`)).
+		WithArgs(stationID).
+		WillReturnRows(sqlmock.NewRows([]string{"capacity"}).AddRow(capacity))
+
+	rows := sqlmock.NewRows([]string{"slot_number"})
+	for _, n := range occupied {
+		rows.AddRow(n)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT slot_number
+		FROM powerbanks
+		WHERE current_station_id = $1 AND slot_number IS NOT NULL AND id <> $2
+	`)).
+		WithArgs(stationID, 0).
+		WillReturnRows(rows)
+}
+
+// Проверка пакета ничего не пишет в базу, а станция читается один раз.
+func TestPowerbankModel_CheckImport_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.PowerbankModel{DB: db}
+	slot := 4
+	powerbanks := []*data.Powerbank{
+		{CurrentStationID: 10, Status: data.PowerbankStatusAvailable},
+		{CurrentStationID: 10, Status: data.PowerbankStatusMaintenance},
+		{CurrentStationID: 10, SlotNumber: &slot, Status: data.PowerbankStatusCharging},
+	}
+
+	expectCheckImportStation(mock, 10, 4, 1)
+
+	if err := model.CheckImport(powerbanks); err != nil {
+		t.Fatalf("unexpected error in CheckImport: %s", err)
+	}
+	if powerbanks[0].SlotNumber != nil {
+		t.Errorf("CheckImport must not assign slots, got %d", *powerbanks[0].SlotNumber)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// Слот, занятый повербанком раньше в пакете, считается занятым.
+func TestPowerbankModel_CheckImport_SlotTakenInBatch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.PowerbankModel{DB: db}
+	slot := 2
+	powerbanks := []*data.Powerbank{
+		{CurrentStationID: 10, Status: data.PowerbankStatusAvailable},
+		{CurrentStationID: 10, SlotNumber: &slot, Status: data.PowerbankStatusAvailable},
+	}
+
+	expectCheckImportStation(mock, 10, 4, 1)
+
+	err = model.CheckImport(powerbanks)
+
+	var bulkErr *data.BulkError
+	if !errors.As(err, &bulkErr) || bulkErr.Index != 1 {
+		t.Fatalf("expected *data.BulkError for index 1, got %v", err)
+	}
+	if !errors.Is(err, data.ErrSlotOccupied) {
+		t.Errorf("expected ErrSlotOccupied, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPowerbankModel_CheckImport_UnknownStation(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.PowerbankModel{DB: db}
+	powerbanks := []*data.Powerbank{{CurrentStationID: 99, Status: data.PowerbankStatusAvailable}}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM stations`)).
+		WithArgs(99).
+		WillReturnRows(sqlmock.NewRows([]string{"capacity"}))
+
+	err = model.CheckImport(powerbanks)
+	if !errors.Is(err, data.ErrInvalidForeignKey) {
+		t.Errorf("expected ErrInvalidForeignKey, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPowerbankModel_Export(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.PowerbankModel{DB: db}
+	now := time.Now()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT p.id, p.current_station_id, p.slot_number, p.status, p.created_at, p.updated_at, p.version
+		FROM powerbanks p
+		JOIN stations s ON s.id = p.current_station_id
+		WHERE (s.org_id = $1 OR $1 = 0)
+		AND (p.current_station_id = $2 OR $2 = 0)
+		AND p.deleted_at IS NULL
+		ORDER BY p.id ASC
+	`)).
+		WithArgs(3, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "current_station_id", "slot_number", "status", "created_at", "updated_at", "version"}).
+			AddRow(1, 10, 1, data.PowerbankStatusAvailable, now, now, 1).
+			AddRow(2, 10, nil, data.PowerbankStatusRented, now, now, 3))
+
+	var exported []*data.Powerbank
+	err = model.Export(3, 0, func(p *data.Powerbank) error {
+		exported = append(exported, p)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error in Export: %s", err)
+	}
+	if len(exported) != 2 {
+		t.Fatalf("expected 2 powerbanks, got %d", len(exported))
+	}
+	if exported[1].SlotNumber != nil {
+		t.Errorf("expected rented powerbank without slot, got %d", *exported[1].SlotNumber)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func expectLockPowerbanks(mock sqlmock.Sqlmock, ids []int, rows *sqlmock.Rows) {
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT id, current_station_id, slot_number, status, created_at, updated_at, version
+		FROM powerbanks
+		WHERE id = ANY($1) AND deleted_at IS NULL
+		ORDER BY id
+		FOR UPDATE
+	`)).
+		WithArgs(pq.Array(ids)).
+		WillReturnRows(rows)
+}
+
+func lockedPowerbankRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "current_station_id", "slot_number", "status", "created_at", "updated_at", "version"})
+}
+
+var transferPowerbankQuery = regexp.QuoteMeta(`
+		UPDATE powerbanks
+		SET current_station_id = $1,
+			slot_number = $2,
+			version = version + 1,
+			updated_at = NOW()
+		WHERE id = $3
+		RETURNING updated_at, version
+	`)
+
+func TestPowerbankModel_Transfer_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.PowerbankModel{DB: db}
+	now := time.Now()
+	ids := []int{8, 3}
+
+	mock.ExpectBegin()
+	expectLockPowerbanks(mock, ids, lockedPowerbankRows().
+		AddRow(3, 10, 2, data.PowerbankStatusCharging, now, now, 4).
+		AddRow(8, 10, 1, data.PowerbankStatusAvailable, now, now, 2))
+
+	expectClaimSlot(mock, 20, 8, 4, 1)
+	mock.ExpectQuery(transferPowerbankQuery).
+		WithArgs(20, 2, 8).
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at", "version"}).AddRow(now, 3))
+	expectPowerbankEvent(mock).
+		WithArgs(8, data.PowerbankStatusAvailable, data.PowerbankStatusAvailable, 10, 20, 7, nil)
+
+	expectClaimSlot(mock, 20, 3, 4, 1, 2)
+	mock.ExpectQuery(transferPowerbankQuery).
+		WithArgs(20, 3, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at", "version"}).AddRow(now, 5))
+	expectPowerbankEvent(mock).
+		WithArgs(3, data.PowerbankStatusCharging, data.PowerbankStatusCharging, 10, 20, 7, nil)
+	expectAudit(mock, "update powerbank 8", "update powerbank 3").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	actorID := 7
+	moved, err := model.Transfer(10, 20, ids, &actorID, &data.Audit{ActorID: &actorID})
+	if err != nil {
+		t.Fatalf("unexpected error in Transfer: %s", err)
+	}
+	if len(moved) != 2 || moved[0].ID != 8 || moved[1].ID != 3 {
+		t.Fatalf("expected powerbanks in request order [8 3], got %v", moved)
+	}
+	if moved[0].CurrentStationID != 20 || *moved[0].SlotNumber != 2 || moved[0].Version != 3 {
+		t.Errorf("unexpected first moved powerbank: %+v", moved[0])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPowerbankModel_Transfer_Rejected(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		rows    *sqlmock.Rows
+		wantErr error
+	}{
+		{
+			name:    "not found",
+			rows:    lockedPowerbankRows().AddRow(3, 10, 2, data.PowerbankStatusCharging, now, now, 1),
+			wantErr: data.ErrRecordNotFound,
+		},
+		{
+			name: "other station",
+			rows: lockedPowerbankRows().
+				AddRow(3, 10, 2, data.PowerbankStatusCharging, now, now, 1).
+				AddRow(8, 11, 1, data.PowerbankStatusAvailable, now, now, 1),
+			wantErr: data.ErrNotAtStation,
+		},
+		{
+			name: "rented",
+			rows: lockedPowerbankRows().
+				AddRow(3, 10, 2, data.PowerbankStatusCharging, now, now, 1).
+				AddRow(8, 10, nil, data.PowerbankStatusRented, now, now, 1),
+			wantErr: data.ErrPowerbankNotMovable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("unexpected error when creating sqlmock: %s", err)
+			}
+			defer db.Close()
+
+			model := data.PowerbankModel{DB: db}
+			ids := []int{8, 3}
+
+			mock.ExpectBegin()
+			expectLockPowerbanks(mock, ids, tt.rows)
+			mock.ExpectRollback()
+
+			_, err = model.Transfer(10, 20, ids, nil, nil)
+
+			var bulkErr *data.BulkError
+			if !errors.As(err, &bulkErr) || bulkErr.Index != 0 {
+				t.Fatalf("expected *data.BulkError for index 0, got %v", err)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestPowerbankModel_Transfer_StationFull(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.PowerbankModel{DB: db}
+	now := time.Now()
+	ids := []int{3, 8}
+
+	mock.ExpectBegin()
+	expectLockPowerbanks(mock, ids, lockedPowerbankRows().
+		AddRow(3, 10, 2, data.PowerbankStatusCharging, now, now, 4).
+		AddRow(8, 10, 1, data.PowerbankStatusAvailable, now, now, 2))
+
+	expectClaimSlot(mock, 20, 3, 2, 1)
+	mock.ExpectQuery(transferPowerbankQuery).
+		WithArgs(20, 2, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at", "version"}).AddRow(now, 5))
+	expectPowerbankEvent(mock).
+		WithArgs(3, data.PowerbankStatusCharging, data.PowerbankStatusCharging, 10, 20, nil, nil)
+	expectClaimSlot(mock, 20, 8, 2, 1, 2)
+	mock.ExpectRollback()
+
+	_, err = model.Transfer(10, 20, ids, nil, nil)
+	if !errors.Is(err, data.ErrStationFull) {
+		t.Errorf("expected ErrStationFull, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,115 @@
+package data_test
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/olzzhas/qrent/internal/data"
+)
+
+func TestSignQRCode_RoundTrip(t *testing.T) {
+	secret := []byte("test-secret")
+	code := data.QRCode{Kind: data.QRKindStation, ID: 12, Revision: 3}
+
+	payload := data.SignQRCode(secret, code)
+	if !strings.HasPrefix(payload, "qrent:v1:s:12:3:") {
+		t.Errorf("unexpected payload %q", payload)
+	}
+	if again := data.SignQRCode(secret, code); again != payload {
+		t.Errorf("expected stable payload, got %q and %q", payload, again)
+	}
+
+	parsed, err := data.ParseQRCode(secret, payload)
+	if err != nil {
+		t.Fatalf("unexpected error in ParseQRCode: %s", err)
+	}
+	if parsed != code {
+		t.Errorf("expected %+v, got %+v", code, parsed)
+	}
+}
+
+func TestParseQRCode_Rejected(t *testing.T) {
+	secret := []byte("test-secret")
+	payload := data.SignQRCode(secret, data.QRCode{Kind: data.QRKindPowerbank, ID: 7, Revision: 1})
+
+	tests := []struct {
+		name    string
+		secret  []byte
+		payload string
+	}{
+		{"tampered id", secret, strings.Replace(payload, ":7:", ":8:", 1)},
+		{"tampered revision", secret, strings.Replace(payload, ":7:1:", ":7:2:", 1)},
+		{"foreign secret", []byte("other-secret"), payload},
+		{"unknown kind", secret, strings.Replace(payload, ":p:", ":x:", 1)},
+		{"garbage", secret, "https://example.com/station/7"},
+	}
+
+	for _, tt := range tests {
+		if _, err := data.ParseQRCode(tt.secret, tt.payload); !errors.Is(err, data.ErrInvalidQRCode) {
+			t.Errorf("%s: expected error %q, got %v", tt.name, data.ErrInvalidQRCode, err)
+		}
+	}
+}
+
+func TestQRCodeModel_Revoke(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.QRCodeModel{DB: db}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		UPDATE stations
+		SET qr_revision = qr_revision + 1,
+			version = version + 1,
+			updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING qr_revision
+	`)).
+		WithArgs(12).
+		WillReturnRows(sqlmock.NewRows([]string{"qr_revision"}).AddRow(4))
+	expectAudit(mock, "update station 12").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	revision, err := model.Revoke(data.QRKindStation, 12, &data.Audit{})
+	if err != nil {
+		t.Fatalf("unexpected error in Revoke: %s", err)
+	}
+	if revision != 4 {
+		t.Errorf("expected revision 4, got %d", revision)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// У удалённой станции коды не отзываются.
+func TestQRCodeModel_Revoke_Deleted(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.QRCodeModel{DB: db}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE id = $1 AND deleted_at IS NULL`)).
+		WithArgs(12).
+		WillReturnRows(sqlmock.NewRows([]string{"qr_revision"}))
+	mock.ExpectRollback()
+
+	_, err = model.Revoke(data.QRKindStation, 12, &data.Audit{})
+	if !errors.Is(err, data.ErrRecordNotFound) {
+		t.Errorf("expected error %q, got %v", data.ErrRecordNotFound, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,172 @@
+package data_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/olzzhas/qrent/internal/data"
+)
+
+func coords(lat, lon float64) (*float64, *float64) {
+	return &lat, &lon
+}
+
+func stock(id, capacity, docked, movable int, lat, lon *float64) data.StationStock {
+	return data.StationStock{StationID: id, Latitude: lat, Longitude: lon, Capacity: capacity, Docked: docked, Movable: movable}
+}
+
+func TestPlanRebalance(t *testing.T) {
+	latA, lonA := coords(43.2389, 76.8897)
+	latB, lonB := coords(43.2400, 76.8900) // ~125 м от A
+	latC, lonC := coords(43.3000, 76.9500) // ~8 км от A
+
+	tests := []struct {
+		name      string
+		stocks    []data.StationStock
+		target    float64
+		transfers []data.RebalanceTransfer
+		planned   []int
+	}{
+		{
+			name: "nearest station first",
+			stocks: []data.StationStock{
+				stock(1, 10, 9, 9, latA, lonA),
+				stock(2, 10, 1, 1, latB, lonB),
+				stock(3, 10, 3, 3, latC, lonC),
+			},
+			target:    0.5,
+			transfers: []data.RebalanceTransfer{{FromStationID: 1, ToStationID: 2, Count: 4}},
+			planned:   []int{5, 5, 3},
+		},
+		{
+			name: "surplus split across receivers",
+			stocks: []data.StationStock{
+				stock(1, 10, 10, 10, latA, lonA),
+				stock(2, 10, 3, 3, latB, lonB),
+				stock(3, 10, 0, 0, latC, lonC),
+			},
+			target: 0.5,
+			transfers: []data.RebalanceTransfer{
+				{FromStationID: 1, ToStationID: 2, Count: 2},
+				{FromStationID: 1, ToStationID: 3, Count: 3},
+			},
+			planned: []int{5, 5, 3},
+		},
+		{
+			name: "maintenance powerbanks stay",
+			stocks: []data.StationStock{
+				stock(1, 10, 9, 2, latA, lonA),
+				stock(2, 10, 0, 0, latB, lonB),
+			},
+			target:    0.5,
+			transfers: []data.RebalanceTransfer{{FromStationID: 1, ToStationID: 2, Count: 2}},
+			planned:   []int{7, 2},
+		},
+		{
+			name: "stations without coordinates go last",
+			stocks: []data.StationStock{
+				stock(1, 4, 4, 4, latA, lonA),
+				stock(2, 4, 0, 0, nil, nil),
+				stock(3, 4, 1, 1, latC, lonC),
+			},
+			target: 0.5,
+			transfers: []data.RebalanceTransfer{
+				{FromStationID: 1, ToStationID: 3, Count: 1},
+				{FromStationID: 1, ToStationID: 2, Count: 1},
+			},
+			planned: []int{2, 1, 2},
+		},
+		{
+			name: "already balanced",
+			stocks: []data.StationStock{
+				stock(1, 10, 5, 5, latA, lonA),
+				stock(2, 6, 3, 3, latB, lonB),
+			},
+			target:    0.5,
+			transfers: []data.RebalanceTransfer{},
+			planned:   []int{5, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := data.PlanRebalance(tt.stocks, tt.target)
+
+			if len(plan.Transfers) != len(tt.transfers) {
+				t.Fatalf("expected %d transfers, got %+v", len(tt.transfers), plan.Transfers)
+			}
+			for i, want := range tt.transfers {
+				got := plan.Transfers[i]
+				if got.FromStationID != want.FromStationID || got.ToStationID != want.ToStationID || got.Count != want.Count {
+					t.Errorf("transfer %d: expected %d -> %d x%d, got %d -> %d x%d", i,
+						want.FromStationID, want.ToStationID, want.Count, got.FromStationID, got.ToStationID, got.Count)
+				}
+			}
+			for i, want := range tt.planned {
+				if got := plan.Stations[i].Planned; got != want {
+					t.Errorf("station %d: expected %d planned, got %d", plan.Stations[i].StationID, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestPlanRebalance_Distance(t *testing.T) {
+	latA, lonA := coords(43.2389, 76.8897)
+	latB, lonB := coords(43.2389, 76.9021) // ~1 км к востоку
+
+	plan := data.PlanRebalance([]data.StationStock{
+		stock(1, 2, 2, 2, latA, lonA),
+		stock(2, 2, 0, 0, latB, lonB),
+	}, 0.5)
+
+	if len(plan.Transfers) != 1 || plan.Transfers[0].Distance == nil {
+		t.Fatalf("expected one transfer with distance, got %+v", plan.Transfers)
+	}
+	if d := *plan.Transfers[0].Distance; d < 990 || d > 1020 {
+		t.Errorf("expected distance about 1000 m, got %v", d)
+	}
+}
+
+func TestStationModel_Stock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.StationModel{DB: db}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+        SELECT s.id, s.latitude, s.longitude, s.capacity,
+            count(p.id) FILTER (WHERE p.slot_number IS NOT NULL),
+            count(p.id) FILTER (WHERE p.slot_number IS NOT NULL AND p.status IN ('available', 'charging'))
+        FROM stations s
+        LEFT JOIN powerbanks p ON p.current_station_id = s.id AND p.deleted_at IS NULL
+        WHERE s.org_id = $1 AND s.deleted_at IS NULL
+        GROUP BY s.id
+        ORDER BY s.id ASC
+    `)).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "latitude", "longitude", "capacity", "docked", "movable"}).
+			AddRow(1, 43.2389, 76.8897, 10, 7, 6).
+			AddRow(2, nil, nil, 4, 0, 0))
+
+	stocks, err := model.Stock(3)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(stocks) != 2 {
+		t.Fatalf("expected 2 stations, got %d", len(stocks))
+	}
+	if stocks[0].Docked != 7 || stocks[0].Movable != 6 || stocks[0].Latitude == nil {
+		t.Errorf("unexpected first station: %+v", stocks[0])
+	}
+	if stocks[1].Latitude != nil {
+		t.Errorf("expected station without coordinates, got %v", *stocks[1].Latitude)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,346 @@
+package data_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/olzzhas/qrent/internal/data"
+)
+
+// tariffSnapshotArg совпадает со снимком тарифа id, записанным в аренду.
+type tariffSnapshotArg struct {
+	id int
+}
+
+func (a tariffSnapshotArg) Match(v driver.Value) bool {
+	raw, ok := v.([]byte)
+	if !ok {
+		return false
+	}
+	var tariff data.Tariff
+	return json.Unmarshal(raw, &tariff) == nil && tariff.ID == a.id
+}
+
+// Действующий тариф станции копируется в аренду при выдаче.
+func TestRentalModel_Start_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.RentalModel{DB: db}
+	userID := 3
+	rental := &data.Rental{UserID: &userID, PowerbankID: 5, StartStationID: 10}
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT status, current_station_id, slot_number
+		FROM powerbanks
+		WHERE id = $1 AND deleted_at IS NULL
+		FOR UPDATE
+	`)).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "current_station_id", "slot_number"}).
+			AddRow(data.PowerbankStatusAvailable, 10, 3))
+	mock.ExpectExec(regexp.QuoteMeta(`
+		UPDATE powerbanks
+		SET status = $1,
+			slot_number = NULL,
+			version = version + 1,
+			updated_at = NOW()
+		WHERE id = $2
+	`)).
+		WithArgs(data.PowerbankStatusRented, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectTariffForStation(mock, 10).
+		WillReturnRows(tariffRows().AddRow(7, 1, nil, "KZT", 100, 200, 60, 10, nil, 5000, 0, now, now))
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		INSERT INTO rentals (user_id, powerbank_id, start_station_id, start_slot_number, status,
+			tariff_id, tariff_snapshot)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, started_at, created_at, updated_at
+	`)).
+		WithArgs(&userID, 5, 10, 3, data.RentalStatusActive, 7, tariffSnapshotArg{7}).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "started_at", "created_at", "updated_at"}).
+			AddRow(1, now, now, now))
+	expectPowerbankEvent(mock).
+		WithArgs(5, data.PowerbankStatusAvailable, data.PowerbankStatusRented, 10, 10, &userID, 1)
+	mock.ExpectCommit()
+
+	if err := model.Start(rental, nil); err != nil {
+		t.Fatalf("unexpected error in Start: %s", err)
+	}
+	if rental.ID != 1 || rental.Status != data.RentalStatusActive || rental.StartSlot == nil || *rental.StartSlot != 3 ||
+		rental.TariffID == nil || *rental.TariffID != 7 {
+		t.Errorf("unexpected rental data: %+v", rental)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRentalModel_Start_AttachesDeposit(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.RentalModel{DB: db}
+	userID := 3
+	rental := &data.Rental{UserID: &userID, PowerbankID: 5, StartStationID: 10}
+	deposit := &data.Payment{ID: 42, Kind: data.PaymentKindAuthorize}
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT status, current_station_id, slot_number
+		FROM powerbanks
+		WHERE id = $1 AND deleted_at IS NULL
+		FOR UPDATE
+	`)).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "current_station_id", "slot_number"}).
+			AddRow(data.PowerbankStatusAvailable, 10, 3))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE powerbanks`)).
+		WithArgs(data.PowerbankStatusRented, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectTariffForStation(mock, 10).WillReturnRows(tariffRows())
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO rentals`)).
+		WithArgs(&userID, 5, 10, 3, data.RentalStatusActive, nil, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "started_at", "created_at", "updated_at"}).
+			AddRow(1, now, now, now))
+	expectPowerbankEvent(mock).
+		WithArgs(5, data.PowerbankStatusAvailable, data.PowerbankStatusRented, 10, 10, &userID, 1)
+	mock.ExpectExec(regexp.QuoteMeta(`
+			UPDATE payments
+			SET rental_id = $1,
+				updated_at = NOW()
+			WHERE id = $2
+		`)).
+		WithArgs(1, int64(42)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := model.Start(rental, deposit); err != nil {
+		t.Fatalf("unexpected error in Start: %s", err)
+	}
+	if deposit.RentalID == nil || *deposit.RentalID != 1 {
+		t.Errorf("expected deposit to be attached to rental 1, got %v", deposit.RentalID)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRentalModel_Start_DepositMissing(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.RentalModel{DB: db}
+	rental := &data.Rental{PowerbankID: 5, StartStationID: 10}
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM powerbanks`)).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "current_station_id", "slot_number"}).
+			AddRow(data.PowerbankStatusAvailable, 10, 3))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE powerbanks`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectTariffForStation(mock, 10).WillReturnRows(tariffRows())
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO rentals`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "started_at", "created_at", "updated_at"}).
+			AddRow(1, now, now, now))
+	expectPowerbankEvent(mock)
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE payments`)).
+		WithArgs(1, int64(42)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	if err := model.Start(rental, &data.Payment{ID: 42}); err == nil {
+		t.Fatal("expected an error for a missing deposit, got nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRentalModel_Start_NotAvailable(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.RentalModel{DB: db}
+	rental := &data.Rental{PowerbankID: 5, StartStationID: 10}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT status, current_station_id, slot_number
+		FROM powerbanks
+		WHERE id = $1 AND deleted_at IS NULL
+		FOR UPDATE
+	`)).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "current_station_id", "slot_number"}).
+			AddRow(data.PowerbankStatusRented, 10, nil))
+	mock.ExpectRollback()
+
+	err = model.Start(rental, nil)
+	if !errors.Is(err, data.ErrPowerbankNotAvailable) {
+		t.Errorf("expected error %q, got %v", data.ErrPowerbankNotAvailable, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRentalModel_Start_PowerbankNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.RentalModel{DB: db}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT status, current_station_id, slot_number
+		FROM powerbanks
+		WHERE id = $1 AND deleted_at IS NULL
+		FOR UPDATE
+	`)).
+		WithArgs(999).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	err = model.Start(&data.Rental{PowerbankID: 999, StartStationID: 10}, nil)
+	if !errors.Is(err, data.ErrRecordNotFound) {
+		t.Errorf("expected error %q, got %v", data.ErrRecordNotFound, err)
+	}
+}
+
+// Цена считается по снимку тарифа из аренды: действующий тариф станции при
+// возврате не читается, поэтому его правка во время аренды цену не меняет.
+func TestRentalModel_Finish_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.RentalModel{DB: db}
+	now := time.Now()
+	rental := &data.Rental{ID: 1, PowerbankID: 5, StartStationID: 10, Status: data.RentalStatusActive,
+		StartedAt: now.Add(-95 * time.Minute)}
+
+	expectLockedRentalWithTariff(mock, 1, data.RentalStatusActive, &data.Tariff{ID: 7, Currency: "KZT",
+		UnlockFee: 100, PeriodPrice: 200, PeriodMinutes: 60, FreeMinutes: 10, LostPenalty: 5000})
+	expectRentedPowerbank(mock, 5, 10)
+	expectClaimSlot(mock, 20, 5, 4, 1, 2)
+	mock.ExpectExec(regexp.QuoteMeta(`
+		UPDATE powerbanks
+		SET current_station_id = $1,
+			slot_number = $2,
+			status = $3,
+			version = version + 1,
+			updated_at = NOW()
+		WHERE id = $4
+	`)).
+		WithArgs(20, 3, data.PowerbankStatusCharging, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectPowerbankEvent(mock).
+		WithArgs(5, data.PowerbankStatusRented, data.PowerbankStatusCharging, 10, 20, nil, 1)
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		UPDATE rentals
+		SET end_station_id = $1,
+			end_slot_number = $2,
+			status = $3,
+			ended_at = $4,
+			tariff_id = $5,
+			price = $6,
+			currency = $7,
+			updated_at = NOW()
+		WHERE id = $8
+		RETURNING end_station_id, end_slot_number, status, ended_at, updated_at
+	`)).
+		WithArgs(20, 3, data.RentalStatusFinished, sqlmock.AnyArg(), 7, int64(500), "KZT", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"end_station_id", "end_slot_number", "status", "ended_at", "updated_at"}).
+			AddRow(20, 3, data.RentalStatusFinished, now, now))
+	mock.ExpectCommit()
+
+	if err := model.Finish(rental, 20, nil); err != nil {
+		t.Fatalf("unexpected error in Finish: %s", err)
+	}
+	if rental.Status != data.RentalStatusFinished || rental.EndStationID == nil || *rental.EndStationID != 20 ||
+		rental.EndSlot == nil || *rental.EndSlot != 3 {
+		t.Errorf("unexpected rental data: %+v", rental)
+	}
+	if rental.Price == nil || *rental.Price != 500 || rental.Currency == nil || *rental.Currency != "KZT" {
+		t.Errorf("unexpected rental price: %v %v", rental.Price, rental.Currency)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRentalModel_Finish_NotActive(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.RentalModel{DB: db}
+	rental := &data.Rental{ID: 1, PowerbankID: 5}
+
+	expectLockedRental(mock, 1, data.RentalStatusFinished)
+	mock.ExpectRollback()
+
+	err = model.Finish(rental, 20, nil)
+	if !errors.Is(err, data.ErrRentalNotActive) {
+		t.Errorf("expected error %q, got %v", data.ErrRentalNotActive, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRentalModel_Get_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.RentalModel{DB: db}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT id, user_id, powerbank_id, start_station_id, start_slot_number,
+			end_station_id, end_slot_number, status, tariff_id, price, currency,
+			started_at, ended_at, created_at, updated_at
+		FROM rentals
+		WHERE id = $1
+	`)).
+		WithArgs(999).
+		WillReturnError(sql.ErrNoRows)
+
+	_, err = model.Get(999)
+	if !errors.Is(err, data.ErrRecordNotFound) {
+		t.Errorf("expected error %q, got %v", data.ErrRecordNotFound, err)
+	}
+}
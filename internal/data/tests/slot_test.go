@@ -0,0 +1,140 @@
+package data_test
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/olzzhas/qrent/internal/data"
+)
+
+// expectClaimSlot ожидает запросы выбора слота: блокировку станции с ёмкостью
+// capacity и чтение занятых слотов occupied.
+func expectClaimSlot(mock sqlmock.Sqlmock, stationID, powerbankID, capacity int, occupied ...int) {
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT capacity
+		FROM stations
+		WHERE id = $1 AND deleted_at IS NULL
+		FOR UPDATE
+	`)).
+		WithArgs(stationID).
+		WillReturnRows(sqlmock.NewRows([]string{"capacity"}).AddRow(capacity))
+
+	rows := sqlmock.NewRows([]string{"slot_number"})
+	for _, n := range occupied {
+		rows.AddRow(n)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT slot_number
+		FROM powerbanks
+		WHERE current_station_id = $1 AND slot_number IS NOT NULL AND id <> $2
+	`)).
+		WithArgs(stationID, powerbankID).
+		WillReturnRows(rows)
+}
+
+func expectActiveRental(mock sqlmock.Sqlmock, id int) {
+	expectLockedRental(mock, id, data.RentalStatusActive)
+}
+
+func TestRentalModel_Finish_StationFull(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.RentalModel{DB: db}
+	rental := &data.Rental{ID: 1, PowerbankID: 5}
+
+	expectActiveRental(mock, 1)
+	expectRentedPowerbank(mock, 5, 10)
+	expectClaimSlot(mock, 20, 5, 2, 1, 2)
+	mock.ExpectRollback()
+
+	err = model.Finish(rental, 20, nil)
+	if !errors.Is(err, data.ErrStationFull) {
+		t.Errorf("expected error %q, got %v", data.ErrStationFull, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRentalModel_Finish_SlotErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		slot     int
+		expected error
+	}{
+		{"occupied slot", 1, data.ErrSlotOccupied},
+		{"slot above capacity", 5, data.ErrInvalidSlot},
+	}
+
+	for _, tt := range tests {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("unexpected error when creating sqlmock: %s", err)
+		}
+
+		model := data.RentalModel{DB: db}
+		rental := &data.Rental{ID: 1, PowerbankID: 5}
+
+		expectActiveRental(mock, 1)
+		expectRentedPowerbank(mock, 5, 10)
+		expectClaimSlot(mock, 20, 5, 4, 1)
+		mock.ExpectRollback()
+
+		slot := tt.slot
+		err = model.Finish(rental, 20, &slot)
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected error %q, got %v", tt.name, tt.expected, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("%s: there were unfulfilled expectations: %s", tt.name, err)
+		}
+		db.Close()
+	}
+}
+
+func TestStationModel_Slots(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.StationModel{DB: db}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+        SELECT gs.n, p.id, p.status
+        FROM stations s
+        CROSS JOIN LATERAL generate_series(1, s.capacity) AS gs(n)
+        LEFT JOIN powerbanks p ON p.current_station_id = s.id AND p.slot_number = gs.n
+        WHERE s.id = $1
+        ORDER BY gs.n
+    `)).
+		WithArgs(20).
+		WillReturnRows(sqlmock.NewRows([]string{"n", "id", "status"}).
+			AddRow(1, 5, data.PowerbankStatusAvailable).
+			AddRow(2, nil, nil).
+			AddRow(3, 7, data.PowerbankStatusCharging))
+
+	slots, err := model.Slots(20)
+	if err != nil {
+		t.Fatalf("unexpected error in Slots: %s", err)
+	}
+	if len(slots) != 3 {
+		t.Fatalf("expected 3 slots, got %d", len(slots))
+	}
+	if slots[1].PowerbankID != nil || slots[1].Status != nil {
+		t.Errorf("expected slot 2 to be free, got %+v", slots[1])
+	}
+	if slots[2].PowerbankID == nil || *slots[2].PowerbankID != 7 {
+		t.Errorf("expected powerbank 7 in slot 3, got %+v", slots[2])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
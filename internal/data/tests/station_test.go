@@ -4,7 +4,6 @@ package data_test
 import (
 	"database/sql"
 	"errors"
-	"fmt"
 	"regexp"
 	"testing"
 	"time"
@@ -12,6 +11,7 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/lib/pq"
 	"github.com/olzzhas/qrent/internal/data"
+	"github.com/olzzhas/qrent/pkg/validator"
 )
 
 // Test Insert успеха
@@ -24,22 +24,28 @@ func TestStationModel_Insert_Success(t *testing.T) {
 
 	model := data.StationModel{DB: db}
 
+	lat, lon := 43.238949, 76.889709
 	station := &data.Station{
-		OrgID: 10,
+		OrgID:     10,
+		Latitude:  &lat,
+		Longitude: &lon,
+		Address:   "Абая 10, Алматы",
 	}
 
 	now := time.Now()
 
+	mock.ExpectBegin()
 	mock.ExpectQuery(regexp.QuoteMeta(`
-        INSERT INTO stations (org_id)
-        VALUES ($1)
-        RETURNING id, created_at, updated_at
+        INSERT INTO stations (org_id, latitude, longitude, address, capacity)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, created_at, updated_at, version
     `)).
-		WithArgs(station.OrgID).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).
-			AddRow(5, now, now))
+		WithArgs(station.OrgID, station.Latitude, station.Longitude, station.Address, station.Capacity).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version"}).
+			AddRow(5, now, now, 1))
+	mock.ExpectCommit()
 
-	if err = model.Insert(station); err != nil {
+	if err = model.Insert(station, nil); err != nil {
 		t.Errorf("unexpected error: %s", err)
 	}
 	if station.ID != 5 {
@@ -61,15 +67,17 @@ func TestStationModel_Insert_InvalidForeignKey(t *testing.T) {
 	model := data.StationModel{DB: db}
 	station := &data.Station{OrgID: 99999} // Предполагаем неверный OrgID
 
+	mock.ExpectBegin()
 	mock.ExpectQuery(regexp.QuoteMeta(`
-        INSERT INTO stations (org_id)
-        VALUES ($1)
-        RETURNING id, created_at, updated_at
+        INSERT INTO stations (org_id, latitude, longitude, address, capacity)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, created_at, updated_at, version
     `)).
-		WithArgs(station.OrgID).
+		WithArgs(station.OrgID, station.Latitude, station.Longitude, station.Address, station.Capacity).
 		WillReturnError(&pq.Error{Code: "23503"})
+	mock.ExpectRollback()
 
-	err = model.Insert(station)
+	err = model.Insert(station, nil)
 	if err == nil {
 		t.Errorf("expected error due to invalid foreign key, got nil")
 	}
@@ -92,19 +100,19 @@ func TestStationModel_Get_Success(t *testing.T) {
 
 	// Ожидаем запрос, возвращающий корректную запись
 	mock.ExpectQuery(regexp.QuoteMeta(`
-        SELECT id, org_id, created_at, updated_at
+        SELECT id, org_id, latitude, longitude, address, capacity, created_at, updated_at, version, deleted_at
         FROM stations
-        WHERE id = $1
+        WHERE id = $1 AND (deleted_at IS NULL OR $2)
     `)).
-		WithArgs(5).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "org_id", "created_at", "updated_at"}).
-			AddRow(5, 10, now, now))
+		WithArgs(5, false).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "org_id", "latitude", "longitude", "address", "capacity", "created_at", "updated_at", "version", "deleted_at"}).
+			AddRow(5, 10, 43.238949, 76.889709, "Абая 10, Алматы", 8, now, now, 1, nil))
 
 	station, err := model.Get(5)
 	if err != nil {
-		t.Errorf("unexpected error in Get: %s", err)
+		t.Fatalf("unexpected error in Get: %s", err)
 	}
-	if station.ID != 5 || station.OrgID != 10 {
+	if station.ID != 5 || station.OrgID != 10 || station.Latitude == nil || *station.Latitude != 43.238949 {
 		t.Errorf("unexpected station data: %+v", station)
 	}
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -123,20 +131,16 @@ func TestStationModel_Get_NotFound(t *testing.T) {
 	model := data.StationModel{DB: db}
 
 	mock.ExpectQuery(regexp.QuoteMeta(`
-        SELECT id, org_id, created_at, updated_at
+        SELECT id, org_id, latitude, longitude, address, capacity, created_at, updated_at, version, deleted_at
         FROM stations
-        WHERE id = $1
+        WHERE id = $1 AND (deleted_at IS NULL OR $2)
     `)).
-		WithArgs(999).
+		WithArgs(999, false).
 		WillReturnError(sql.ErrNoRows)
 
 	_, err = model.Get(999)
-	if err == nil {
-		t.Errorf("expected error for non-existent station, got nil")
-	}
-	expected := fmt.Sprintf("station not found: id %d", 999)
-	if err.Error() != expected {
-		t.Errorf("expected error %q, got %q", expected, err.Error())
+	if !errors.Is(err, data.ErrRecordNotFound) {
+		t.Errorf("expected error %q, got %v", data.ErrRecordNotFound, err)
 	}
 }
 
@@ -155,17 +159,25 @@ func TestStationModel_Update_Success(t *testing.T) {
 	}
 
 	newTime := time.Now()
+	mock.ExpectBegin()
+	expectHighestSlot(mock, station.ID, 0)
 	mock.ExpectQuery(regexp.QuoteMeta(`
         UPDATE stations
         SET org_id = $1,
+            latitude = $2,
+            longitude = $3,
+            address = $4,
+            capacity = $5,
+            version = version + 1,
             updated_at = NOW()
-        WHERE id = $2
-        RETURNING updated_at
+        WHERE id = $6 AND version = $7
+        RETURNING updated_at, version
     `)).
-		WithArgs(station.OrgID, station.ID).
-		WillReturnRows(sqlmock.NewRows([]string{"updated_at"}).AddRow(newTime))
+		WithArgs(station.OrgID, station.Latitude, station.Longitude, station.Address, station.Capacity, station.ID, station.Version).
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at", "version"}).AddRow(newTime, 2))
+	mock.ExpectCommit()
 
-	err = model.Update(station)
+	err = model.Update(station, nil)
 	if err != nil {
 		t.Errorf("unexpected error in Update: %s", err)
 	}
@@ -177,8 +189,8 @@ func TestStationModel_Update_Success(t *testing.T) {
 	}
 }
 
-// Test Update: не найден
-func TestStationModel_Update_NotFound(t *testing.T) {
+// Test Update: версия станции уже изменилась
+func TestStationModel_Update_EditConflict(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("Ошибка создания sqlmock: %s", err)
@@ -187,27 +199,31 @@ func TestStationModel_Update_NotFound(t *testing.T) {
 
 	model := data.StationModel{DB: db}
 	station := &data.Station{
-		ID:    999,
-		OrgID: 20,
+		ID:      5,
+		OrgID:   20,
+		Version: 3,
 	}
 
+	mock.ExpectBegin()
+	expectHighestSlot(mock, station.ID, 0)
 	mock.ExpectQuery(regexp.QuoteMeta(`
         UPDATE stations
         SET org_id = $1,
+            latitude = $2,
+            longitude = $3,
+            address = $4,
+            capacity = $5,
+            version = version + 1,
             updated_at = NOW()
-        WHERE id = $2
-        RETURNING updated_at
+        WHERE id = $6 AND version = $7
+        RETURNING updated_at, version
     `)).
-		WithArgs(station.OrgID, station.ID).
+		WithArgs(station.OrgID, station.Latitude, station.Longitude, station.Address, station.Capacity, station.ID, station.Version).
 		WillReturnError(sql.ErrNoRows)
 
-	err = model.Update(station)
-	if err == nil {
-		t.Errorf("expected error for non-existent station in Update, got nil")
-	}
-	expected := fmt.Sprintf("no station found with id %d", station.ID)
-	if err.Error() != expected {
-		t.Errorf("expected error %q, got %q", expected, err.Error())
+	err = model.Update(station, nil)
+	if !errors.Is(err, data.ErrEditConflict) {
+		t.Errorf("expected error %q, got %v", data.ErrEditConflict, err)
 	}
 }
 
@@ -225,17 +241,24 @@ func TestStationModel_Update_InvalidForeignKey(t *testing.T) {
 		OrgID: 99999, // несуществующий OrgID
 	}
 
+	mock.ExpectBegin()
+	expectHighestSlot(mock, station.ID, 0)
 	mock.ExpectQuery(regexp.QuoteMeta(`
         UPDATE stations
         SET org_id = $1,
+            latitude = $2,
+            longitude = $3,
+            address = $4,
+            capacity = $5,
+            version = version + 1,
             updated_at = NOW()
-        WHERE id = $2
-        RETURNING updated_at
+        WHERE id = $6 AND version = $7
+        RETURNING updated_at, version
     `)).
-		WithArgs(station.OrgID, station.ID).
+		WithArgs(station.OrgID, station.Latitude, station.Longitude, station.Address, station.Capacity, station.ID, station.Version).
 		WillReturnError(&pq.Error{Code: "23503"})
 
-	err = model.Update(station)
+	err = model.Update(station, nil)
 	if err == nil {
 		t.Errorf("expected error due to invalid foreign key in Update, got nil")
 	}
@@ -256,14 +279,27 @@ func TestStationModel_Delete_Success(t *testing.T) {
 	model := data.StationModel{DB: db}
 	stationID := 5
 
+	mock.ExpectBegin()
 	mock.ExpectExec(regexp.QuoteMeta(`
-        DELETE FROM stations
-        WHERE id = $1
+        UPDATE stations
+        SET deleted_at = NOW(),
+            version = version + 1
+        WHERE id = $1 AND deleted_at IS NULL
     `)).
 		WithArgs(stationID).
 		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`
+        UPDATE powerbanks
+        SET deleted_at = NOW(),
+            version = version + 1
+        WHERE current_station_id = $1 AND deleted_at IS NULL AND status <> $2
+        RETURNING id
+    `)).
+		WithArgs(stationID, data.PowerbankStatusRented).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectCommit()
 
-	err = model.Delete(stationID)
+	err = model.Delete(stationID, nil)
 	if err != nil {
 		t.Errorf("unexpected error in Delete: %s", err)
 	}
@@ -283,20 +319,103 @@ func TestStationModel_Delete_NotFound(t *testing.T) {
 	model := data.StationModel{DB: db}
 	stationID := 999
 
+	mock.ExpectBegin()
 	mock.ExpectExec(regexp.QuoteMeta(`
-        DELETE FROM stations
-        WHERE id = $1
+        UPDATE stations
+        SET deleted_at = NOW(),
+            version = version + 1
+        WHERE id = $1 AND deleted_at IS NULL
     `)).
 		WithArgs(stationID).
 		WillReturnResult(sqlmock.NewResult(0, 0)) // 0 строк затронуто
+	mock.ExpectRollback()
 
-	err = model.Delete(stationID)
-	if err == nil {
-		t.Errorf("expected error for non-existent station in Delete, got nil")
+	err = model.Delete(stationID, nil)
+	if !errors.Is(err, data.ErrRecordNotFound) {
+		t.Errorf("expected error %q, got %v", data.ErrRecordNotFound, err)
+	}
+}
+
+// expectRestoreStationLock ожидает блокировку станции при восстановлении.
+func expectRestoreStationLock(mock sqlmock.Sqlmock, id int, deletedAt any, orgDeleted bool) {
+	mock.ExpectQuery(regexp.QuoteMeta(`
+        SELECT s.deleted_at, o.deleted_at IS NOT NULL
+        FROM stations s
+        INNER JOIN organizations o ON o.id = s.org_id
+        WHERE s.id = $1
+        FOR UPDATE OF s
+    `)).
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"deleted_at", "org_deleted"}).AddRow(deletedAt, orgDeleted))
+}
+
+// Test Restore успеха: вместе со станцией возвращаются её повербанки
+func TestStationModel_Restore_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.StationModel{DB: db}
+	deletedAt := time.Now()
+
+	mock.ExpectBegin()
+	expectRestoreStationLock(mock, 5, deletedAt, false)
+	mock.ExpectQuery(regexp.QuoteMeta(`
+        UPDATE stations
+        SET deleted_at = NULL,
+            version = version + 1,
+            updated_at = NOW()
+        WHERE id = $1
+        RETURNING org_id, latitude, longitude, address, capacity, created_at, updated_at, version
+    `)).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"org_id", "latitude", "longitude", "address", "capacity",
+			"created_at", "updated_at", "version"}).
+			AddRow(20, nil, nil, "Абая 10, Алматы", 8, deletedAt, deletedAt, 4))
+	mock.ExpectQuery(regexp.QuoteMeta(`
+        UPDATE powerbanks
+        SET deleted_at = NULL,
+            version = version + 1
+        WHERE current_station_id = $1 AND deleted_at = $2
+        RETURNING id
+    `)).
+		WithArgs(5, deletedAt).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectCommit()
+
+	station, err := model.Restore(5, nil)
+	if err != nil {
+		t.Fatalf("unexpected error in Restore: %s", err)
+	}
+	if station.OrgID != 20 || station.Version != 4 || station.DeletedAt != nil {
+		t.Errorf("unexpected restored station: %+v", station)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %s", err)
+	}
+}
+
+// Test Restore: организация станции удалена
+func TestStationModel_Restore_ParentDeleted(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.StationModel{DB: db}
+
+	mock.ExpectBegin()
+	expectRestoreStationLock(mock, 5, time.Now(), true)
+	mock.ExpectRollback()
+
+	if _, err := model.Restore(5, nil); !errors.Is(err, data.ErrParentDeleted) {
+		t.Errorf("expected error %q, got %v", data.ErrParentDeleted, err)
 	}
-	expected := fmt.Sprintf("no station found with id %d", stationID)
-	if err.Error() != expected {
-		t.Errorf("expected error %q, got %q", expected, err.Error())
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %s", err)
 	}
 }
 
@@ -311,21 +430,355 @@ func TestStationModel_List_Success(t *testing.T) {
 	model := data.StationModel{DB: db}
 	now := time.Now()
 
+	filters := data.Filters{Page: 1, PageSize: 20, Sort: "id", SortSafelist: []string{"id"}}
+
 	mock.ExpectQuery(regexp.QuoteMeta(`
-        SELECT id, org_id, created_at, updated_at
-        FROM stations
-        ORDER BY id
+        SELECT count(*) OVER(), s.id, s.org_id, s.latitude, s.longitude, s.address,
+            s.capacity, s.created_at, s.updated_at, s.version, s.deleted_at,
+            count(p.id) FILTER (WHERE p.status = 'available'),
+            count(p.id) FILTER (WHERE p.status = 'charging'),
+            count(p.id) FILTER (WHERE p.status = 'rented')
+        FROM stations s
+        LEFT JOIN powerbanks p ON p.current_station_id = s.id AND p.deleted_at IS NULL
+        WHERE (s.org_id = $1 OR $1 = 0)
+        AND (s.deleted_at IS NULL OR $2)
+        GROUP BY s.id
+        ORDER BY s.id ASC, s.id ASC
+        LIMIT $3 OFFSET $4
     `)).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "org_id", "created_at", "updated_at"}).
-			AddRow(1, 10, now, now).
-			AddRow(2, 20, now, now))
+		WithArgs(0, false, 20, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "id", "org_id", "latitude", "longitude", "address", "capacity", "created_at", "updated_at", "version", "deleted_at", "available", "charging", "rented"}).
+			AddRow(2, 1, 10, 43.238949, 76.889709, "Абая 10", 8, now, now, 1, nil, 3, 1, 2).
+			AddRow(2, 2, 20, nil, nil, "", 8, now, now, 1, nil, 0, 0, 0))
 
-	stations, err := model.List()
+	stations, _, err := model.List(0, false, filters)
 	if err != nil {
-		t.Errorf("unexpected error in List: %s", err)
+		t.Fatalf("unexpected error in List: %s", err)
 	}
 	if len(stations) != 2 {
-		t.Errorf("expected 2 stations, got %d", len(stations))
+		t.Fatalf("expected 2 stations, got %d", len(stations))
+	}
+	expected := data.StationAvailability{Available: 3, Charging: 1, Rented: 2}
+	if stations[0].Availability == nil || *stations[0].Availability != expected {
+		t.Errorf("expected availability %+v, got %+v", expected, stations[0].Availability)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %s", err)
+	}
+}
+
+func TestStationModel_Availability(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.StationModel{DB: db}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+        SELECT count(*) FILTER (WHERE status = 'available'),
+            count(*) FILTER (WHERE status = 'charging'),
+            count(*) FILTER (WHERE status = 'rented')
+        FROM powerbanks
+        WHERE current_station_id = $1
+    `)).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"available", "charging", "rented"}).AddRow(4, 2, 1))
+
+	availability, err := model.Availability(5)
+	if err != nil {
+		t.Fatalf("unexpected error in Availability: %s", err)
+	}
+
+	expected := data.StationAvailability{Available: 4, Charging: 2, Rented: 1}
+	if *availability != expected {
+		t.Errorf("expected %+v, got %+v", expected, *availability)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %s", err)
+	}
+}
+
+func TestStationModel_Nearby(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.StationModel{DB: db}
+	now := time.Now()
+
+	filters := data.Filters{Page: 1, PageSize: 20, Sort: "distance", SortSafelist: []string{"distance"}}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+        WHERE earth_box(ll_to_earth($1, $2), $3) @> ll_to_earth(s.latitude, s.longitude)
+        AND earth_distance(ll_to_earth(s.latitude, s.longitude), ll_to_earth($1, $2)) <= $3
+        AND s.deleted_at IS NULL
+        GROUP BY s.id
+        ORDER BY distance ASC, s.id ASC
+        LIMIT $4 OFFSET $5
+    `)).
+		WithArgs(43.2389, 76.8897, 1500.0, 20, 0).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"count", "id", "org_id", "latitude", "longitude", "address", "capacity", "created_at", "updated_at", "version",
+			"available", "charging", "rented", "distance",
+		}).
+			AddRow(2, 3, 10, 43.2391, 76.8899, "Абая 12", 8, now, now, 1, 2, 0, 1, 27.4).
+			AddRow(2, 1, 10, 43.2410, 76.8950, "Абая 40", 8, now, now, 1, 0, 3, 0, 480.9))
+
+	stations, metadata, err := model.Nearby(43.2389, 76.8897, 1500, filters)
+	if err != nil {
+		t.Fatalf("unexpected error in Nearby: %s", err)
+	}
+	if len(stations) != 2 || stations[0].ID != 3 {
+		t.Fatalf("expected nearest station 3 first, got %+v", stations)
+	}
+	if stations[0].Distance == nil || *stations[0].Distance != 27.4 {
+		t.Errorf("expected distance 27.4, got %v", stations[0].Distance)
+	}
+	if stations[0].Availability.Available != 2 {
+		t.Errorf("expected 2 available powerbanks, got %d", stations[0].Availability.Available)
+	}
+	if metadata.TotalRecords != 2 {
+		t.Errorf("expected 2 total records, got %d", metadata.TotalRecords)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %s", err)
+	}
+}
+
+func TestValidateStation(t *testing.T) {
+	lat, lon := 43.2389, 76.8897
+	badLat := 91.0
+
+	tests := []struct {
+		name    string
+		station data.Station
+		valid   bool
+	}{
+		{"valid", data.Station{OrgID: 1, Latitude: &lat, Longitude: &lon, Address: "Абая 10", Capacity: 8}, true},
+		{"zero capacity", data.Station{OrgID: 1, Latitude: &lat, Longitude: &lon, Address: "Абая 10"}, false},
+		{"missing coordinates", data.Station{OrgID: 1, Address: "Абая 10"}, false},
+		{"latitude out of range", data.Station{OrgID: 1, Latitude: &badLat, Longitude: &lon, Address: "Абая 10"}, false},
+		{"missing address", data.Station{OrgID: 1, Latitude: &lat, Longitude: &lon}, false},
+	}
+
+	for _, tt := range tests {
+		v := validator.New()
+		data.ValidateStation(v, &tt.station)
+		if v.Valid() != tt.valid {
+			t.Errorf("%s: expected valid=%v, got errors %v", tt.name, tt.valid, v.Errors)
+		}
+	}
+}
+
+// expectHighestSlot ожидает блокировку станции и чтение старшего занятого слота.
+func expectHighestSlot(mock sqlmock.Sqlmock, stationID, highest int) {
+	mock.ExpectQuery(regexp.QuoteMeta(`
+        SELECT (
+            SELECT coalesce(max(slot_number), 0)
+            FROM powerbanks
+            WHERE current_station_id = s.id
+        )
+        FROM stations s
+        WHERE s.id = $1 AND s.deleted_at IS NULL
+        FOR UPDATE
+    `)).
+		WithArgs(stationID).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(highest))
+}
+
+// Test Update: станцию удалили параллельно
+func TestStationModel_Update_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.StationModel{DB: db}
+	station := &data.Station{ID: 5, OrgID: 20, Capacity: 4, Version: 1}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM stations s`)).
+		WithArgs(station.ID).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	err = model.Update(station, nil)
+	if !errors.Is(err, data.ErrRecordNotFound) {
+		t.Errorf("expected error %q, got %v", data.ErrRecordNotFound, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %s", err)
+	}
+}
+
+func TestStationModel_Update_CapacityBelowOccupied(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.StationModel{DB: db}
+	station := &data.Station{ID: 5, OrgID: 20, Capacity: 4}
+
+	mock.ExpectBegin()
+	expectHighestSlot(mock, station.ID, 6)
+	mock.ExpectRollback()
+
+	err = model.Update(station, nil)
+	if !errors.Is(err, data.ErrCapacityBelowOccupied) {
+		t.Errorf("expected error %q, got %v", data.ErrCapacityBelowOccupied, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %s", err)
+	}
+}
+
+func expectInsertStation(mock sqlmock.Sqlmock, s *data.Station) *sqlmock.ExpectedQuery {
+	return mock.ExpectQuery(regexp.QuoteMeta(`
+        INSERT INTO stations (org_id, latitude, longitude, address, capacity)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, created_at, updated_at, version
+    `)).
+		WithArgs(s.OrgID, s.Latitude, s.Longitude, s.Address, s.Capacity)
+}
+
+func importStations() []*data.Station {
+	lat, lon := 43.238949, 76.889709
+	return []*data.Station{
+		{OrgID: 1, Latitude: &lat, Longitude: &lon, Address: "Абая 10, Алматы", Capacity: 8},
+		{OrgID: 2, Latitude: &lat, Longitude: &lon, Address: "Абая 12, Алматы", Capacity: 4},
+	}
+}
+
+// Test Import: все станции вставляются одной транзакцией, записи аудита о них
+// пишутся одним запросом в ней же
+func TestStationModel_Import_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.StationModel{DB: db}
+	stations := importStations()
+	now := time.Now()
+
+	mock.ExpectBegin()
+	expectInsertStation(mock, stations[0]).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version"}).AddRow(5, now, now, 1))
+	expectInsertStation(mock, stations[1]).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version"}).AddRow(6, now, now, 1))
+	expectAudit(mock, "create station 5", "create station 6").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	if err := model.Import(stations, &data.Audit{RequestID: "req-1"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if stations[0].ID != 5 || stations[1].ID != 6 {
+		t.Errorf("expected ids 5 and 6, got %d and %d", stations[0].ID, stations[1].ID)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %s", err)
+	}
+}
+
+// Test Import: ошибка вставки возвращается с позицией станции
+func TestStationModel_Import_InvalidForeignKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.StationModel{DB: db}
+	stations := importStations()
+	now := time.Now()
+
+	mock.ExpectBegin()
+	expectInsertStation(mock, stations[0]).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version"}).AddRow(5, now, now, 1))
+	expectInsertStation(mock, stations[1]).
+		WillReturnError(&pq.Error{Code: "23503"})
+	mock.ExpectRollback()
+
+	err = model.Import(stations, nil)
+
+	var bulkErr *data.BulkError
+	if !errors.As(err, &bulkErr) {
+		t.Fatalf("expected *data.BulkError, got %v", err)
+	}
+	if bulkErr.Index != 1 {
+		t.Errorf("expected failed index 1, got %d", bulkErr.Index)
+	}
+	if !errors.Is(err, data.ErrInvalidForeignKey) {
+		t.Errorf("expected ErrInvalidForeignKey, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %s", err)
+	}
+}
+
+// Test Export: станции передаются по одной, ошибка fn прекращает выборку
+func TestStationModel_Export(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.StationModel{DB: db}
+	now := time.Now()
+
+	query := regexp.QuoteMeta(`
+        SELECT id, org_id, latitude, longitude, address, capacity, created_at, updated_at, version
+        FROM stations
+        WHERE (org_id = $1 OR $1 = 0)
+        AND deleted_at IS NULL
+        ORDER BY id ASC
+    `)
+	columns := []string{"id", "org_id", "latitude", "longitude", "address", "capacity", "created_at", "updated_at", "version"}
+
+	mock.ExpectQuery(query).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(1, 3, 43.2, 76.9, "Абая 10", 8, now, now, 1).
+			AddRow(2, 3, nil, nil, "Абая 12", 4, now, now, 2))
+
+	var ids []int
+	err = model.Export(3, func(s *data.Station) error {
+		ids = append(ids, s.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+		t.Errorf("expected ids [1 2], got %v", ids)
+	}
+
+	stop := errors.New("client gone")
+	mock.ExpectQuery(query).
+		WithArgs(0).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(1, 3, 43.2, 76.9, "Абая 10", 8, now, now, 1).
+			AddRow(2, 3, nil, nil, "Абая 12", 4, now, now, 2))
+
+	calls := 0
+	err = model.Export(0, func(s *data.Station) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Errorf("expected fn error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected export to stop after 1 station, got %d", calls)
 	}
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("unfulfilled expectations: %s", err)
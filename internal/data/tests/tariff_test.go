@@ -0,0 +1,284 @@
+package data_test
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/olzzhas/qrent/internal/data"
+	"github.com/olzzhas/qrent/pkg/validator"
+)
+
+func tariffRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "org_id", "station_id", "currency", "unlock_fee", "period_price",
+		"period_minutes", "free_minutes", "daily_cap", "lost_penalty", "late_fee", "created_at", "updated_at"})
+}
+
+// expectTariffForStation ожидает запрос действующего тарифа станции.
+func expectTariffForStation(mock sqlmock.Sqlmock, stationID int) *sqlmock.ExpectedQuery {
+	return mock.ExpectQuery(regexp.QuoteMeta(`
+		FROM tariffs
+		WHERE station_id = $1
+		OR org_id = (SELECT org_id FROM stations WHERE id = $1)
+		ORDER BY station_id IS NULL
+		LIMIT 1
+	`)).
+		WithArgs(stationID)
+}
+
+func TestTariff_Price(t *testing.T) {
+	dailyCap := int64(1500)
+	tariff := &data.Tariff{
+		UnlockFee:     100,
+		PeriodPrice:   200,
+		PeriodMinutes: 60,
+		FreeMinutes:   5,
+		DailyCap:      &dailyCap,
+	}
+
+	tests := []struct {
+		name     string
+		duration time.Duration
+		want     int64
+	}{
+		{"within free minutes", 5 * time.Minute, 0},
+		{"started minute is billed", 5*time.Minute + time.Second, 300},
+		{"one full period", 65 * time.Minute, 300},
+		{"started period is billed", 66 * time.Minute, 500},
+		{"daily cap", 20 * time.Hour, 1600},
+		{"cap per started day", 25 * time.Hour, 3100},
+		{"negative duration", -time.Minute, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tariff.Price(tt.duration); got != tt.want {
+				t.Errorf("Price(%s) = %d, want %d", tt.duration, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTariff(t *testing.T) {
+	orgID := 1
+	stationID := 2
+	negative := int64(-1)
+
+	tests := []struct {
+		name   string
+		tariff data.Tariff
+		fields []string
+	}{
+		{"valid", data.Tariff{OrgID: &orgID, Currency: "KZT", PeriodMinutes: 60}, nil},
+		{"no owner", data.Tariff{Currency: "KZT", PeriodMinutes: 60}, []string{"tariff"}},
+		{"both owners", data.Tariff{OrgID: &orgID, StationID: &stationID, Currency: "KZT", PeriodMinutes: 60}, []string{"tariff"}},
+		{"bad currency", data.Tariff{OrgID: &orgID, Currency: "kzt", PeriodMinutes: 60}, []string{"currency"}},
+		{"zero period", data.Tariff{OrgID: &orgID, Currency: "KZT"}, []string{"period_minutes"}},
+		{"negative cap", data.Tariff{OrgID: &orgID, Currency: "KZT", PeriodMinutes: 60, DailyCap: &negative}, []string{"daily_cap"}},
+		{"negative late fee", data.Tariff{OrgID: &orgID, Currency: "KZT", PeriodMinutes: 60, LateFee: -1}, []string{"late_fee"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			data.ValidateTariff(v, &tt.tariff)
+			if len(v.Errors) != len(tt.fields) {
+				t.Fatalf("expected errors for %v, got %v", tt.fields, v.Errors)
+			}
+			for _, field := range tt.fields {
+				if _, ok := v.Errors[field]; !ok {
+					t.Errorf("expected error for %q, got %v", field, v.Errors)
+				}
+			}
+		})
+	}
+}
+
+func TestTariffModel_GetForStation_PrefersStation(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.TariffModel{DB: db}
+	now := time.Now()
+
+	expectTariffForStation(mock, 10).
+		WillReturnRows(tariffRows().AddRow(3, nil, 10, "KZT", 0, 150, 30, 0, 1200, 8000, 0, now, now))
+
+	tariff, err := model.GetForStation(10)
+	if err != nil {
+		t.Fatalf("unexpected error in GetForStation: %s", err)
+	}
+	if tariff.StationID == nil || *tariff.StationID != 10 || tariff.OrgID != nil ||
+		tariff.DailyCap == nil || *tariff.DailyCap != 1200 {
+		t.Errorf("unexpected tariff: %+v", tariff)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestTariffModel_GetForStation_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.TariffModel{DB: db}
+
+	expectTariffForStation(mock, 10).WillReturnRows(tariffRows())
+
+	_, err = model.GetForStation(10)
+	if !errors.Is(err, data.ErrRecordNotFound) {
+		t.Errorf("expected error %q, got %v", data.ErrRecordNotFound, err)
+	}
+}
+
+func TestRentalModel_Finish_NoTariff(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.RentalModel{DB: db}
+	now := time.Now()
+	rental := &data.Rental{ID: 1, PowerbankID: 5, StartStationID: 10, StartedAt: now.Add(-time.Hour)}
+
+	expectActiveRental(mock, 1)
+	expectRentedPowerbank(mock, 5, 10)
+	expectClaimSlot(mock, 20, 5, 4)
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE powerbanks`)).
+		WithArgs(20, 1, data.PowerbankStatusCharging, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectPowerbankEvent(mock)
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE rentals`)).
+		WithArgs(20, 1, data.RentalStatusFinished, sqlmock.AnyArg(), nil, nil, nil, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"end_station_id", "end_slot_number", "status", "ended_at", "updated_at"}).
+			AddRow(20, 1, data.RentalStatusFinished, now, now))
+	mock.ExpectCommit()
+
+	if err := model.Finish(rental, 20, nil); err != nil {
+		t.Fatalf("unexpected error in Finish: %s", err)
+	}
+	if rental.Price != nil || rental.TariffID != nil {
+		t.Errorf("expected no price without a tariff, got %+v", rental)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// Возврат просроченной аренды добавляет к цене по времени плату за просрочку.
+func TestRentalModel_Finish_OverdueAddsLateFee(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.RentalModel{DB: db}
+	now := time.Now()
+	rental := &data.Rental{ID: 1, PowerbankID: 5, StartStationID: 10, StartedAt: now.Add(-95 * time.Minute)}
+
+	expectLockedRentalWithTariff(mock, 1, data.RentalStatusOverdue, &data.Tariff{ID: 7, Currency: "KZT",
+		UnlockFee: 100, PeriodPrice: 200, PeriodMinutes: 60, FreeMinutes: 10, LostPenalty: 5000, LateFee: 1000})
+	expectRentedPowerbank(mock, 5, 10)
+	expectClaimSlot(mock, 20, 5, 4)
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE powerbanks`)).
+		WithArgs(20, 1, data.PowerbankStatusCharging, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectPowerbankEvent(mock)
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE rentals`)).
+		WithArgs(20, 1, data.RentalStatusFinished, sqlmock.AnyArg(), 7, int64(1500), "KZT", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"end_station_id", "end_slot_number", "status", "ended_at", "updated_at"}).
+			AddRow(20, 1, data.RentalStatusFinished, now, now))
+	mock.ExpectCommit()
+
+	if err := model.Finish(rental, 20, nil); err != nil {
+		t.Fatalf("unexpected error in Finish: %s", err)
+	}
+	if rental.Price == nil || *rental.Price != 1500 {
+		t.Errorf("expected price 1500 with the late fee, got %v", rental.Price)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// Замена существующего тарифа пишется в аудит как правка, новый тариф — как
+// создание.
+func TestTariffModel_Upsert_AuditAction(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous *sqlmock.Rows
+		expected string
+	}{
+		{"new tariff", tariffRows(), "create tariff 3"},
+		{"replaced tariff", tariffRows().AddRow(3, 1, nil, "KZT", 0, 100, 60, 0, nil, 5000, 0, time.Now(), time.Now()), "update tariff 3"},
+	}
+
+	for _, tt := range tests {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("unexpected error when creating sqlmock: %s", err)
+		}
+
+		orgID := 1
+		tariff := &data.Tariff{OrgID: &orgID, Currency: "KZT", PeriodPrice: 150, PeriodMinutes: 60, LostPenalty: 5000}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`
+		FROM tariffs
+		WHERE org_id = $1
+		FOR UPDATE
+	`)).
+			WithArgs(&orgID).
+			WillReturnRows(tt.previous)
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO tariffs`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).AddRow(3, time.Now(), time.Now()))
+		expectAudit(mock, tt.expected).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		if err := (data.TariffModel{DB: db}).Upsert(tariff, &data.Audit{}); err != nil {
+			t.Errorf("%s: unexpected error in Upsert: %s", tt.name, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("%s: there were unfulfilled expectations: %s", tt.name, err)
+		}
+		db.Close()
+	}
+}
+
+func TestTariffModel_DeleteForStation(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.TariffModel{DB: db}
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		DELETE FROM tariffs
+		WHERE station_id = $1
+		RETURNING id`)).
+		WithArgs(10).
+		WillReturnRows(tariffRows().AddRow(3, nil, 10, "KZT", 0, 150, 30, 0, nil, 8000, 0, now, now))
+	expectAudit(mock, "delete tariff 3").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := model.DeleteForStation(10, &data.Audit{}); err != nil {
+		t.Fatalf("unexpected error in DeleteForStation: %s", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,218 @@
+package data_test
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/olzzhas/qrent/internal/data"
+)
+
+func TestUserPassword_SetAndMatches(t *testing.T) {
+	var user data.User
+	if err := user.Password.Set("pa55word123"); err != nil {
+		t.Fatalf("unexpected error in Set: %s", err)
+	}
+
+	match, err := user.Password.Matches("pa55word123")
+	if err != nil || !match {
+		t.Errorf("expected password to match, got match=%v err=%v", match, err)
+	}
+
+	match, err = user.Password.Matches("wrong-password")
+	if err != nil || match {
+		t.Errorf("expected password not to match, got match=%v err=%v", match, err)
+	}
+}
+
+func TestUserModel_Insert_DuplicateEmail(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.UserModel{DB: db}
+	user := &data.User{Name: "Alice", Email: "alice@example.com"}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		INSERT INTO users (name, email, password_hash, activated, role, org_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at, version
+	`)).
+		WillReturnError(&pq.Error{Code: "23505"})
+
+	err = model.Insert(user)
+	if !errors.Is(err, data.ErrDuplicateEmail) {
+		t.Errorf("expected error %q, got %v", data.ErrDuplicateEmail, err)
+	}
+}
+
+// expectRegister ожидает вставку пользователя, выдачу прав и токена активации
+// в одной транзакции.
+func expectRegister(mock sqlmock.Sqlmock, userID int) {
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO users`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version"}).AddRow(userID, now, now, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM users_permissions`)).
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO users_permissions`)).
+		WithArgs(userID, pq.Array([]string(data.RoleRenter.Permissions()))).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO tokens`)).
+		WithArgs(sqlmock.AnyArg(), userID, sqlmock.AnyArg(), data.ScopeActivation).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func TestUserModel_Register_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.UserModel{DB: db}
+	user := &data.User{Name: "Alice", Email: "alice@example.com", Role: data.RoleRenter}
+
+	expectRegister(mock, 7)
+	mock.ExpectCommit()
+
+	var delivered *data.Token
+	token, err := model.Register(user, time.Hour, func(token *data.Token) error {
+		delivered = token
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error in Register: %s", err)
+	}
+	if user.ID != 7 || token.UserID != 7 || delivered != token {
+		t.Errorf("unexpected registration: user %+v, token %+v, delivered %+v", user, token, delivered)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// Недоставленный токен откатывает регистрацию, и почта остаётся свободной.
+func TestUserModel_Register_DeliveryFailureRollsBack(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.UserModel{DB: db}
+	user := &data.User{Name: "Alice", Email: "alice@example.com", Role: data.RoleRenter}
+	deliveryErr := errors.New("broker is down")
+
+	expectRegister(mock, 7)
+	mock.ExpectRollback()
+
+	_, err = model.Register(user, time.Hour, func(*data.Token) error {
+		return deliveryErr
+	})
+	if !errors.Is(err, deliveryErr) {
+		t.Errorf("expected error %q, got %v", deliveryErr, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUserModel_GetByEmail_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.UserModel{DB: db}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`
+		SELECT id, name, email, password_hash, activated, role, org_id, version, created_at, updated_at
+		FROM users
+		WHERE email = $1
+	`)).
+		WithArgs("nobody@example.com").
+		WillReturnError(sql.ErrNoRows)
+
+	_, err = model.GetByEmail("nobody@example.com")
+	if !errors.Is(err, data.ErrRecordNotFound) {
+		t.Errorf("expected error %q, got %v", data.ErrRecordNotFound, err)
+	}
+}
+
+func TestTokenModel_New(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.TokenModel{DB: db}
+
+	mock.ExpectExec(regexp.QuoteMeta(`
+		INSERT INTO tokens (hash, user_id, expiry, scope)
+		VALUES ($1, $2, $3, $4)
+	`)).
+		WithArgs(sqlmock.AnyArg(), 7, sqlmock.AnyArg(), data.ScopeAuthentication).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	token, err := model.New(7, time.Hour, data.ScopeAuthentication)
+	if err != nil {
+		t.Fatalf("unexpected error in New: %s", err)
+	}
+	if len(token.Plaintext) != 26 {
+		t.Errorf("expected 26-byte plaintext token, got %d bytes", len(token.Plaintext))
+	}
+	if len(token.Hash) != 32 {
+		t.Errorf("expected 32-byte token hash, got %d bytes", len(token.Hash))
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// Смена роли, замена прав и запись аудита идут одной транзакцией.
+func TestUserModel_UpdateRole(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when creating sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	model := data.UserModel{DB: db}
+	orgID := 4
+	user := &data.User{ID: 7, Name: "Alice", Email: "alice@example.com", Role: data.RoleOperator, OrgID: &orgID, Version: 2}
+	before := *user
+	before.Role, before.OrgID = data.RoleRenter, nil
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE users`)).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "updated_at"}).AddRow(3, time.Now()))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM users_permissions`)).
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO users_permissions`)).
+		WithArgs(7, pq.Array(data.RoleOperator.Permissions())).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAudit(mock, "update user 7").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := model.UpdateRole(user, &data.Audit{Before: &before}); err != nil {
+		t.Fatalf("unexpected error in UpdateRole: %s", err)
+	}
+	if user.Version != 3 {
+		t.Errorf("expected version 3, got %d", user.Version)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,370 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"github.com/lib/pq"
+	"github.com/olzzhas/qrent/pkg/validator"
+	"golang.org/x/crypto/bcrypt"
+	"time"
+)
+
+var (
+	ErrDuplicateEmail = errors.New("duplicate email")
+)
+
+// AnonymousUser представляет неаутентифицированного клиента.
+var AnonymousUser = &User{}
+
+type User struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Password  password  `json:"-"`
+	Activated bool      `json:"activated"`
+	Role      Role      `json:"role"`
+	OrgID     *int      `json:"org_id"`
+	Version   int       `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (u *User) IsAnonymous() bool {
+	return u == AnonymousUser
+}
+
+// CanManageOrganization сообщает, может ли пользователь управлять станциями и
+// повербанками организации orgID: администратор платформы может всё, оператор
+// только в пределах своей организации.
+func (u *User) CanManageOrganization(orgID int) bool {
+	switch u.Role {
+	case RoleAdmin:
+		return true
+	case RoleOperator:
+		return u.OrgID != nil && *u.OrgID == orgID
+	default:
+		return false
+	}
+}
+
+type password struct {
+	plaintext *string
+	hash      []byte
+}
+
+func (p *password) Set(plaintextPassword string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(plaintextPassword), 12)
+	if err != nil {
+		return err
+	}
+
+	p.plaintext = &plaintextPassword
+	p.hash = hash
+
+	return nil
+}
+
+func (p *password) Matches(plaintextPassword string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword(p.hash, []byte(plaintextPassword))
+	if err != nil {
+		switch {
+		case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+type UserModel struct {
+	DB    *sql.DB
+	Redis *redis.Client
+}
+
+func ValidateEmail(v *validator.Validator, email string) {
+	v.Check(email != "", "email", "must be provided")
+	v.Check(validator.Matches(email, validator.EmailRX), "email", "must be a valid email address")
+}
+
+func ValidatePasswordPlaintext(v *validator.Validator, password string) {
+	v.Check(password != "", "password", "must be provided")
+	v.Check(len(password) >= 8, "password", "must be at least 8 bytes long")
+	v.Check(len(password) <= 72, "password", "must not be more than 72 bytes long")
+}
+
+func ValidateUser(v *validator.Validator, user *User) {
+	v.Check(user.Name != "", "name", "must be provided")
+	v.Check(len(user.Name) <= 500, "name", "must not be more than 500 bytes long")
+
+	ValidateEmail(v, user.Email)
+
+	if user.Password.plaintext != nil {
+		ValidatePasswordPlaintext(v, *user.Password.plaintext)
+	}
+
+	v.Check(user.Role.IsValid(), "role", "must be one of: admin, operator, renter")
+	if user.Role == RoleOperator {
+		v.Check(user.OrgID != nil && *user.OrgID > 0, "org_id", "must be provided for operator")
+	}
+
+	if user.Password.hash == nil {
+		panic("missing password hash for user")
+	}
+}
+
+func (m UserModel) Insert(user *User) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return insertUser(ctx, m.DB, user)
+}
+
+// Register создаёт неактивированного пользователя, выдаёт ему права его роли
+// и токен активации со сроком activationTTL одной транзакцией. deliver
+// получает токен до фиксации: если доставить его не удалось, пользователь не
+// создаётся, и с той же почтой можно зарегистрироваться снова.
+func (m UserModel) Register(user *User, activationTTL time.Duration, deliver func(*Token) error) (*Token, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := insertUser(ctx, tx, user); err != nil {
+		return nil, err
+	}
+
+	if err := setUserPermissions(ctx, tx, user.ID, user.Role.Permissions()); err != nil {
+		return nil, err
+	}
+
+	token, err := generateToken(user.ID, activationTTL, ScopeActivation)
+	if err != nil {
+		return nil, err
+	}
+	if err := insertToken(ctx, tx, token); err != nil {
+		return nil, err
+	}
+
+	if err := deliver(token); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+func insertUser(ctx context.Context, q querier, user *User) error {
+	query := `
+		INSERT INTO users (name, email, password_hash, activated, role, org_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at, version
+	`
+	args := []any{user.Name, user.Email, user.Password.hash, user.Activated, user.Role, user.OrgID}
+
+	err := q.QueryRowContext(ctx, query, args...).
+		Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt, &user.Version)
+	if err != nil {
+		var pgerr *pq.Error
+		if errors.As(err, &pgerr) && pgerr.Code == "23505" {
+			return ErrDuplicateEmail
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (m UserModel) Get(id int) (*User, error) {
+	query := `
+		SELECT id, name, email, password_hash, activated, role, org_id, version, created_at, updated_at
+		FROM users
+		WHERE id = $1
+	`
+	var user User
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&user.ID,
+		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Activated,
+		&user.Role,
+		&user.OrgID,
+		&user.Version,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (m UserModel) GetByEmail(email string) (*User, error) {
+	query := `
+		SELECT id, name, email, password_hash, activated, role, org_id, version, created_at, updated_at
+		FROM users
+		WHERE email = $1
+	`
+	var user User
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, email).Scan(
+		&user.ID,
+		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Activated,
+		&user.Role,
+		&user.OrgID,
+		&user.Version,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (m UserModel) Update(user *User) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return updateUser(ctx, m.DB, user)
+}
+
+// UpdateRole сохраняет пользователя со сменой роли и заменяет его права
+// правами новой роли одной транзакцией.
+func (m UserModel) UpdateRole(user *User, audit *Audit) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := updateUser(ctx, tx, user); err != nil {
+		return err
+	}
+
+	if err := setUserPermissions(ctx, tx, user.ID, user.Role.Permissions()); err != nil {
+		return err
+	}
+
+	if err := audit.record(ctx, tx, AuditActionUpdate, AuditResourceUser, user.ID, audit.before(), user); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func updateUser(ctx context.Context, q queryRower, user *User) error {
+	query := `
+		UPDATE users
+		SET name = $1,
+			email = $2,
+			password_hash = $3,
+			activated = $4,
+			role = $5,
+			org_id = $6,
+			version = version + 1,
+			updated_at = NOW()
+		WHERE id = $7 AND version = $8
+		RETURNING version, updated_at
+	`
+	args := []any{
+		user.Name,
+		user.Email,
+		user.Password.hash,
+		user.Activated,
+		user.Role,
+		user.OrgID,
+		user.ID,
+		user.Version,
+	}
+
+	err := q.QueryRowContext(ctx, query, args...).Scan(&user.Version, &user.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		var pgerr *pq.Error
+		if errors.As(err, &pgerr) && pgerr.Code == "23505" {
+			return ErrDuplicateEmail
+		}
+		if errors.As(err, &pgerr) && pgerr.Code == "23503" {
+			return ErrInvalidForeignKey
+		}
+		return err
+	}
+
+	return nil
+}
+
+// GetForToken возвращает владельца действующего токена с заданной областью.
+func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	query := `
+		SELECT users.id, users.name, users.email, users.password_hash, users.activated,
+			users.role, users.org_id, users.version, users.created_at, users.updated_at
+		FROM users
+		INNER JOIN tokens
+		ON users.id = tokens.user_id
+		WHERE tokens.hash = $1
+		AND tokens.scope = $2
+		AND tokens.expiry > $3
+	`
+	args := []any{tokenHash[:], tokenScope, time.Now()}
+
+	var user User
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(
+		&user.ID,
+		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Activated,
+		&user.Role,
+		&user.OrgID,
+		&user.Version,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
@@ -0,0 +1,125 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Fake — провайдер в памяти процесса для разработки и тестов. Он ведёт те же
+// остатки, что и настоящий: списать можно не больше замороженного, вернуть —
+// не больше списанного. Состояние теряется при перезапуске.
+type Fake struct {
+	// DeclineOver — авторизации на сумму больше этой отклоняются с
+	// ErrDeclined; 0 — без ограничения.
+	DeclineOver int64
+
+	mu      sync.Mutex
+	seq     int
+	auths   map[string]*fakeAuth
+	results map[string]fakeResult
+}
+
+type fakeAuth struct {
+	amount   int64
+	captured int64
+	refunded int64
+	voided   bool
+}
+
+type fakeResult struct {
+	ref string
+	err error
+}
+
+func NewFake() *Fake {
+	return &Fake{
+		auths:   make(map[string]*fakeAuth),
+		results: make(map[string]fakeResult),
+	}
+}
+
+func (f *Fake) Name() string {
+	return "fake"
+}
+
+func (f *Fake) Authorize(_ context.Context, key string, amount int64, currency string) (string, error) {
+	return f.once(key, func() (string, error) {
+		if f.DeclineOver > 0 && amount > f.DeclineOver {
+			return "", ErrDeclined
+		}
+
+		ref := f.nextRef("auth")
+		f.auths[ref] = &fakeAuth{amount: amount}
+		return ref, nil
+	})
+}
+
+func (f *Fake) Capture(_ context.Context, key, ref string, amount int64) (string, error) {
+	return f.once(key, func() (string, error) {
+		auth, ok := f.auths[ref]
+		switch {
+		case !ok:
+			return "", ErrUnknownReference
+		case auth.voided || auth.captured > 0:
+			return "", ErrInvalidState
+		case amount > auth.amount:
+			return "", ErrAmountExceeded
+		}
+
+		auth.captured = amount
+		return f.nextRef("cap"), nil
+	})
+}
+
+func (f *Fake) Refund(_ context.Context, key, ref string, amount int64) (string, error) {
+	return f.once(key, func() (string, error) {
+		auth, ok := f.auths[ref]
+		switch {
+		case !ok:
+			return "", ErrUnknownReference
+		case auth.captured == 0:
+			return "", ErrInvalidState
+		case auth.refunded+amount > auth.captured:
+			return "", ErrAmountExceeded
+		}
+
+		auth.refunded += amount
+		return f.nextRef("ref"), nil
+	})
+}
+
+func (f *Fake) Void(_ context.Context, key, ref string) (string, error) {
+	return f.once(key, func() (string, error) {
+		auth, ok := f.auths[ref]
+		switch {
+		case !ok:
+			return "", ErrUnknownReference
+		case auth.captured > 0:
+			return "", ErrInvalidState
+		}
+
+		auth.voided = true
+		return f.nextRef("void"), nil
+	})
+}
+
+// once выполняет операцию один раз для ключа key и запоминает её результат,
+// включая ошибку.
+func (f *Fake) once(key string, op func() (string, error)) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if res, ok := f.results[key]; ok {
+		return res.ref, res.err
+	}
+
+	ref, err := op()
+	f.results[key] = fakeResult{ref: ref, err: err}
+	return ref, err
+}
+
+func (f *Fake) nextRef(prefix string) string {
+	f.seq++
+	return fmt.Sprintf("fake_%s_%d", prefix, f.seq)
+}
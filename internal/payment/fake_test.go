@@ -0,0 +1,69 @@
+package payment_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/olzzhas/qrent/internal/payment"
+)
+
+func TestFake_IdempotentCapture(t *testing.T) {
+	ctx := context.Background()
+	f := payment.NewFake()
+
+	auth, err := f.Authorize(ctx, "auth-1", 1000, "KZT")
+	if err != nil {
+		t.Fatalf("unexpected error in Authorize: %s", err)
+	}
+
+	first, err := f.Capture(ctx, "cap-1", auth, 700)
+	if err != nil {
+		t.Fatalf("unexpected error in Capture: %s", err)
+	}
+	retry, err := f.Capture(ctx, "cap-1", auth, 700)
+	if err != nil || retry != first {
+		t.Errorf("expected retry to return %q, got %q, %v", first, retry, err)
+	}
+
+	if _, err := f.Capture(ctx, "cap-2", auth, 700); !errors.Is(err, payment.ErrInvalidState) {
+		t.Errorf("expected error %q for a second capture, got %v", payment.ErrInvalidState, err)
+	}
+}
+
+func TestFake_Limits(t *testing.T) {
+	ctx := context.Background()
+	f := payment.NewFake()
+	f.DeclineOver = 5000
+
+	if _, err := f.Authorize(ctx, "auth-big", 6000, "KZT"); !errors.Is(err, payment.ErrDeclined) {
+		t.Errorf("expected error %q, got %v", payment.ErrDeclined, err)
+	}
+
+	auth, err := f.Authorize(ctx, "auth-1", 1000, "KZT")
+	if err != nil {
+		t.Fatalf("unexpected error in Authorize: %s", err)
+	}
+	if _, err := f.Capture(ctx, "cap-over", auth, 1001); !errors.Is(err, payment.ErrAmountExceeded) {
+		t.Errorf("expected error %q, got %v", payment.ErrAmountExceeded, err)
+	}
+	if _, err := f.Refund(ctx, "ref-early", auth, 100); !errors.Is(err, payment.ErrInvalidState) {
+		t.Errorf("expected error %q before capture, got %v", payment.ErrInvalidState, err)
+	}
+
+	if _, err := f.Capture(ctx, "cap-1", auth, 800); err != nil {
+		t.Fatalf("unexpected error in Capture: %s", err)
+	}
+	if _, err := f.Refund(ctx, "ref-1", auth, 500); err != nil {
+		t.Fatalf("unexpected error in Refund: %s", err)
+	}
+	if _, err := f.Refund(ctx, "ref-2", auth, 301); !errors.Is(err, payment.ErrAmountExceeded) {
+		t.Errorf("expected error %q, got %v", payment.ErrAmountExceeded, err)
+	}
+	if _, err := f.Void(ctx, "void-1", auth); !errors.Is(err, payment.ErrInvalidState) {
+		t.Errorf("expected error %q for void after capture, got %v", payment.ErrInvalidState, err)
+	}
+	if _, err := f.Void(ctx, "void-2", "missing"); !errors.Is(err, payment.ErrUnknownReference) {
+		t.Errorf("expected error %q, got %v", payment.ErrUnknownReference, err)
+	}
+}
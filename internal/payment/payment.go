@@ -0,0 +1,31 @@
+// Package payment описывает платёжного провайдера, через которого списываются
+// деньги за аренду. Провайдер замораживает сумму (Authorize), списывает её
+// целиком или частично (Capture), возвращает списанное (Refund) или снимает
+// заморозку (Void).
+//
+// Каждая операция принимает ключ идемпотентности: повтор вызова с тем же
+// ключом возвращает результат первого вызова и не трогает деньги повторно.
+package payment
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrDeclined         = errors.New("payment declined")
+	ErrUnknownReference = errors.New("unknown payment reference")
+	ErrAmountExceeded   = errors.New("amount exceeds the available amount")
+	ErrInvalidState     = errors.New("operation is not allowed in the current payment state")
+)
+
+// Provider — платёжный провайдер. Все суммы — в минимальных единицах валюты.
+// ref — идентификатор авторизации, который вернул Authorize; остальные методы
+// возвращают идентификатор своей операции у провайдера.
+type Provider interface {
+	Name() string
+	Authorize(ctx context.Context, key string, amount int64, currency string) (string, error)
+	Capture(ctx context.Context, key, ref string, amount int64) (string, error)
+	Refund(ctx context.Context, key, ref string, amount int64) (string, error)
+	Void(ctx context.Context, key, ref string) (string, error)
+}
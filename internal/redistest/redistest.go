@@ -0,0 +1,226 @@
+// Package redistest поднимает в процессе теста минимальный сервер с
+// протоколом Redis (RESP), чтобы проверять код, работающий через go-redis,
+// без внешнего Redis. Поддерживаются только строковые команды, которые
+// использует qrent: PING, GET, SET с EX/PX/NX, DEL, INCR и PTTL. Lua-скрипты
+// сервер не выполняет.
+package redistest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type entry struct {
+	value   string
+	expires time.Time
+}
+
+type server struct {
+	mu     sync.Mutex
+	values map[string]entry
+}
+
+// NewClient запускает сервер и возвращает подключённый к нему клиент. Сервер
+// и клиент закрываются по завершении теста.
+func NewClient(t testing.TB) *redis.Client {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to start fake redis: %s", err)
+	}
+
+	s := &server{values: make(map[string]entry)}
+	go s.serve(ln)
+
+	client := redis.NewClient(&redis.Options{Addr: ln.Addr().String()})
+	t.Cleanup(func() {
+		client.Close()
+		ln.Close()
+	})
+
+	return client
+}
+
+func (s *server) serve(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		if _, err := io.WriteString(conn, s.exec(args)); err != nil {
+			return
+		}
+	}
+}
+
+// readCommand читает команду клиента — массив bulk-строк.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	n, err := readHeader(r, '*')
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		size, err := readHeader(r, '$')
+		if err != nil {
+			return nil, err
+		}
+
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+
+	return args, nil
+}
+
+func readHeader(r *bufio.Reader, prefix byte) (int, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return 0, err
+	}
+
+	line = strings.TrimSuffix(line, "\r\n")
+	if len(line) < 2 || line[0] != prefix {
+		return 0, fmt.Errorf("unexpected line %q", line)
+	}
+
+	return strconv.Atoi(line[1:])
+}
+
+func (s *server) exec(args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(args) == 0 {
+		return errorReply("ERR empty command")
+	}
+
+	switch cmd := strings.ToUpper(args[0]); {
+	case cmd == "PING":
+		return "+PONG\r\n"
+	case cmd == "GET" && len(args) == 2:
+		e, ok := s.get(args[1])
+		if !ok {
+			return "$-1\r\n"
+		}
+		return bulkReply(e.value)
+	case cmd == "SET" && len(args) >= 3:
+		return s.set(args[1], args[2], args[3:])
+	case cmd == "DEL" && len(args) >= 2:
+		deleted := 0
+		for _, key := range args[1:] {
+			if _, ok := s.get(key); ok {
+				delete(s.values, key)
+				deleted++
+			}
+		}
+		return intReply(int64(deleted))
+	case cmd == "INCR" && len(args) == 2:
+		e, _ := s.get(args[1])
+		n := int64(0)
+		if e.value != "" {
+			var err error
+			if n, err = strconv.ParseInt(e.value, 10, 64); err != nil {
+				return errorReply("ERR value is not an integer or out of range")
+			}
+		}
+		n++
+		s.values[args[1]] = entry{value: strconv.FormatInt(n, 10), expires: e.expires}
+		return intReply(n)
+	case cmd == "PTTL" && len(args) == 2:
+		e, ok := s.get(args[1])
+		switch {
+		case !ok:
+			return intReply(-2)
+		case e.expires.IsZero():
+			return intReply(-1)
+		}
+		return intReply(time.Until(e.expires).Milliseconds())
+	default:
+		return errorReply(fmt.Sprintf("ERR unsupported command %q", args[0]))
+	}
+}
+
+func (s *server) set(key, value string, options []string) string {
+	var expires time.Time
+	nx := false
+
+	for i := 0; i < len(options); i++ {
+		switch option := strings.ToUpper(options[i]); option {
+		case "NX":
+			nx = true
+		case "EX", "PX":
+			if i+1 == len(options) {
+				return errorReply("ERR syntax error")
+			}
+			i++
+			n, err := strconv.ParseInt(options[i], 10, 64)
+			if err != nil || n <= 0 {
+				return errorReply("ERR invalid expire time in 'set' command")
+			}
+			unit := time.Second
+			if option == "PX" {
+				unit = time.Millisecond
+			}
+			expires = time.Now().Add(time.Duration(n) * unit)
+		default:
+			return errorReply("ERR syntax error")
+		}
+	}
+
+	if _, ok := s.get(key); ok && nx {
+		return "$-1\r\n"
+	}
+
+	s.values[key] = entry{value: value, expires: expires}
+	return "+OK\r\n"
+}
+
+// get возвращает живое значение ключа, удаляя просроченное.
+func (s *server) get(key string) (entry, bool) {
+	e, ok := s.values[key]
+	if ok && !e.expires.IsZero() && !time.Now().Before(e.expires) {
+		delete(s.values, key)
+		return entry{}, false
+	}
+	return e, ok
+}
+
+func bulkReply(value string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+}
+
+func intReply(n int64) string {
+	return fmt.Sprintf(":%d\r\n", n)
+}
+
+func errorReply(message string) string {
+	return "-" + message + "\r\n"
+}
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_rentals_active_powerbank;
+
+DROP INDEX IF EXISTS idx_rentals_powerbank_id;
+
+DROP TRIGGER IF EXISTS rentals_update_timestamp ON rentals;
+
+DROP TABLE IF EXISTS rentals;
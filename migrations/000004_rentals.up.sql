@@ -0,0 +1,38 @@
+CREATE TABLE IF NOT EXISTS rentals (
+    id SERIAL PRIMARY KEY,
+    powerbank_id INTEGER NOT NULL,
+    start_station_id INTEGER NOT NULL,
+    end_station_id INTEGER,
+    status TEXT NOT NULL DEFAULT 'active',
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ended_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT fk_powerbanks
+        FOREIGN KEY (powerbank_id)
+        REFERENCES powerbanks(id)
+        ON DELETE CASCADE,
+    CONSTRAINT fk_start_stations
+        FOREIGN KEY (start_station_id)
+        REFERENCES stations(id)
+        ON DELETE CASCADE,
+    CONSTRAINT fk_end_stations
+        FOREIGN KEY (end_station_id)
+        REFERENCES stations(id)
+        ON DELETE SET NULL,
+    CONSTRAINT chk_rental_status
+        CHECK (status IN ('active', 'finished'))
+);
+
+CREATE TRIGGER rentals_update_timestamp
+    BEFORE UPDATE ON rentals
+    FOR EACH ROW
+    EXECUTE PROCEDURE update_timestamp();
+
+CREATE INDEX IF NOT EXISTS idx_rentals_powerbank_id
+    ON rentals(powerbank_id);
+
+-- Один повербанк не может находиться в двух активных арендах одновременно.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_rentals_active_powerbank
+    ON rentals(powerbank_id)
+    WHERE status = 'active';
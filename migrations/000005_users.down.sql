@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS idx_rentals_user_id;
+
+ALTER TABLE rentals
+    DROP COLUMN IF EXISTS user_id;
+
+DROP INDEX IF EXISTS idx_tokens_user_id;
+
+DROP TABLE IF EXISTS tokens;
+
+DROP TRIGGER IF EXISTS users_update_timestamp ON users;
+
+DROP TABLE IF EXISTS users;
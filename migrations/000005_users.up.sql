@@ -0,0 +1,39 @@
+CREATE EXTENSION IF NOT EXISTS citext;
+
+CREATE TABLE IF NOT EXISTS users (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    email CITEXT UNIQUE NOT NULL,
+    password_hash BYTEA NOT NULL,
+    activated BOOLEAN NOT NULL DEFAULT FALSE,
+    version INTEGER NOT NULL DEFAULT 1,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TRIGGER users_update_timestamp
+    BEFORE UPDATE ON users
+    FOR EACH ROW
+    EXECUTE PROCEDURE update_timestamp();
+
+CREATE TABLE IF NOT EXISTS tokens (
+    hash BYTEA PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    expiry TIMESTAMPTZ NOT NULL,
+    scope TEXT NOT NULL,
+    CONSTRAINT fk_users
+        FOREIGN KEY (user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_tokens_user_id
+    ON tokens(user_id);
+
+ALTER TABLE rentals
+    ADD COLUMN IF NOT EXISTS user_id INTEGER
+        REFERENCES users(id)
+        ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_rentals_user_id
+    ON rentals(user_id);
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS users_permissions;
+
+DROP TABLE IF EXISTS permissions;
+
+DROP INDEX IF EXISTS idx_users_org_id;
+
+ALTER TABLE users
+    DROP CONSTRAINT IF EXISTS chk_role,
+    DROP COLUMN IF EXISTS org_id,
+    DROP COLUMN IF EXISTS role;
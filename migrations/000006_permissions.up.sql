@@ -0,0 +1,34 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'renter',
+    ADD COLUMN IF NOT EXISTS org_id INTEGER
+        REFERENCES organizations(id)
+        ON DELETE SET NULL,
+    ADD CONSTRAINT chk_role
+        CHECK (role IN ('admin', 'operator', 'renter'));
+
+CREATE INDEX IF NOT EXISTS idx_users_org_id
+    ON users(org_id);
+
+CREATE TABLE IF NOT EXISTS permissions (
+    id SERIAL PRIMARY KEY,
+    code TEXT UNIQUE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS users_permissions (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    permission_id INTEGER NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, permission_id)
+);
+
+INSERT INTO permissions (code)
+VALUES
+    ('organizations:write'),
+    ('stations:write'),
+    ('powerbanks:write'),
+    ('rentals:create'),
+    ('users:write')
+ON CONFLICT (code) DO NOTHING;
+
+-- Первого администратора назначают вручную:
+-- UPDATE users SET role = 'admin' WHERE email = '...';
+-- INSERT INTO users_permissions SELECT u.id, p.id FROM users u, permissions p WHERE u.email = '...';
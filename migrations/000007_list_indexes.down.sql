@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_powerbanks_status;
+
+DROP INDEX IF EXISTS idx_organizations_name_fts;
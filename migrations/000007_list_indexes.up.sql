@@ -0,0 +1,5 @@
+CREATE INDEX IF NOT EXISTS idx_organizations_name_fts
+    ON organizations USING GIN (to_tsvector('simple', name));
+
+CREATE INDEX IF NOT EXISTS idx_powerbanks_status
+    ON powerbanks(status);
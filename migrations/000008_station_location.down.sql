@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_stations_location;
+
+ALTER TABLE stations
+    DROP CONSTRAINT IF EXISTS chk_longitude,
+    DROP CONSTRAINT IF EXISTS chk_latitude,
+    DROP COLUMN IF EXISTS address,
+    DROP COLUMN IF EXISTS longitude,
+    DROP COLUMN IF EXISTS latitude;
+
+DROP EXTENSION IF EXISTS earthdistance;
+DROP EXTENSION IF EXISTS cube;
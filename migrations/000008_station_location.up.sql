@@ -0,0 +1,13 @@
+CREATE EXTENSION IF NOT EXISTS cube;
+CREATE EXTENSION IF NOT EXISTS earthdistance;
+
+-- Координаты допускают NULL только для станций, созданных до этой миграции.
+ALTER TABLE stations
+    ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS address TEXT NOT NULL DEFAULT '',
+    ADD CONSTRAINT chk_latitude CHECK (latitude BETWEEN -90 AND 90),
+    ADD CONSTRAINT chk_longitude CHECK (longitude BETWEEN -180 AND 180);
+
+CREATE INDEX IF NOT EXISTS idx_stations_location
+    ON stations USING GIST (ll_to_earth(latitude, longitude));
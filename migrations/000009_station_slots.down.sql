@@ -0,0 +1,13 @@
+ALTER TABLE rentals
+    DROP COLUMN IF EXISTS end_slot_number,
+    DROP COLUMN IF EXISTS start_slot_number;
+
+DROP INDEX IF EXISTS idx_powerbanks_station_slot;
+
+ALTER TABLE powerbanks
+    DROP CONSTRAINT IF EXISTS chk_slot_number,
+    DROP COLUMN IF EXISTS slot_number;
+
+ALTER TABLE stations
+    DROP CONSTRAINT IF EXISTS chk_capacity,
+    DROP COLUMN IF EXISTS capacity;
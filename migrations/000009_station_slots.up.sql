@@ -0,0 +1,39 @@
+ALTER TABLE stations
+    ADD COLUMN IF NOT EXISTS capacity INTEGER NOT NULL DEFAULT 8;
+
+-- Станции, на которых уже больше повербанков, чем слотов по умолчанию,
+-- получают ёмкость по фактическому числу повербанков.
+UPDATE stations s
+SET capacity = c.total
+FROM (
+    SELECT current_station_id, count(*) AS total
+    FROM powerbanks
+    WHERE status <> 'rented'
+    GROUP BY current_station_id
+) c
+WHERE c.current_station_id = s.id AND c.total > s.capacity;
+
+ALTER TABLE stations
+    ADD CONSTRAINT chk_capacity CHECK (capacity > 0);
+
+-- slot_number равен NULL, пока повербанк в аренде.
+ALTER TABLE powerbanks
+    ADD COLUMN IF NOT EXISTS slot_number INTEGER,
+    ADD CONSTRAINT chk_slot_number CHECK (slot_number > 0);
+
+UPDATE powerbanks p
+SET slot_number = n.slot_number
+FROM (
+    SELECT id, row_number() OVER (PARTITION BY current_station_id ORDER BY id) AS slot_number
+    FROM powerbanks
+    WHERE status <> 'rented'
+) n
+WHERE n.id = p.id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_powerbanks_station_slot
+    ON powerbanks(current_station_id, slot_number)
+    WHERE slot_number IS NOT NULL;
+
+ALTER TABLE rentals
+    ADD COLUMN IF NOT EXISTS start_slot_number INTEGER,
+    ADD COLUMN IF NOT EXISTS end_slot_number INTEGER;
@@ -0,0 +1,5 @@
+ALTER TABLE powerbanks
+    DROP COLUMN IF EXISTS qr_revision;
+
+ALTER TABLE stations
+    DROP COLUMN IF EXISTS qr_revision;
@@ -0,0 +1,5 @@
+ALTER TABLE stations
+    ADD COLUMN IF NOT EXISTS qr_revision INTEGER NOT NULL DEFAULT 1;
+
+ALTER TABLE powerbanks
+    ADD COLUMN IF NOT EXISTS qr_revision INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,8 @@
+ALTER TABLE rentals
+    DROP COLUMN IF EXISTS currency,
+    DROP COLUMN IF EXISTS price,
+    DROP COLUMN IF EXISTS tariff_id;
+
+DROP TRIGGER IF EXISTS tariffs_update_timestamp ON tariffs;
+
+DROP TABLE IF EXISTS tariffs;
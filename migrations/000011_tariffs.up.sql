@@ -0,0 +1,48 @@
+-- Денежные суммы хранятся в минимальных единицах валюты (тиынах, центах).
+CREATE TABLE IF NOT EXISTS tariffs (
+    id SERIAL PRIMARY KEY,
+    org_id INTEGER,
+    station_id INTEGER,
+    currency TEXT NOT NULL DEFAULT 'KZT',
+    unlock_fee BIGINT NOT NULL DEFAULT 0,
+    period_price BIGINT NOT NULL,
+    period_minutes INTEGER NOT NULL DEFAULT 60,
+    free_minutes INTEGER NOT NULL DEFAULT 0,
+    daily_cap BIGINT,
+    lost_penalty BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT fk_organizations
+        FOREIGN KEY (org_id)
+        REFERENCES organizations(id)
+        ON DELETE CASCADE,
+    CONSTRAINT fk_stations
+        FOREIGN KEY (station_id)
+        REFERENCES stations(id)
+        ON DELETE CASCADE,
+    -- Тариф принадлежит либо организации целиком, либо одной станции.
+    CONSTRAINT chk_tariff_owner
+        CHECK ((org_id IS NULL) <> (station_id IS NULL)),
+    CONSTRAINT chk_tariff_amounts
+        CHECK (unlock_fee >= 0 AND period_price >= 0 AND period_minutes > 0
+            AND free_minutes >= 0 AND daily_cap >= 0 AND lost_penalty >= 0)
+);
+
+CREATE TRIGGER tariffs_update_timestamp
+    BEFORE UPDATE ON tariffs
+    FOR EACH ROW
+    EXECUTE PROCEDURE update_timestamp();
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tariffs_org_id
+    ON tariffs(org_id)
+    WHERE org_id IS NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tariffs_station_id
+    ON tariffs(station_id)
+    WHERE station_id IS NOT NULL;
+
+ALTER TABLE rentals
+    ADD COLUMN IF NOT EXISTS tariff_id INTEGER
+        REFERENCES tariffs(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS price BIGINT,
+    ADD COLUMN IF NOT EXISTS currency TEXT;
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS payments_update_timestamp ON payments;
+
+DROP TABLE IF EXISTS payments;
@@ -0,0 +1,34 @@
+-- Каждая попытка операции у платёжного провайдера. idempotency_key защищает
+-- от повторного списания: повтор с тем же ключом не создаёт новую строку.
+CREATE TABLE IF NOT EXISTS payments (
+    id BIGSERIAL PRIMARY KEY,
+    rental_id INTEGER,
+    kind TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    amount BIGINT NOT NULL,
+    currency TEXT NOT NULL,
+    provider TEXT NOT NULL,
+    provider_ref TEXT,
+    idempotency_key TEXT UNIQUE NOT NULL,
+    error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT fk_rentals
+        FOREIGN KEY (rental_id)
+        REFERENCES rentals(id)
+        ON DELETE SET NULL,
+    CONSTRAINT chk_payment_kind
+        CHECK (kind IN ('authorize', 'capture', 'refund', 'void')),
+    CONSTRAINT chk_payment_status
+        CHECK (status IN ('pending', 'succeeded', 'failed')),
+    CONSTRAINT chk_payment_amount
+        CHECK (amount >= 0)
+);
+
+CREATE TRIGGER payments_update_timestamp
+    BEFORE UPDATE ON payments
+    FOR EACH ROW
+    EXECUTE PROCEDURE update_timestamp();
+
+CREATE INDEX IF NOT EXISTS idx_payments_rental_id
+    ON payments(rental_id);
@@ -0,0 +1,22 @@
+DROP INDEX IF EXISTS idx_rentals_open_started_at;
+
+DROP INDEX IF EXISTS idx_rentals_active_powerbank;
+
+UPDATE rentals SET status = 'active' WHERE status = 'overdue';
+UPDATE rentals SET status = 'finished' WHERE status = 'lost';
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_rentals_active_powerbank
+    ON rentals(powerbank_id)
+    WHERE status = 'active';
+
+ALTER TABLE rentals
+    DROP CONSTRAINT IF EXISTS chk_rental_status,
+    ADD CONSTRAINT chk_rental_status
+        CHECK (status IN ('active', 'finished'));
+
+UPDATE powerbanks SET status = 'charging' WHERE status IN ('lost', 'maintenance');
+
+ALTER TABLE powerbanks
+    DROP CONSTRAINT IF EXISTS chk_status,
+    ADD CONSTRAINT chk_status
+        CHECK (status IN ('rented', 'available', 'charging'));
@@ -0,0 +1,21 @@
+ALTER TABLE powerbanks
+    DROP CONSTRAINT IF EXISTS chk_status,
+    ADD CONSTRAINT chk_status
+        CHECK (status IN ('rented', 'available', 'charging', 'lost', 'maintenance'));
+
+-- overdue — аренда всё ещё открыта, но превысила допустимую длительность;
+-- lost — повербанк так и не вернули, аренда закрыта со штрафом.
+ALTER TABLE rentals
+    DROP CONSTRAINT IF EXISTS chk_rental_status,
+    ADD CONSTRAINT chk_rental_status
+        CHECK (status IN ('active', 'overdue', 'finished', 'lost'));
+
+DROP INDEX IF EXISTS idx_rentals_active_powerbank;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_rentals_active_powerbank
+    ON rentals(powerbank_id)
+    WHERE status IN ('active', 'overdue');
+
+CREATE INDEX IF NOT EXISTS idx_rentals_open_started_at
+    ON rentals(started_at)
+    WHERE status IN ('active', 'overdue');
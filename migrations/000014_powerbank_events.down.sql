@@ -0,0 +1 @@
+DROP TABLE IF EXISTS powerbank_events;
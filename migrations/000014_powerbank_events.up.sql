@@ -0,0 +1,36 @@
+-- История повербанка: каждая смена статуса или станции. Пишется в той же
+-- транзакции, что и само изменение.
+CREATE TABLE IF NOT EXISTS powerbank_events (
+    id BIGSERIAL PRIMARY KEY,
+    powerbank_id INTEGER NOT NULL,
+    old_status TEXT,
+    new_status TEXT NOT NULL,
+    from_station_id INTEGER,
+    to_station_id INTEGER,
+    actor_id INTEGER,
+    rental_id INTEGER,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT fk_powerbanks
+        FOREIGN KEY (powerbank_id)
+        REFERENCES powerbanks(id)
+        ON DELETE CASCADE,
+    CONSTRAINT fk_from_stations
+        FOREIGN KEY (from_station_id)
+        REFERENCES stations(id)
+        ON DELETE SET NULL,
+    CONSTRAINT fk_to_stations
+        FOREIGN KEY (to_station_id)
+        REFERENCES stations(id)
+        ON DELETE SET NULL,
+    CONSTRAINT fk_users
+        FOREIGN KEY (actor_id)
+        REFERENCES users(id)
+        ON DELETE SET NULL,
+    CONSTRAINT fk_rentals
+        FOREIGN KEY (rental_id)
+        REFERENCES rentals(id)
+        ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_powerbank_events_powerbank_id
+    ON powerbank_events(powerbank_id, id);
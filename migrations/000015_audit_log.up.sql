@@ -0,0 +1,25 @@
+-- Журнал аудита изменяющих запросов. actor_id и resource_id намеренно без
+-- внешних ключей: запись должна пережить удаление пользователя и ресурса.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    actor_id INTEGER,
+    action TEXT NOT NULL,
+    resource_type TEXT NOT NULL,
+    resource_id INTEGER NOT NULL,
+    before JSONB,
+    after JSONB,
+    ip TEXT NOT NULL DEFAULT '',
+    request_id TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_audit_action
+        CHECK (action IN ('create', 'update', 'delete'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_resource
+    ON audit_log(resource_type, resource_id, created_at);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor_id
+    ON audit_log(actor_id, created_at);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_created_at
+    ON audit_log(created_at);
@@ -0,0 +1,24 @@
+DELETE FROM audit_log WHERE action = 'restore';
+
+ALTER TABLE audit_log
+    DROP CONSTRAINT IF EXISTS chk_audit_action,
+    ADD CONSTRAINT chk_audit_action
+        CHECK (action IN ('create', 'update', 'delete'));
+
+DROP INDEX IF EXISTS idx_powerbanks_station_deleted_at;
+
+DROP INDEX IF EXISTS idx_stations_org_id_deleted_at;
+
+-- Без мягкого удаления помеченные строки были бы удалены окончательно.
+DELETE FROM powerbanks WHERE deleted_at IS NOT NULL;
+DELETE FROM stations WHERE deleted_at IS NOT NULL;
+DELETE FROM organizations WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE powerbanks
+    DROP COLUMN IF EXISTS deleted_at;
+
+ALTER TABLE stations
+    DROP COLUMN IF EXISTS deleted_at;
+
+ALTER TABLE organizations
+    DROP COLUMN IF EXISTS deleted_at;
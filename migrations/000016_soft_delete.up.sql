@@ -0,0 +1,22 @@
+-- Мягкое удаление: строка остаётся в таблице с отметкой deleted_at. Удаление
+-- организации или станции помечает вложенные станции и повербанки тем же
+-- deleted_at, по нему восстановление возвращает их вместе с родителем.
+ALTER TABLE organizations
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+ALTER TABLE stations
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+ALTER TABLE powerbanks
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_stations_org_id_deleted_at
+    ON stations(org_id, deleted_at);
+
+CREATE INDEX IF NOT EXISTS idx_powerbanks_station_deleted_at
+    ON powerbanks(current_station_id, deleted_at);
+
+ALTER TABLE audit_log
+    DROP CONSTRAINT IF EXISTS chk_audit_action,
+    ADD CONSTRAINT chk_audit_action
+        CHECK (action IN ('create', 'update', 'delete', 'restore'));
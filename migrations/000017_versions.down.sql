@@ -0,0 +1,8 @@
+ALTER TABLE powerbanks
+    DROP COLUMN IF EXISTS version;
+
+ALTER TABLE stations
+    DROP COLUMN IF EXISTS version;
+
+ALTER TABLE organizations
+    DROP COLUMN IF EXISTS version;
@@ -0,0 +1,11 @@
+-- Номер версии строки для оптимистичных блокировок: каждое изменение строки
+-- увеличивает version, а UPDATE из API проходит только при совпадении версии,
+-- которую видел клиент.
+ALTER TABLE organizations
+    ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+ALTER TABLE stations
+    ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+ALTER TABLE powerbanks
+    ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,11 @@
+INSERT INTO permissions (code)
+VALUES ('payments:refund')
+ON CONFLICT (code) DO NOTHING;
+
+-- Возвраты раньше проверяли роль admin напрямую: выдаём право тем, у кого
+-- эта роль уже есть.
+INSERT INTO users_permissions (user_id, permission_id)
+SELECT u.id, p.id
+FROM users u, permissions p
+WHERE u.role = 'admin' AND p.code = 'payments:refund'
+ON CONFLICT DO NOTHING;
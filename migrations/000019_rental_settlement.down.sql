@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_rentals_unsettled_ended_at;
+
+ALTER TABLE rentals
+    DROP COLUMN IF EXISTS settled_at;
@@ -0,0 +1,13 @@
+-- settled_at — когда по закрытой аренде завершились расчёты с провайдером.
+-- Закрытые аренды без отметки фоновая задача рассчитывает повторно.
+ALTER TABLE rentals
+    ADD COLUMN IF NOT EXISTS settled_at TIMESTAMPTZ;
+
+-- Аренды, закрытые до появления отметки, повторно не рассчитываются.
+UPDATE rentals
+SET settled_at = ended_at
+WHERE status IN ('finished', 'lost');
+
+CREATE INDEX IF NOT EXISTS idx_rentals_unsettled_ended_at
+    ON rentals(ended_at)
+    WHERE settled_at IS NULL AND status IN ('finished', 'lost');
@@ -0,0 +1,3 @@
+ALTER TABLE tariffs
+    DROP CONSTRAINT IF EXISTS chk_tariff_late_fee,
+    DROP COLUMN IF EXISTS late_fee;
@@ -0,0 +1,4 @@
+-- late_fee — разовая плата за возврат аренды, уже отмеченной просроченной.
+ALTER TABLE tariffs
+    ADD COLUMN IF NOT EXISTS late_fee BIGINT NOT NULL DEFAULT 0,
+    ADD CONSTRAINT chk_tariff_late_fee CHECK (late_fee >= 0);
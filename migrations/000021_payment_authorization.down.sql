@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_payments_authorization_id;
+
+ALTER TABLE payments
+    DROP CONSTRAINT IF EXISTS fk_payments_authorization,
+    DROP COLUMN IF EXISTS authorization_id;
@@ -0,0 +1,24 @@
+-- authorization_id — заморозка, по которой прошли списание, возврат или
+-- снятие заморозки. По ней возврат находит свою заморозку, когда у аренды
+-- их несколько: депозит и доплата сверх него.
+ALTER TABLE payments
+    ADD COLUMN IF NOT EXISTS authorization_id BIGINT,
+    ADD CONSTRAINT fk_payments_authorization
+        FOREIGN KEY (authorization_id)
+        REFERENCES payments(id)
+        ON DELETE RESTRICT;
+
+-- Платежи, записанные до появления связи: доплата списывается из последней
+-- заморозки аренды, остальные операции — из первой, депозита.
+UPDATE payments c
+SET authorization_id = (
+    SELECT a.id
+    FROM payments a
+    WHERE a.rental_id = c.rental_id AND a.kind = 'authorize' AND a.status = 'succeeded'
+    ORDER BY CASE WHEN c.idempotency_key LIKE 'rental:%:shortfall-%' THEN -a.id ELSE a.id END
+    LIMIT 1
+)
+WHERE c.kind <> 'authorize' AND c.rental_id IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_payments_authorization_id
+    ON payments(authorization_id);
@@ -0,0 +1,11 @@
+ALTER TABLE rentals
+    DROP CONSTRAINT IF EXISTS fk_powerbanks,
+    ADD CONSTRAINT fk_powerbanks
+        FOREIGN KEY (powerbank_id)
+        REFERENCES powerbanks(id)
+        ON DELETE CASCADE,
+    DROP CONSTRAINT IF EXISTS fk_start_stations,
+    ADD CONSTRAINT fk_start_stations
+        FOREIGN KEY (start_station_id)
+        REFERENCES stations(id)
+        ON DELETE CASCADE;
@@ -0,0 +1,14 @@
+-- Аренды — основа расчётов и журнала платежей, поэтому жёсткое удаление
+-- повербанка или станции с арендами запрещено. Обычное удаление мягкое и
+-- строки не трогает.
+ALTER TABLE rentals
+    DROP CONSTRAINT IF EXISTS fk_powerbanks,
+    ADD CONSTRAINT fk_powerbanks
+        FOREIGN KEY (powerbank_id)
+        REFERENCES powerbanks(id)
+        ON DELETE RESTRICT,
+    DROP CONSTRAINT IF EXISTS fk_start_stations,
+    ADD CONSTRAINT fk_start_stations
+        FOREIGN KEY (start_station_id)
+        REFERENCES stations(id)
+        ON DELETE RESTRICT;
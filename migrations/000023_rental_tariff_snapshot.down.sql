@@ -0,0 +1,2 @@
+ALTER TABLE rentals
+    DROP COLUMN IF EXISTS tariff_snapshot;
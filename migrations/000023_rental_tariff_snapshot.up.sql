@@ -0,0 +1,20 @@
+-- Аренда оплачивается по тарифу, действовавшему при выдаче: его копия
+-- хранится в самой аренде, потому что строки tariffs правятся на месте.
+ALTER TABLE rentals
+    ADD COLUMN IF NOT EXISTS tariff_snapshot JSONB;
+
+-- Открытым арендам достаётся тариф, действующий на момент миграции.
+UPDATE rentals r
+SET tariff_snapshot = (
+    SELECT to_jsonb(t)
+    FROM tariffs t
+    WHERE t.station_id = r.start_station_id
+    OR t.org_id = (SELECT org_id FROM stations WHERE id = r.start_station_id)
+    ORDER BY t.station_id IS NULL
+    LIMIT 1
+)
+WHERE r.status IN ('active', 'overdue');
+
+UPDATE rentals
+SET tariff_id = (tariff_snapshot->>'id')::INT
+WHERE tariff_snapshot IS NOT NULL AND status IN ('active', 'overdue');
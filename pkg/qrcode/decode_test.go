@@ -0,0 +1,427 @@
+package qrcode_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/png"
+	"math/rand"
+	"testing"
+
+	"github.com/olzzhas/qrent/pkg/qrcode"
+)
+
+// Ниже — эталонный декодер, написанный по ISO/IEC 18004 независимо от
+// кодировщика: свои таблицы, своя арифметика поля Галуа и проверка кодов
+// Рида-Соломона через синдромы, а не через остаток деления. Он читает
+// только отрисованную картинку, поэтому круговой тест проверяет весь путь
+// от данных до PNG.
+
+// refVersion — параметры уровня коррекции M: всего кодовых слов, число
+// блоков и кодовых слов коррекции на блок.
+type refVersion struct {
+	total, blocks, ec int
+	alignment         []int
+}
+
+var refVersions = map[int]refVersion{
+	1:  {26, 1, 10, nil},
+	2:  {44, 1, 16, []int{6, 18}},
+	3:  {70, 1, 26, []int{6, 22}},
+	4:  {100, 2, 18, []int{6, 26}},
+	5:  {134, 2, 24, []int{6, 30}},
+	6:  {172, 4, 16, []int{6, 34}},
+	7:  {196, 4, 18, []int{6, 22, 38}},
+	8:  {242, 4, 22, []int{6, 24, 42}},
+	9:  {292, 5, 22, []int{6, 26, 46}},
+	10: {346, 5, 26, []int{6, 28, 50}},
+}
+
+var gfExp, gfLog = func() (exp [512]byte, log [256]int) {
+	x := 1
+	for i := 0; i < 255; i++ {
+		exp[i] = byte(x)
+		log[x] = i
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11D
+		}
+	}
+	for i := 255; i < 512; i++ {
+		exp[i] = exp[i-255]
+	}
+	return exp, log
+}()
+
+// bch возвращает value с дописанным остатком от деления на порождающий
+// многочлен poly степени degree.
+func bch(value, poly, degree int) int {
+	rem := value << degree
+	for bit := bitLen(rem) - 1; bit >= degree; bit-- {
+		if rem&(1<<bit) != 0 {
+			rem ^= poly << (bit - degree)
+		}
+	}
+	return value<<degree | rem
+}
+
+func bitLen(x int) int {
+	n := 0
+	for ; x > 0; x >>= 1 {
+		n++
+	}
+	return n
+}
+
+// sampleModules восстанавливает матрицу модулей из PNG: размер модуля
+// определяется по верхней строке левого верхнего поискового узора.
+func sampleModules(t *testing.T, pngData []byte) [][]bool {
+	t.Helper()
+
+	img, err := png.Decode(bytes.NewReader(pngData))
+	if err != nil {
+		t.Fatalf("png decode: %s", err)
+	}
+	dark := func(x, y int) bool {
+		r, g, b, _ := img.At(x, y).RGBA()
+		return r+g+b < 3*0x8000
+	}
+
+	side := img.Bounds().Dx()
+	start := 0
+	for start < side && !dark(start, start) {
+		start++
+	}
+	end := start
+	for end < side && dark(end, start) {
+		end++
+	}
+	if (end-start)%7 != 0 || end == start {
+		t.Fatalf("finder pattern not found")
+	}
+	scale := (end - start) / 7
+	size := side/scale - 2*start/scale
+
+	grid := make([][]bool, size)
+	for y := range grid {
+		grid[y] = make([]bool, size)
+		for x := range grid[y] {
+			grid[y][x] = dark(start+x*scale+scale/2, start+y*scale+scale/2)
+		}
+	}
+	return grid
+}
+
+// functionModules отмечает модули служебных узоров, которые не несут данных.
+func functionModules(size int, info refVersion, version int) [][]bool {
+	f := make([][]bool, size)
+	for y := range f {
+		f[y] = make([]bool, size)
+	}
+	fill := func(x0, y0, w, h int) {
+		for y := max(y0, 0); y < min(y0+h, size); y++ {
+			for x := max(x0, 0); x < min(x0+w, size); x++ {
+				f[y][x] = true
+			}
+		}
+	}
+
+	// Поисковые узоры с разделителями и областями формата.
+	fill(0, 0, 9, 9)
+	fill(size-8, 0, 8, 9)
+	fill(0, size-8, 9, 8)
+	// Синхронизирующие линии.
+	fill(6, 0, 1, size)
+	fill(0, 6, size, 1)
+	// Выравнивающие узоры, кроме пересекающихся с поисковыми.
+	last := len(info.alignment) - 1
+	for i, cy := range info.alignment {
+		for j, cx := range info.alignment {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			fill(cx-2, cy-2, 5, 5)
+		}
+	}
+	if version >= 7 {
+		fill(size-11, 0, 3, 6)
+		fill(0, size-11, 6, 3)
+	}
+	return f
+}
+
+func maskBit(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (y/2+x/3)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+// readFormat декодирует обе копии информации о формате и возвращает маску.
+func readFormat(grid [][]bool) (int, error) {
+	size := len(grid)
+	var first, second int
+	for i := 0; i < 15; i++ {
+		var x1, y1, x2, y2 int
+		switch {
+		case i < 6:
+			x1, y1 = 8, i
+		case i < 8:
+			x1, y1 = 8, i+1
+		case i == 8:
+			x1, y1 = 7, 8
+		default:
+			x1, y1 = 14-i, 8
+		}
+		if i < 8 {
+			x2, y2 = size-1-i, 8
+		} else {
+			x2, y2 = 8, size-15+i
+		}
+		if grid[y1][x1] {
+			first |= 1 << i
+		}
+		if grid[y2][x2] {
+			second |= 1 << i
+		}
+	}
+
+	for data := 0; data < 32; data++ {
+		want := bch(data, 0x537, 10) ^ 0x5412
+		if want != first {
+			continue
+		}
+		if want != second {
+			return 0, errors.New("format copies differ")
+		}
+		if data>>3 != 0b00 {
+			return 0, fmt.Errorf("expected error correction level M, got %02b", data>>3)
+		}
+		return data & 7, nil
+	}
+	return 0, fmt.Errorf("invalid format bits %015b", first)
+}
+
+func readVersion(grid [][]bool, version int) error {
+	size := len(grid)
+	want := bch(version, 0x1F25, 12)
+	var bottomLeft, topRight int
+	for i := 0; i < 18; i++ {
+		if grid[size-11+i%3][i/3] {
+			bottomLeft |= 1 << i
+		}
+		if grid[i/3][size-11+i%3] {
+			topRight |= 1 << i
+		}
+	}
+	if bottomLeft != want || topRight != want {
+		return fmt.Errorf("version info mismatch: %018b %018b, want %018b", bottomLeft, topRight, want)
+	}
+	return nil
+}
+
+// decodeModules читает матрицу модулей и возвращает закодированные байты.
+func decodeModules(grid [][]bool) ([]byte, error) {
+	size := len(grid)
+	version := (size - 17) / 4
+	info, ok := refVersions[version]
+	if !ok || size != 17+4*version {
+		return nil, fmt.Errorf("unsupported size %d", size)
+	}
+	if !grid[size-8][8] {
+		return nil, errors.New("dark module is missing")
+	}
+
+	mask, err := readFormat(grid)
+	if err != nil {
+		return nil, err
+	}
+	if version >= 7 {
+		if err := readVersion(grid, version); err != nil {
+			return nil, err
+		}
+	}
+
+	function := functionModules(size, info, version)
+	raw := make([]byte, 0, info.total)
+	var cur byte
+	n := 0
+	for right := size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < size; vert++ {
+			y := vert
+			if upward {
+				y = size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if function[y][x] {
+					continue
+				}
+				cur = cur<<1 | boolByte(grid[y][x] != maskBit(mask, x, y))
+				if n++; n%8 == 0 && len(raw) < info.total {
+					raw = append(raw, cur)
+					cur = 0
+				}
+			}
+		}
+	}
+	if len(raw) != info.total {
+		return nil, fmt.Errorf("read %d codewords, want %d", len(raw), info.total)
+	}
+
+	dataTotal := info.total - info.blocks*info.ec
+	short := dataTotal / info.blocks
+	longFrom := info.blocks - dataTotal%info.blocks
+	blocks := make([][]byte, info.blocks)
+	pos := 0
+	for i := 0; i <= short; i++ {
+		for b := range blocks {
+			if i == short && b < longFrom {
+				continue
+			}
+			blocks[b] = append(blocks[b], raw[pos])
+			pos++
+		}
+	}
+	var data []byte
+	for b := range blocks {
+		data = append(data, blocks[b]...)
+	}
+	for i := 0; i < info.ec; i++ {
+		for b := range blocks {
+			blocks[b] = append(blocks[b], raw[pos])
+			pos++
+		}
+	}
+
+	for b, block := range blocks {
+		for j := 0; j < info.ec; j++ {
+			var s byte
+			for _, c := range block {
+				if s != 0 {
+					s = gfExp[gfLog[s]+j]
+				}
+				s ^= c
+			}
+			if s != 0 {
+				return nil, fmt.Errorf("block %d: syndrome %d is %d", b, j, s)
+			}
+		}
+	}
+
+	bit := 0
+	read := func(length int) int {
+		v := 0
+		for i := 0; i < length; i++ {
+			v = v<<1 | int(data[bit/8]>>(7-bit%8)&1)
+			bit++
+		}
+		return v
+	}
+	if m := read(4); m != 0b0100 {
+		return nil, fmt.Errorf("expected byte mode, got %04b", m)
+	}
+	countBits := 8
+	if version >= 10 {
+		countBits = 16
+	}
+	length := read(countBits)
+	if 4+countBits+8*length > 8*len(data) {
+		return nil, fmt.Errorf("length %d exceeds capacity", length)
+	}
+	out := make([]byte, length)
+	for i := range out {
+		out[i] = byte(read(8))
+	}
+	return out, nil
+}
+
+func boolByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func TestEncode_RoundTrip(t *testing.T) {
+	inputs := [][]byte{
+		[]byte("qrent:v1:s:1:1:signature"),
+		[]byte("https://qrent.kz/s/12?rev=3&sig=Zm9vYmFyYmF6"),
+		{},
+		{0x00, 0xFF, 0x80, 0x7F},
+	}
+	// Максимальная длина для каждой версии 1–10 и случайные длины между ними.
+	rng := rand.New(rand.NewSource(1))
+	for _, length := range []int{14, 26, 42, 62, 84, 106, 122, 152, 180, 213} {
+		data := make([]byte, length)
+		rng.Read(data)
+		inputs = append(inputs, data)
+	}
+	for i := 0; i < 100; i++ {
+		data := make([]byte, rng.Intn(214))
+		rng.Read(data)
+		inputs = append(inputs, data)
+	}
+
+	for _, input := range inputs {
+		code, err := qrcode.Encode(input)
+		if err != nil {
+			t.Fatalf("encode %d bytes: %s", len(input), err)
+		}
+		img, err := code.PNG(3)
+		if err != nil {
+			t.Fatalf("png: %s", err)
+		}
+
+		got, err := decodeModules(sampleModules(t, img))
+		if err != nil {
+			t.Fatalf("decode %d bytes (size %d): %s", len(input), code.Size, err)
+		}
+		if !bytes.Equal(got, input) {
+			t.Fatalf("round trip mismatch for %d bytes: got %x, want %x", len(input), got, input)
+		}
+	}
+}
+
+// Декодер обязан замечать порчу, иначе круговой тест ничего не доказывает.
+func TestDecodeModules_DetectsCorruption(t *testing.T) {
+	code, err := qrcode.Encode([]byte("qrent:v1:p:7:2:signature"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	grid := make([][]bool, code.Size)
+	for y := range grid {
+		grid[y] = make([]bool, code.Size)
+		for x := range grid[y] {
+			grid[y][x] = code.Dark(x, y)
+		}
+	}
+	if _, err := decodeModules(grid); err != nil {
+		t.Fatalf("unexpected error on intact code: %s", err)
+	}
+
+	// Правый нижний модуль всегда несёт первый бит данных.
+	grid[code.Size-1][code.Size-1] = !grid[code.Size-1][code.Size-1]
+	if _, err := decodeModules(grid); err == nil {
+		t.Error("expected a syndrome error for a corrupted module")
+	}
+}
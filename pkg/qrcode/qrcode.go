@@ -0,0 +1,496 @@
+// Package qrcode кодирует короткие строки в QR-код (байтовый режим, уровень
+// коррекции M, версии 1–10) и отрисовывает его в PNG или SVG. Этого хватает
+// для подписанных ссылок на станции и повербанки; произвольные данные больше
+// 213 байт не поддерживаются.
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// QuietZone — ширина обязательной светлой рамки вокруг кода в модулях.
+const QuietZone = 4
+
+var ErrTooLong = errors.New("qrcode: data too long")
+
+// Code — готовая матрица модулей QR-кода.
+type Code struct {
+	Size    int
+	modules [][]bool
+}
+
+// Dark сообщает, тёмный ли модуль в столбце x и строке y.
+func (c *Code) Dark(x, y int) bool {
+	return c.modules[y][x]
+}
+
+type versionInfo struct {
+	ecPerBlock int
+	// blocks — размеры блоков данных в кодовых словах.
+	blocks []int
+	// alignment — координаты центров выравнивающих узоров.
+	alignment []int
+}
+
+// versions — таблица уровня коррекции M для версий 1–10 (ISO/IEC 18004).
+var versions = [...]versionInfo{
+	1:  {10, []int{16}, nil},
+	2:  {16, []int{28}, []int{6, 18}},
+	3:  {26, []int{44}, []int{6, 22}},
+	4:  {18, []int{32, 32}, []int{6, 26}},
+	5:  {24, []int{43, 43}, []int{6, 30}},
+	6:  {16, []int{27, 27, 27, 27}, []int{6, 34}},
+	7:  {18, []int{31, 31, 31, 31}, []int{6, 22, 38}},
+	8:  {22, []int{38, 38, 39, 39}, []int{6, 24, 42}},
+	9:  {22, []int{36, 36, 36, 37, 37}, []int{6, 26, 46}},
+	10: {26, []int{43, 43, 43, 43, 44}, []int{6, 28, 50}},
+}
+
+func (v versionInfo) dataCodewords() int {
+	total := 0
+	for _, n := range v.blocks {
+		total += n
+	}
+	return total
+}
+
+// Encode строит QR-код минимальной подходящей версии.
+func Encode(data []byte) (*Code, error) {
+	for version := 1; version < len(versions); version++ {
+		countBits := 8
+		if version >= 10 {
+			countBits = 16
+		}
+
+		if 4+countBits+8*len(data) <= 8*versions[version].dataCodewords() {
+			return encode(data, version, countBits), nil
+		}
+	}
+
+	return nil, ErrTooLong
+}
+
+func encode(data []byte, version, countBits int) *Code {
+	info := versions[version]
+
+	var bits bitBuffer
+	bits.append(0b0100, 4)
+	bits.append(len(data), countBits)
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+
+	capacity := 8 * info.dataCodewords()
+	bits.append(0, min(4, capacity-len(bits)))
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+
+	codewords := interleave(bits.bytes(), info)
+
+	m := newMatrix(17 + 4*version)
+	m.drawFunctionPatterns(version, info)
+	m.drawCodewords(codewords)
+
+	bestMask, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		m.applyMask(mask)
+		m.drawFormatBits(mask)
+		if penalty := m.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			bestMask, bestPenalty = mask, penalty
+		}
+		m.applyMask(mask) // маска обратима: повторное применение её снимает
+	}
+	m.applyMask(bestMask)
+	m.drawFormatBits(bestMask)
+
+	return &Code{Size: m.size, modules: m.modules}
+}
+
+// interleave делит данные на блоки, дописывает к каждому коды Рида-Соломона
+// и перемежает кодовые слова блоков.
+func interleave(data []byte, info versionInfo) []byte {
+	divisor := rsDivisor(info.ecPerBlock)
+
+	dataBlocks := make([][]byte, len(info.blocks))
+	ecBlocks := make([][]byte, len(info.blocks))
+	offset := 0
+	for i, n := range info.blocks {
+		dataBlocks[i] = data[offset : offset+n]
+		ecBlocks[i] = rsRemainder(dataBlocks[i], divisor)
+		offset += n
+	}
+
+	result := make([]byte, 0, len(data)+len(info.blocks)*info.ecPerBlock)
+	for i := 0; i < info.blocks[len(info.blocks)-1]; i++ {
+		for _, block := range dataBlocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := 0; i < info.ecPerBlock; i++ {
+		for _, block := range ecBlocks {
+			result = append(result, block[i])
+		}
+	}
+
+	return result
+}
+
+type bitBuffer []bool
+
+func (b *bitBuffer) append(value, length int) {
+	for i := length - 1; i >= 0; i-- {
+		*b = append(*b, (value>>i)&1 == 1)
+	}
+}
+
+func (b bitBuffer) bytes() []byte {
+	result := make([]byte, len(b)/8)
+	for i, bit := range b {
+		if bit {
+			result[i/8] |= 1 << (7 - i%8)
+		}
+	}
+	return result
+}
+
+// rsMultiply умножает в поле GF(2^8) по модулю x^8 + x^4 + x^3 + x^2 + 1.
+func rsMultiply(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+
+	var root byte = 1
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = rsMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = rsMultiply(root, 0x02)
+	}
+
+	return result
+}
+
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, d := range divisor {
+			result[i] ^= rsMultiply(d, factor)
+		}
+	}
+	return result
+}
+
+type matrix struct {
+	size       int
+	modules    [][]bool
+	isFunction [][]bool
+}
+
+func newMatrix(size int) *matrix {
+	m := &matrix{size: size}
+	m.modules = make([][]bool, size)
+	m.isFunction = make([][]bool, size)
+	for y := range m.modules {
+		m.modules[y] = make([]bool, size)
+		m.isFunction[y] = make([]bool, size)
+	}
+	return m
+}
+
+func (m *matrix) setFunction(x, y int, dark bool) {
+	m.modules[y][x] = dark
+	m.isFunction[y][x] = true
+}
+
+func (m *matrix) drawFunctionPatterns(version int, info versionInfo) {
+	for i := 0; i < m.size; i++ {
+		m.setFunction(6, i, i%2 == 0)
+		m.setFunction(i, 6, i%2 == 0)
+	}
+
+	m.drawFinder(3, 3)
+	m.drawFinder(m.size-4, 3)
+	m.drawFinder(3, m.size-4)
+
+	last := len(info.alignment) - 1
+	for i, x := range info.alignment {
+		for j, y := range info.alignment {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			m.drawAlignment(x, y)
+		}
+	}
+
+	// Резервируем место под форматную информацию до наложения маски.
+	m.drawFormatBits(0)
+	m.drawVersion(version)
+}
+
+func (m *matrix) drawFinder(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || x >= m.size || y < 0 || y >= m.size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			m.setFunction(x, y, dist != 2 && dist != 4)
+		}
+	}
+}
+
+func (m *matrix) drawAlignment(cx, cy int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			m.setFunction(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// drawFormatBits рисует обе копии уровня коррекции M и номера маски.
+func (m *matrix) drawFormatBits(mask int) {
+	data := 0b00<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+
+	bit := func(i int) bool { return (bits>>i)&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		m.setFunction(8, i, bit(i))
+	}
+	m.setFunction(8, 7, bit(6))
+	m.setFunction(8, 8, bit(7))
+	m.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		m.setFunction(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		m.setFunction(m.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		m.setFunction(8, m.size-15+i, bit(i))
+	}
+	m.setFunction(8, m.size-8, true)
+}
+
+func (m *matrix) drawVersion(version int) {
+	if version < 7 {
+		return
+	}
+
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	bits := version<<12 | rem
+
+	for i := 0; i < 18; i++ {
+		dark := (bits>>i)&1 == 1
+		a, b := m.size-11+i%3, i/3
+		m.setFunction(a, b, dark)
+		m.setFunction(b, a, dark)
+	}
+}
+
+// drawCodewords раскладывает биты зигзагом по парам столбцов снизу справа.
+func (m *matrix) drawCodewords(data []byte) {
+	i := 0
+	for right := m.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < m.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = m.size - 1 - vert
+				}
+				if m.isFunction[y][x] || i >= len(data)*8 {
+					continue
+				}
+				m.modules[y][x] = (data[i>>3]>>(7-i&7))&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+func (m *matrix) applyMask(mask int) {
+	for y := 0; y < m.size; y++ {
+		for x := 0; x < m.size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !m.isFunction[y][x] {
+				m.modules[y][x] = !m.modules[y][x]
+			}
+		}
+	}
+}
+
+var finderLike = [...][11]bool{
+	{true, false, true, true, true, false, true, false, false, false, false},
+	{false, false, false, false, true, false, true, true, true, false, true},
+}
+
+// penalty считает штраф маски по четырём правилам стандарта.
+func (m *matrix) penalty() int {
+	result := 0
+	at := func(x, y int, transpose bool) bool {
+		if transpose {
+			return m.modules[x][y]
+		}
+		return m.modules[y][x]
+	}
+
+	for _, transpose := range []bool{false, true} {
+		for y := 0; y < m.size; y++ {
+			run := 1
+			for x := 1; x <= m.size; x++ {
+				if x < m.size && at(x, y, transpose) == at(x-1, y, transpose) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					result += 3 + run - 5
+				}
+				run = 1
+			}
+
+			for x := 0; x+11 <= m.size; x++ {
+				for _, pattern := range finderLike {
+					match := true
+					for k, dark := range pattern {
+						if at(x+k, y, transpose) != dark {
+							match = false
+							break
+						}
+					}
+					if match {
+						result += 40
+					}
+				}
+			}
+		}
+	}
+
+	dark := 0
+	for y := 0; y < m.size; y++ {
+		for x := 0; x < m.size; x++ {
+			if m.modules[y][x] {
+				dark++
+			}
+			if x+1 < m.size && y+1 < m.size {
+				c := m.modules[y][x]
+				if c == m.modules[y][x+1] && c == m.modules[y+1][x] && c == m.modules[y+1][x+1] {
+					result += 3
+				}
+			}
+		}
+	}
+
+	total := m.size * m.size
+	result += abs(dark*20-total*10) / total * 10
+
+	return result
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// PNG отрисовывает код, где каждый модуль — квадрат scale×scale пикселей.
+func (c *Code) PNG(scale int) ([]byte, error) {
+	side := (c.Size + 2*QuietZone) * scale
+	img := image.NewGray(image.Rect(0, 0, side, side))
+	for i := range img.Pix {
+		img.Pix[i] = 0xFF
+	}
+
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.Dark(x, y) {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetGray((x+QuietZone)*scale+dx, (y+QuietZone)*scale+dy, color.Gray{})
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SVG отрисовывает код одним path, масштабируемым до scale пикселей на модуль.
+func (c *Code) SVG(scale int) []byte {
+	side := c.Size + 2*QuietZone
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		side*scale, side*scale, side, side)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, side, side)
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.Dark(x, y) {
+				fmt.Fprintf(&buf, "M%d %dh1v1h-1z", x+QuietZone, y+QuietZone)
+			}
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+
+	return buf.Bytes()
+}
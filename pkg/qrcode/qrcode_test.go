@@ -0,0 +1,59 @@
+package qrcode
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Контрольные значения взяты из разборов стандарта ISO/IEC 18004
+// (пример «HELLO WORLD», версия 1-M).
+func TestRSRemainder(t *testing.T) {
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	expected := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+
+	if got := rsRemainder(data, rsDivisor(10)); !bytes.Equal(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestEncode_VersionSelection(t *testing.T) {
+	tests := []struct {
+		length int
+		size   int
+	}{
+		{14, 21},
+		{40, 29},
+		{213, 57},
+	}
+
+	for _, tt := range tests {
+		code, err := Encode(bytes.Repeat([]byte("a"), tt.length))
+		if err != nil {
+			t.Fatalf("unexpected error for %d bytes: %s", tt.length, err)
+		}
+		if code.Size != tt.size {
+			t.Errorf("%d bytes: expected size %d, got %d", tt.length, tt.size, code.Size)
+		}
+	}
+
+	if _, err := Encode(bytes.Repeat([]byte("a"), 214)); err != ErrTooLong {
+		t.Errorf("expected ErrTooLong, got %v", err)
+	}
+}
+
+func TestEncode_FunctionPatterns(t *testing.T) {
+	code, err := Encode([]byte("qrent:v1:s:1:1:signature"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Угловые поисковые узоры и тёмный модуль не зависят от данных и маски.
+	for _, corner := range [][2]int{{0, 0}, {code.Size - 7, 0}, {0, code.Size - 7}} {
+		if !code.Dark(corner[0], corner[1]) || code.Dark(corner[0]+1, corner[1]+1) || !code.Dark(corner[0]+3, corner[1]+3) {
+			t.Errorf("finder pattern at %v is broken", corner)
+		}
+	}
+	if !code.Dark(8, code.Size-8) {
+		t.Error("dark module is missing")
+	}
+}